@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"aisandbox/pkg/sandbox"
+)
+
+// buildCmd 实现 `ai-sandbox build` 子命令：按 Sandboxfile 构建镜像。
+func buildCmd(argv []string) int {
+	var (
+		file      string
+		tag       string
+		storeDir  string
+		noCache   bool
+		noNet     bool
+		noCgroup  bool
+		cpuQuota  int
+		cpuPeriod int
+		memoryMax string
+		pidsMax   int
+		noSeccomp bool
+		size      string
+		logDir    string
+		logLevel  string
+	)
+
+	fs := flag.NewFlagSet("ai-sandbox build", flag.ExitOnError)
+	fs.StringVar(&file, "f", "", "path to the Sandboxfile (default: <context>/Sandboxfile)")
+	fs.StringVar(&tag, "t", "", "name of the resulting image (required)")
+	fs.StringVar(&storeDir, "store-dir", sandbox.DefaultLayerStoreDir, "local layer store directory")
+	fs.BoolVar(&noCache, "no-cache", false, "do not use cached layers")
+	fs.BoolVar(&noNet, "no-net", false, "disable network namespace isolation for RUN steps")
+	fs.BoolVar(&noCgroup, "no-cgroup", false, "disable cgroup resource limits for RUN steps")
+	fs.IntVar(&cpuQuota, "cpu-quota", 100000, "CPU quota in microseconds per period (0=unlimited)")
+	fs.IntVar(&cpuPeriod, "cpu-period", 100000, "CPU period in microseconds")
+	fs.StringVar(&memoryMax, "memory-max", "1g", "memory limit (supports k/m/g suffixes, 0=unlimited)")
+	fs.IntVar(&pidsMax, "pids-max", 512, "maximum number of processes (0=unlimited)")
+	fs.BoolVar(&noSeccomp, "no-seccomp", false, "disable seccomp syscall filtering for RUN steps")
+	fs.StringVar(&size, "overlay-size", "1g", "tmpfs size limit for each step's upper layer")
+	fs.StringVar(&logDir, "log-dir", "/var/log/ai-sandbox", "log file storage directory")
+	fs.StringVar(&logLevel, "log-level", "info", "log level: debug/info/warn/error")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ai-sandbox build -t <name> [options] [context-dir]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Instructions: FROM, RUN, COPY, ENV, WORKDIR, USER, ENTRYPOINT, CMD")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(argv)
+
+	if tag == "" || fs.NArg() > 1 {
+		fs.Usage()
+		return ExitFailure
+	}
+	contextDir := "."
+	if fs.NArg() == 1 {
+		contextDir = fs.Arg(0)
+	}
+	if file == "" {
+		file = filepath.Join(contextDir, "Sandboxfile")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return ExitFailure
+	}
+	steps, err := sandbox.ParseSandboxfile(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %s: %v\n", file, err)
+		return ExitFailure
+	}
+
+	slog, err := sandbox.NewSandboxLogger(sandbox.LogConfig{Level: logLevel, Dir: logDir})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: create logger: %v\n", err)
+		return ExitFailure
+	}
+	defer slog.Close()
+	logger := slog.Logger()
+
+	store, err := sandbox.NewLayerStore(storeDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return ExitFailure
+	}
+	store.SetLogger(logger)
+
+	cfg := sandbox.DefaultBuildConfig(contextDir)
+	cfg.NoCache = noCache
+	cfg.OverlaySize = size
+	if noNet {
+		cfg.Namespace.Network = false
+		cfg.Namespace.SetupLoopback = false
+	}
+	if noSeccomp {
+		cfg.Seccomp = nil
+	}
+	if !noCgroup {
+		memBytes, err := parseMemorySize(memoryMax)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: invalid --memory-max %q: %v\n", memoryMax, err)
+			return ExitFailure
+		}
+		cfg.Cgroups = &sandbox.CgroupsConfig{
+			Enabled:      true,
+			CPUQuota:     cpuQuota,
+			CPUPeriod:    cpuPeriod,
+			MemoryMax:    memBytes,
+			PidsMax:      pidsMax,
+			DeviceFilter: true,
+		}
+	}
+
+	b := sandbox.NewBuilder(store, cfg)
+	b.SetLogger(logger)
+	m, err := b.Build(steps, tag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return ExitFailure
+	}
+	fmt.Println(m.Name)
+	return ExitSuccess
+}
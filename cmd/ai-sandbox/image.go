@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"aisandbox/pkg/sandbox"
+)
+
+// imageCmd 实现 `ai-sandbox image` 子命令：管理本地层存储中的层和镜像。
+func imageCmd(argv []string) int {
+	fs := flag.NewFlagSet("ai-sandbox image", flag.ExitOnError)
+	storeDir := fs.String("store-dir", sandbox.DefaultLayerStoreDir, "local layer store directory")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ai-sandbox image [--store-dir DIR] <subcommand> [args...]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Subcommands:")
+		fmt.Fprintln(os.Stderr, "  import <archive|oci-dir> [name]     import a docker save archive or OCI image layout")
+		fmt.Fprintln(os.Stderr, "  import-layer <file.tar[.gz]>...     import tar archives as layers, print their digests")
+		fmt.Fprintln(os.Stderr, "  create <name> <digest>...           create an image from layers (bottom to top)")
+		fmt.Fprintln(os.Stderr, "  ls                                  list images")
+		fmt.Fprintln(os.Stderr, "  layers                              list layers and their reference counts")
+		fmt.Fprintln(os.Stderr, "  rm <name>...                        remove images (layers are kept until prune)")
+		fmt.Fprintln(os.Stderr, "  prune                               remove layers not used by any image or sandbox")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(argv)
+
+	args := fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		return ExitFailure
+	}
+
+	store, err := sandbox.NewLayerStore(*storeDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return ExitFailure
+	}
+
+	sub, args := args[0], args[1:]
+	switch sub {
+	case "import":
+		if len(args) < 1 || len(args) > 2 {
+			fmt.Fprintln(os.Stderr, "sandbox: image import: usage: import <archive|oci-dir> [name]")
+			return ExitFailure
+		}
+		var name string
+		if len(args) == 2 {
+			name = args[1]
+		}
+		m, err := store.ImportImage(args[0], name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+			return ExitFailure
+		}
+		fmt.Println(m.Name)
+
+	case "import-layer":
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, "sandbox: image import-layer: no files given")
+			return ExitFailure
+		}
+		for _, path := range args {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+				return ExitFailure
+			}
+			info, err := store.ImportLayer(f)
+			f.Close()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: %s: %v\n", path, err)
+				return ExitFailure
+			}
+			fmt.Println(info.Digest)
+		}
+
+	case "create":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "sandbox: image create: usage: create <name> <digest>...")
+			return ExitFailure
+		}
+		m := sandbox.ImageManifest{Name: args[0], Layers: args[1:]}
+		if err := store.CreateImage(m); err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+			return ExitFailure
+		}
+
+	case "ls":
+		images, err := store.Images()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+			return ExitFailure
+		}
+		fmt.Printf("%-32s %-7s %s\n", "NAME", "LAYERS", "CREATED")
+		for _, m := range images {
+			fmt.Printf("%-32s %-7d %s\n", m.Name, len(m.Layers), m.Created.Format("2006-01-02 15:04:05"))
+		}
+
+	case "layers":
+		layers, err := store.Layers()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+			return ExitFailure
+		}
+		fmt.Printf("%-71s %-12s %s\n", "DIGEST", "SIZE", "REFS")
+		for _, l := range layers {
+			refs, _ := store.RefCount(l.Digest)
+			fmt.Printf("%-71s %-12d %d\n", l.Digest, l.Size, refs)
+		}
+
+	case "rm":
+		for _, name := range args {
+			if err := store.RemoveImage(name); err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+				return ExitFailure
+			}
+		}
+
+	case "prune":
+		removed, err := store.Prune()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+			return ExitFailure
+		}
+		if len(removed) > 0 {
+			fmt.Println(strings.Join(removed, "\n"))
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "sandbox: image: unknown subcommand %q\n", sub)
+		fs.Usage()
+		return ExitFailure
+	}
+	return ExitSuccess
+}
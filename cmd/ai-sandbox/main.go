@@ -6,6 +6,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"aisandbox/pkg/sandbox"
 )
@@ -13,73 +15,211 @@ import (
 const (
 	ExitSuccess = 0 // 正常退出
 	ExitFailure = 1 // 一般性错误
+
+	// ExitCPUBudget 表示 CPU 时间预算耗尽，沙箱被杀死（128+SIGXCPU，与 RLIMIT_CPU 超限的 shell 约定一致）
+	ExitCPUBudget = 128 + int(syscall.SIGXCPU)
 )
 
 func main() {
 	// 必须在第一行：检测是否是sandbox init子进程
 	sandbox.MustReexecInit()
 
-	os.Exit(run())
+	os.Exit(dispatch(os.Args[1:]))
+}
+
+// dispatch 根据第一个参数分发子命令并返回退出码。
+// 未识别的第一个参数按 run 处理，保持 `ai-sandbox [options] <command>` 的用法不变。
+func dispatch(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			return run(args[1:])
+		case "image":
+			return imageCmd(args[1:])
+		case "build":
+			return buildCmd(args[1:])
+		case "rootfs":
+			return rootfsCmd(args[1:])
+		case "stats":
+			return statsCmd(args[1:])
+		case "update":
+			return updateCmd(args[1:])
+		case "usage":
+			return usageCmd(args[1:])
+		case "pause":
+			return pauseCmd("pause", args[1:], true)
+		case "resume":
+			return pauseCmd("resume", args[1:], false)
+		}
+	}
+	return run(args)
 }
 
 // run 执行主逻辑并返回退出码。
 // 独立为函数以确保 defer 正常执行（os.Exit 会跳过 defer）。
-func run() int {
+func run(argv []string) int {
 	var (
-		noPID        bool
-		noIPC        bool
-		noNet        bool
-		noUTS        bool
-		host         string
-		noOverlay    bool
-		overlayLower string
-		overlaySize  string
-		noCgroup     bool
-		cpuQuota     int
-		cpuPeriod    int
-		memoryMax    string
-		pidsMax      int
-		logDir       string
-		logLevel     string
-		noSeccomp    bool
-		seccompLog   bool
-		noPivotRoot  bool
-		rootfs       string
+		noPID         bool
+		noIPC         bool
+		noNet         bool
+		noUTS         bool
+		host          string
+		noOverlay     bool
+		overlayLower  string
+		overlaySize   string
+		overlayInodes string
+		image         string
+		storeDir      string
+		noCgroup      bool
+		cpuQuota      int
+		cpuPeriod     int
+		memoryMax     string
+		memoryHigh    string
+		memorySwapMax string
+		memoryLow     string
+		memoryMin     string
+		oomGroup      bool
+		pidsMax       int
+		ioReadBps     stringSliceFlag
+		ioWriteBps    stringSliceFlag
+		ioReadIOPS    stringSliceFlag
+		ioWriteIOPS   stringSliceFlag
+		ioWeight      int
+		cgroupParent  string
+		cgroupDeleg   bool
+		cpusetCPUs    string
+		cpusetMems    string
+		cpus          int
+		cpuExclusive  bool
+		pressureWarn  stringSliceFlag
+		cpuBudget     time.Duration
+		budgetFreeze  bool
+		labels        stringSliceFlag
+		logDir        string
+		logLevel      string
+		noSeccomp     bool
+		seccompLog    bool
+		noPivotRoot   bool
+		rootfs        string
+		mounts        stringSliceFlag
+		noMaskPaths   bool
+		mountSysfs    bool
+		collect       stringSliceFlag
+		collectDir    string
+		collectFiles  int
+		collectSize   string
+		noEtcFiles    bool
+		dns           stringSliceFlag
+		dnsSearch     stringSliceFlag
+		dnsOptions    stringSliceFlag
+		addHosts      stringSliceFlag
+		hostsFile     string
+		resolvConf    string
+		passwdFile    string
+		groupFile     string
+		inheritDev    bool
+		devices       string
+		noDevpts      bool
+		shmSize       string
+		devSize       string
+		deviceAllow   stringSliceFlag
+		noDevFilter   bool
 	)
 
-	flag.BoolVar(&noPID, "no-pid", false, "disable PID namespace isolation")
-	flag.BoolVar(&noIPC, "no-ipc", false, "disable IPC namespace isolation")
-	flag.BoolVar(&noNet, "no-net", false, "disable network namespace isolation")
-	flag.BoolVar(&noUTS, "no-uts", false, "disable UTS namespace isolation")
-	flag.StringVar(&host, "hostname", "sandbox", "hostname inside the sandbox")
-	flag.BoolVar(&noOverlay, "no-overlay", false, "disable OverlayFS filesystem isolation (DANGEROUS: allows host modification)")
-	flag.StringVar(&overlayLower, "overlay-lower", "/", "lower directory for OverlayFS (read-only base)")
-	flag.StringVar(&overlaySize, "overlay-size", "64m", "tmpfs size limit for OverlayFS upper layer")
-	flag.BoolVar(&noCgroup, "no-cgroup", false, "disable cgroups v2 resource limits")
-	flag.IntVar(&cpuQuota, "cpu-quota", 100000, "CPU quota in microseconds per period (0=unlimited)")
-	flag.IntVar(&cpuPeriod, "cpu-period", 100000, "CPU period in microseconds")
-	flag.StringVar(&memoryMax, "memory-max", "512m", "memory limit (supports k/m/g suffixes, 0=unlimited)")
-	flag.IntVar(&pidsMax, "pids-max", 512, "maximum number of processes (0=unlimited)")
-	flag.StringVar(&logDir, "log-dir", "/var/log/ai-sandbox", "log file storage directory")
-	flag.StringVar(&logLevel, "log-level", "info", "log level: debug/info/warn/error")
-	flag.BoolVar(&noSeccomp, "no-seccomp", false, "disable seccomp syscall filtering")
-	flag.BoolVar(&seccompLog, "seccomp-log", false, "log seccomp violations instead of killing")
-	flag.BoolVar(&noPivotRoot, "no-pivot-root", false, "disable pivot_root confinement")
-	flag.StringVar(&rootfs, "rootfs", "", "rootfs path for pivot_root (without overlay)")
-	flag.Parse()
-
-	args := flag.Args()
-	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "Usage: ai-sandbox [options] <command> [args...]")
+	fs := flag.NewFlagSet("ai-sandbox", flag.ExitOnError)
+
+	fs.BoolVar(&noPID, "no-pid", false, "disable PID namespace isolation")
+	fs.BoolVar(&noIPC, "no-ipc", false, "disable IPC namespace isolation")
+	fs.BoolVar(&noNet, "no-net", false, "disable network namespace isolation")
+	fs.BoolVar(&noUTS, "no-uts", false, "disable UTS namespace isolation")
+	fs.StringVar(&host, "hostname", "sandbox", "hostname inside the sandbox")
+	fs.BoolVar(&noOverlay, "no-overlay", false, "disable OverlayFS filesystem isolation (DANGEROUS: allows host modification)")
+	fs.StringVar(&overlayLower, "overlay-lower", "/", "lower directory for OverlayFS (read-only base)")
+	fs.StringVar(&overlaySize, "overlay-size", "64m", "tmpfs size limit for OverlayFS upper layer")
+	fs.StringVar(&overlayInodes, "overlay-inodes", "", "inode limit for OverlayFS upper layer, e.g. 100k (default: kernel default)")
+	fs.StringVar(&image, "image", "", "run on an image from the local layer store instead of --overlay-lower")
+	fs.StringVar(&storeDir, "store-dir", sandbox.DefaultLayerStoreDir, "local layer store directory")
+	fs.BoolVar(&noCgroup, "no-cgroup", false, "disable cgroup resource limits")
+	fs.IntVar(&cpuQuota, "cpu-quota", 100000, "CPU quota in microseconds per period (0=unlimited)")
+	fs.IntVar(&cpuPeriod, "cpu-period", 100000, "CPU period in microseconds")
+	fs.StringVar(&memoryMax, "memory-max", "512m", "memory limit (supports k/m/g suffixes, 0=unlimited)")
+	fs.StringVar(&memoryHigh, "memory-high", "0", "soft memory limit: throttle and reclaim above it instead of OOM killing (0=unset)")
+	fs.StringVar(&memorySwapMax, "memory-swap-max", "max", "swap limit (0=no swap, max=inherit from parent)")
+	fs.StringVar(&memoryLow, "memory-low", "0", "best-effort memory protection from reclaim (0=unset)")
+	fs.StringVar(&memoryMin, "memory-min", "0", "hard memory protection from reclaim (0=unset)")
+	fs.BoolVar(&oomGroup, "oom-group", false, "on OOM, kill the whole sandbox instead of only the chosen process")
+	fs.IntVar(&pidsMax, "pids-max", 512, "maximum number of processes (0=unlimited)")
+	fs.Var(&ioReadBps, "io-read-bps", "read bandwidth limit: device:rate, e.g. /dev/vda:10m or /var/lib:10m (repeatable)")
+	fs.Var(&ioWriteBps, "io-write-bps", "write bandwidth limit: device:rate, e.g. 254:0:10m (repeatable)")
+	fs.Var(&ioReadIOPS, "io-read-iops", "read IOPS limit: device:iops (repeatable)")
+	fs.Var(&ioWriteIOPS, "io-write-iops", "write IOPS limit: device:iops (repeatable)")
+	fs.IntVar(&ioWeight, "io-weight", 0, "relative IO weight 1-10000 (0=kernel default)")
+	fs.StringVar(&cpusetCPUs, "cpuset-cpus", "", "pin the sandbox to these CPUs, e.g. 0-3,6 (default: inherit)")
+	fs.StringVar(&cpusetMems, "cpuset-mems", "", "restrict memory allocation to these NUMA nodes, e.g. 0 (default: inherit)")
+	fs.IntVar(&cpus, "cpus", 0, "allocate and pin N CPUs not used by other pinned sandboxes (see --cpu-exclusive)")
+	fs.BoolVar(&cpuExclusive, "cpu-exclusive", false, "with --cpus, do not share the allocated CPUs with any other pinned sandbox")
+	fs.DurationVar(&cpuBudget, "cpu-budget", 0, "total CPU time the sandbox may use over its lifetime, e.g. 10m; time blocked on IO is not charged (0=unlimited)")
+	fs.BoolVar(&budgetFreeze, "cpu-budget-freeze", false, "when --cpu-budget is exhausted, freeze the sandbox instead of killing it")
+	fs.Var(&pressureWarn, "pressure-warn", "log a warning when the sandbox stalls: '<cpu|memory|io> <some|full> <stall_us> <window_us>', e.g. 'memory some 150000 2000000' (repeatable)")
+	fs.StringVar(&cgroupParent, "cgroup-parent", "", "nested parent cgroups for the sandbox, e.g. ai-sandbox/tenant-a/wf-17 (limits: ai-sandbox update <path>)")
+	fs.BoolVar(&cgroupDeleg, "cgroup-delegate", false, "create cgroups under this process's own (systemd-delegated) cgroup instead of the cgroup root")
+	fs.StringVar(&logDir, "log-dir", "/var/log/ai-sandbox", "log file storage directory")
+	fs.Var(&labels, "label", "accounting label key=value recorded in <log-dir>/usage.jsonl, e.g. team=search (repeatable)")
+	fs.StringVar(&logLevel, "log-level", "info", "log level: debug/info/warn/error")
+	fs.BoolVar(&noSeccomp, "no-seccomp", false, "disable seccomp syscall filtering")
+	fs.BoolVar(&seccompLog, "seccomp-log", false, "log seccomp violations instead of killing")
+	fs.BoolVar(&noPivotRoot, "no-pivot-root", false, "disable pivot_root confinement")
+	fs.StringVar(&rootfs, "rootfs", "", "rootfs path for pivot_root (without overlay)")
+	fs.BoolVar(&noMaskPaths, "no-mask-paths", false, "disable masking of sensitive /proc and /sys paths")
+	fs.BoolVar(&mountSysfs, "mount-sysfs", false, "mount a fresh read-only sysfs at /sys instead of inheriting it")
+	fs.Var(&mounts, "mount", "bind mount a host path into the sandbox: src:dst[:ro,nosuid,nodev,noexec,...] (repeatable)")
+	fs.Var(&collect, "collect", "glob of output files to copy out after exit, e.g. 'out/**/*.png' (repeatable)")
+	fs.StringVar(&collectDir, "collect-dir", "./artifacts", "host directory for collected output files")
+	fs.IntVar(&collectFiles, "collect-max-files", 1000, "maximum number of collected files")
+	fs.StringVar(&collectSize, "collect-max-size", "64m", "maximum total size of collected files (supports k/m/g suffixes)")
+	fs.Var(&deviceAllow, "device-allow", "additional cgroup device rule, e.g. 'c 10:200 rwm' (repeatable)")
+	fs.BoolVar(&noDevFilter, "no-device-filter", false, "do not attach the cgroup device allowlist BPF program (skipped with a warning on kernels that cannot load it)")
+	fs.BoolVar(&inheritDev, "inherit-dev", false, "use the root filesystem's own /dev instead of a fresh tmpfs /dev")
+	fs.StringVar(&devices, "devices", "", "comma-separated device nodes to create in /dev: null,zero,full,random,urandom,tty (default: all)")
+	fs.BoolVar(&noDevpts, "no-devpts", false, "do not mount a private devpts at /dev/pts")
+	fs.StringVar(&devSize, "dev-size", "64m", "tmpfs size limit for /dev")
+	fs.StringVar(&shmSize, "shm-size", "64m", "tmpfs size limit for /dev/shm (0=no /dev/shm)")
+	fs.BoolVar(&noEtcFiles, "no-etc-files", false, "inherit /etc/hosts, resolv.conf, passwd and group instead of generating them")
+	fs.Var(&dns, "dns", "DNS server for the generated /etc/resolv.conf (repeatable)")
+	fs.Var(&dnsSearch, "dns-search", "DNS search domain for the generated /etc/resolv.conf (repeatable)")
+	fs.Var(&dnsOptions, "dns-option", "DNS option for the generated /etc/resolv.conf, e.g. ndots:1 (repeatable)")
+	fs.Var(&addHosts, "add-host", "extra /etc/hosts entry: name:ip (repeatable)")
+	fs.StringVar(&hostsFile, "hosts-file", "", "host file to use as /etc/hosts ('-' keeps the sandbox's own)")
+	fs.StringVar(&resolvConf, "resolv-conf", "", "host file to use as /etc/resolv.conf ('-' keeps the sandbox's own)")
+	fs.StringVar(&passwdFile, "passwd-file", "", "host file to use as /etc/passwd ('-' keeps the sandbox's own)")
+	fs.StringVar(&groupFile, "group-file", "", "host file to use as /etc/group ('-' keeps the sandbox's own)")
+	fs.Parse(argv)
+
+	args := fs.Args()
+	if len(args) == 0 && image == "" {
+		fmt.Fprintln(os.Stderr, "Usage: ai-sandbox [run] [options] <command> [args...]")
+		fmt.Fprintln(os.Stderr, "       ai-sandbox image <subcommand> [args...]")
+		fmt.Fprintln(os.Stderr, "       ai-sandbox build -t <name> [-f Sandboxfile] [context-dir]")
+		fmt.Fprintln(os.Stderr, "       ai-sandbox rootfs build -o <dir> <binary>...")
+		fmt.Fprintln(os.Stderr, "       ai-sandbox stats [options] [id...]")
+		fmt.Fprintln(os.Stderr, "       ai-sandbox pause|resume <id>...")
+		fmt.Fprintln(os.Stderr, "       ai-sandbox update [options] <id>")
+		fmt.Fprintln(os.Stderr, "       ai-sandbox usage [--since 24h] [--group-by label]")
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "Options:")
-		flag.PrintDefaults()
+		fs.PrintDefaults()
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "Example:")
 		fmt.Fprintln(os.Stderr, "  ai-sandbox sh -c 'echo hello'")
 		fmt.Fprintln(os.Stderr, "  ai-sandbox --no-net sh -c 'echo hello'")
 		fmt.Fprintln(os.Stderr, "  ai-sandbox sh -c 'touch /tmp/test && ls /tmp/test'")
 		fmt.Fprintln(os.Stderr, "  ai-sandbox --memory-max 1g --cpu-quota 50000 python agent.py")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox --cpus 4 --cpu-exclusive --cpu-quota 400000 python bench.py")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox --io-write-bps /var/lib/ai-sandbox:20m --io-weight 50 python agent.py")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox --mount /data/project:/workspace --mount /data/ds:/data:ro python agent.py")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox --no-net --dns 1.1.1.1 --add-host db:10.0.0.5 python agent.py")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox run --image python-3.12-ds python agent.py")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox image import python-3.12.tar && ai-sandbox run --image python:3.12")
 		fmt.Fprintln(os.Stderr, "  ai-sandbox --no-overlay sh -c 'echo no isolation'  # DANGEROUS")
 		return ExitFailure
 	}
@@ -123,9 +263,45 @@ func run() int {
 	defer ns.Cleanup()
 
 	// 配置OverlayFS（默认启用：保护宿主机文件系统不被修改）
+	if image != "" && noOverlay {
+		fmt.Fprintln(os.Stderr, "sandbox: --image requires OverlayFS")
+		return ExitFailure
+	}
 	if !noOverlay {
 		ovConfig := sandbox.DefaultOverlayConfig(overlayLower)
+		if image != "" {
+			store, err := sandbox.NewLayerStore(storeDir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+				return ExitFailure
+			}
+			store.SetLogger(logger)
+			lowerDirs, release, err := store.Acquire(image)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+				return ExitFailure
+			}
+			// 在 ns.Cleanup 之后释放（defer 逆序执行）
+			defer release()
+			ovConfig.LowerDirs = lowerDirs
+
+			// 应用镜像的 Env/WorkingDir/User，并按 Entrypoint/Cmd 合成命令
+			m, err := store.Image(image)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+				return ExitFailure
+			}
+			if m.Config != nil {
+				m.Config.Apply(ns)
+				args = m.Config.Command(args)
+			}
+			if len(args) == 0 {
+				fmt.Fprintf(os.Stderr, "sandbox: image %s has no Entrypoint/Cmd, specify a command\n", image)
+				return ExitFailure
+			}
+		}
 		ovConfig.TmpfsSize = overlaySize
+		ovConfig.TmpfsInodes = overlayInodes
 		ov := sandbox.NewOverlayFS(ovConfig)
 		ov.SetLogger(logger)
 		if err := ov.Setup(); err != nil {
@@ -135,7 +311,7 @@ func run() int {
 		ns.SetOverlayFS(ov)
 	}
 
-	// 配置CgroupsV2（默认启用）
+	// 配置 cgroup 资源限制（默认启用，宿主机只有 cgroup v1 时自动使用 v1 后端）
 	if !noCgroup {
 		memBytes, err := parseMemorySize(memoryMax)
 		if err != nil {
@@ -143,20 +319,133 @@ func run() int {
 			return ExitFailure
 		}
 
+		highBytes, err := parseMemorySize(memoryHigh)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: invalid --memory-high %q: %v\n", memoryHigh, err)
+			return ExitFailure
+		}
+		swapBytes, err := parseSwapMax(memorySwapMax)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: invalid --memory-swap-max %q: %v\n", memorySwapMax, err)
+			return ExitFailure
+		}
+		lowBytes, err := parseMemorySize(memoryLow)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: invalid --memory-low %q: %v\n", memoryLow, err)
+			return ExitFailure
+		}
+		minBytes, err := parseMemorySize(memoryMin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: invalid --memory-min %q: %v\n", memoryMin, err)
+			return ExitFailure
+		}
+
 		cgConfig := sandbox.CgroupsConfig{
-			Enabled:   true,
-			CPUQuota:  cpuQuota,
-			CPUPeriod: cpuPeriod,
-			MemoryMax: memBytes,
-			PidsMax:   pidsMax,
+			Enabled:        true,
+			CPUQuota:       cpuQuota,
+			CPUPeriod:      cpuPeriod,
+			MemoryMax:      memBytes,
+			MemoryHigh:     highBytes,
+			MemorySwapMax:  swapBytes,
+			MemoryLow:      lowBytes,
+			MemoryMin:      minBytes,
+			MemoryOOMGroup: oomGroup,
+			PidsMax:        pidsMax,
+			IOWeight:       ioWeight,
+			CPUSetCPUs:     cpusetCPUs,
+			CPUSetMems:     cpusetMems,
+			DeviceFilter:   !noDevFilter,
+		}
+		if cpus > 0 {
+			if cpusetCPUs != "" {
+				fmt.Fprintln(os.Stderr, "sandbox: --cpus and --cpuset-cpus are mutually exclusive")
+				return ExitFailure
+			}
+			alloc, err := sandbox.NewCPUSetAllocator(sandbox.DefaultCPUSetDir, "")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+				return ExitFailure
+			}
+			alloc.SetLogger(logger)
+			set, release, err := alloc.Allocate(cpus, cpuExclusive)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+				return ExitFailure
+			}
+			defer release()
+			cgConfig.CPUSetCPUs = set.CPUs
+			if cpusetMems == "" {
+				cgConfig.CPUSetMems = set.Mems
+			}
+		} else if cpuExclusive {
+			fmt.Fprintln(os.Stderr, "sandbox: --cpu-exclusive requires --cpus")
+			return ExitFailure
+		}
+		if cgroupDeleg {
+			if cgConfig.BaseDir, err = sandbox.DelegatedBaseDir(); err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+				return ExitFailure
+			}
+		}
+		if cgroupParent != "" {
+			for _, name := range strings.Split(strings.Trim(cgroupParent, "/"), "/") {
+				cgConfig.Parents = append(cgConfig.Parents, sandbox.CgroupParent{Name: name})
+			}
+		}
+		cgConfig.IOLimits, err = applyIOLimitFlags(nil, ioReadBps, ioWriteBps, ioReadIOPS, ioWriteIOPS)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+			return ExitFailure
+		}
+		if len(deviceAllow) > 0 {
+			if noDevFilter {
+				fmt.Fprintln(os.Stderr, "sandbox: --device-allow and --no-device-filter are mutually exclusive")
+				return ExitFailure
+			}
+			cgConfig.Devices = sandbox.DefaultDeviceRules()
+			for _, spec := range deviceAllow {
+				rule, err := sandbox.ParseDeviceRule(spec)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "sandbox: invalid --device-allow: %v\n", err)
+					return ExitFailure
+				}
+				cgConfig.Devices = append(cgConfig.Devices, rule)
+			}
+		}
+		cg := sandbox.NewCgroups(cgConfig)
+		cg2, _ := cg.(*sandbox.CgroupsV2)
+		if len(pressureWarn) > 0 && cg2 == nil {
+			fmt.Fprintln(os.Stderr, "sandbox: --pressure-warn requires cgroups v2")
+			return ExitFailure
+		}
+		if cpuBudget > 0 && cg2 == nil {
+			fmt.Fprintln(os.Stderr, "sandbox: --cpu-budget requires cgroups v2")
+			return ExitFailure
 		}
-		cg := sandbox.NewCgroupsV2(cgConfig)
 		cg.SetLogger(logger)
 		if err := cg.Setup(); err != nil {
 			fmt.Fprintf(os.Stderr, "sandbox: cgroup setup: %v\n", err)
 			return ExitFailure
 		}
-		ns.SetCgroupsV2(cg)
+		ns.SetCgroups(cg)
+
+		// 沙箱接近资源上限（例如即将 OOM）时记录告警
+		for _, spec := range pressureWarn {
+			trigger, err := sandbox.ParsePSITrigger(spec)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: invalid --pressure-warn: %v\n", err)
+				return ExitFailure
+			}
+			stop, err := cg2.WatchPressure(trigger, func(ev sandbox.PSIEvent) {
+				fmt.Fprintf(os.Stderr, "sandbox: %s: resource pressure: %s (%s PSI %s)\n",
+					cg.ID(), ev.Trigger, ev.Trigger.Resource, formatPSI(ev.Pressure))
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+				return ExitFailure
+			}
+			defer stop()
+		}
 	}
 
 	// 配置 Seccomp-BPF（默认启用）
@@ -166,6 +455,92 @@ func run() int {
 		ns.SetSeccomp(&scfg)
 	}
 
+	// 配置 /proc、/sys 加固（默认启用）
+	if !noMaskPaths || mountSysfs {
+		mcfg := sandbox.DefaultMaskPathsConfig()
+		if noMaskPaths {
+			// 仅挂载 sysfs，不屏蔽任何路径
+			mcfg.MaskedPaths = []string{}
+			mcfg.ReadonlyPaths = []string{}
+		}
+		mcfg.MountSysfs = mountSysfs
+		ns.SetMaskPaths(&mcfg)
+	}
+
+	// bind mount 和生成的 /etc 文件挂载在新 root 内，只有 pivot_root 进入 overlay 或 --rootfs 时才生效
+	pivotsRoot := !noPivotRoot && (!noOverlay || rootfs != "")
+
+	// 配置宿主机 bind mount
+	if len(mounts) > 0 {
+		if !pivotsRoot {
+			fmt.Fprintln(os.Stderr, "sandbox: --mount requires pivot_root into an overlay or --rootfs (not --no-pivot-root)")
+			return ExitFailure
+		}
+		var binds []sandbox.BindMount
+		for _, spec := range mounts {
+			m, err := sandbox.ParseBindMount(spec)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "sandbox: invalid --mount: %v\n", err)
+				return ExitFailure
+			}
+			binds = append(binds, m)
+		}
+		ns.SetBindMounts(binds)
+	}
+
+	// 配置 /etc 文件生成（默认启用：不泄漏宿主机主机名、DNS 和用户账户）
+	// 不 pivot_root 时进程直接使用宿主机的 /etc，显式的 /etc 选项无法生效
+	etcFlags := len(dns) > 0 || len(dnsSearch) > 0 || len(dnsOptions) > 0 || len(addHosts) > 0 ||
+		hostsFile != "" || resolvConf != "" || passwdFile != "" || groupFile != ""
+	if !noEtcFiles && !pivotsRoot && etcFlags {
+		fmt.Fprintln(os.Stderr, "sandbox: --dns, --add-host and the /etc file options require pivot_root into an overlay or --rootfs (not --no-pivot-root)")
+		return ExitFailure
+	}
+	if !noEtcFiles && pivotsRoot {
+		ecfg := sandbox.DefaultEtcFilesConfig()
+		ecfg.DNS = dns
+		ecfg.DNSSearch = dnsSearch
+		ecfg.DNSOptions = dnsOptions
+		ecfg.ExtraHosts = addHosts
+		ecfg.Hosts = hostsFile
+		ecfg.ResolvConf = resolvConf
+		ecfg.Passwd = passwdFile
+		ecfg.Group = groupFile
+		ns.SetEtcFiles(&ecfg)
+	}
+
+	// 配置输出文件收集（需要 OverlayFS）
+	if len(collect) > 0 {
+		if noOverlay {
+			fmt.Fprintln(os.Stderr, "sandbox: --collect requires OverlayFS")
+			return ExitFailure
+		}
+		maxBytes, err := parseMemorySize(collectSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: invalid --collect-max-size %q: %v\n", collectSize, err)
+			return ExitFailure
+		}
+		acfg := sandbox.DefaultArtifactConfig(collectDir, collect...)
+		acfg.MaxFiles = collectFiles
+		acfg.MaxTotalBytes = maxBytes
+		ns.SetArtifacts(&acfg)
+	}
+
+	// 配置资源记账（默认启用，账本位于日志目录，用 `ai-sandbox usage` 汇总）
+	ucfg := sandbox.DefaultAccountingConfig(logDir)
+	for _, l := range labels {
+		k, v, ok := strings.Cut(l, "=")
+		if !ok || k == "" {
+			fmt.Fprintf(os.Stderr, "sandbox: invalid --label %q (expected key=value)\n", l)
+			return ExitFailure
+		}
+		if ucfg.Labels == nil {
+			ucfg.Labels = make(map[string]string)
+		}
+		ucfg.Labels[k] = v
+	}
+	ns.SetAccounting(&ucfg)
+
 	// 配置 PivotRoot（默认启用）
 	if !noPivotRoot {
 		pcfg := sandbox.DefaultPivotRootConfig()
@@ -173,17 +548,168 @@ func run() int {
 			pcfg.RootDir = rootfs
 		}
 		ns.SetPivotRoot(&pcfg)
+
+		dcfg := sandbox.DefaultDevConfig()
+		dcfg.Enabled = !inheritDev
+		if devices != "" {
+			dcfg.Devices = strings.Split(devices, ",")
+		}
+		dcfg.Size = devSize
+		dcfg.Devpts = !noDevpts
+		dcfg.ShmSize = shmSize
+		if shmSize == "0" {
+			dcfg.ShmSize = ""
+		}
+		ns.SetDev(&dcfg)
 	}
 
-	result, err := ns.Execute(args[0], args[1:]...)
+	// 配置 CPU 时间预算（需要 cgroups v2）
+	if cpuBudget > 0 {
+		if noCgroup {
+			fmt.Fprintln(os.Stderr, "sandbox: --cpu-budget requires cgroups")
+			return ExitFailure
+		}
+		bcfg := sandbox.DefaultCPUBudgetConfig(cpuBudget)
+		bcfg.Freeze = budgetFreeze
+		ns.SetCPUBudget(&bcfg)
+	}
+
+	if err := ns.Start(args[0], args[1:]...); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return ExitFailure
+	}
+	if cpuBudget > 0 && budgetFreeze {
+		exhausted, done := ns.CPUBudgetExhausted(), ns.Done()
+		go func() {
+			select {
+			case <-exhausted:
+				fmt.Fprintf(os.Stderr, "sandbox: CPU time budget of %v exhausted, sandbox frozen (ai-sandbox resume continues it without a budget)\n", cpuBudget)
+			case <-done:
+			}
+		}()
+	}
+	result, err := ns.Wait()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
 		return ExitFailure
 	}
 
+	if u := result.OverlayUsage; u != nil && u.Exhausted() {
+		fmt.Fprintf(os.Stderr, "sandbox: overlay exhausted (peak %d/%d bytes, %d/%d inodes), consider raising --overlay-size/--overlay-inodes\n",
+			u.BytesUsed, u.BytesTotal, u.InodesUsed, u.InodesTotal)
+	}
+
+	// 资源耗尽时进程只看到 SIGKILL 或 EAGAIN，在这里明确提示
+	var oomKills, pidsMaxHits uint64
+	for _, ev := range result.CgroupEvents {
+		switch ev.Type {
+		case sandbox.CgroupEventOOMKill:
+			oomKills += ev.Count
+		case sandbox.CgroupEventPidsMax:
+			pidsMaxHits += ev.Count
+		}
+	}
+	if oomKills > 0 {
+		fmt.Fprintf(os.Stderr, "sandbox: %d processes killed by the OOM killer, consider raising --memory-max\n", oomKills)
+	}
+	if pidsMaxHits > 0 {
+		fmt.Fprintf(os.Stderr, "sandbox: %d forks failed at the process limit, consider raising --pids-max\n", pidsMaxHits)
+	}
+
+	if result.Artifacts != nil {
+		for _, a := range result.Artifacts.Files {
+			fmt.Fprintf(os.Stderr, "sandbox: collected %s (%d bytes)\n", a.Path, a.Size)
+		}
+		if result.Artifacts.Truncated {
+			fmt.Fprintln(os.Stderr, "sandbox: artifact collection truncated by limits")
+		}
+	}
+
+	if result.Termination == sandbox.TerminationCPUBudget {
+		fmt.Fprintf(os.Stderr, "sandbox: CPU time budget of %v exhausted, sandbox killed\n", cpuBudget)
+		return ExitCPUBudget
+	}
 	return result.ExitCode
 }
 
+// stringSliceFlag 是可重复指定的字符串 flag，每次出现追加一个值。
+type stringSliceFlag []string
+
+func (f *stringSliceFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringSliceFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// applyIOLimitFlags 将 --io-read-bps/--io-write-bps/--io-read-iops/--io-write-iops 合并到 limits。
+func applyIOLimitFlags(limits []sandbox.IOLimit, readBps, writeBps, readIOPS, writeIOPS []string) ([]sandbox.IOLimit, error) {
+	for _, f := range []struct {
+		name  string
+		specs []string
+		bytes bool
+		set   func(*sandbox.IOLimit, uint64)
+	}{
+		{"io-read-bps", readBps, true, func(l *sandbox.IOLimit, v uint64) { l.RBps = v }},
+		{"io-write-bps", writeBps, true, func(l *sandbox.IOLimit, v uint64) { l.WBps = v }},
+		{"io-read-iops", readIOPS, false, func(l *sandbox.IOLimit, v uint64) { l.RIOPS = v }},
+		{"io-write-iops", writeIOPS, false, func(l *sandbox.IOLimit, v uint64) { l.WIOPS = v }},
+	} {
+		for _, spec := range f.specs {
+			var err error
+			if limits, err = addIOLimit(limits, spec, f.bytes, f.set); err != nil {
+				return nil, fmt.Errorf("invalid --%s: %v", f.name, err)
+			}
+		}
+	}
+	return limits, nil
+}
+
+// addIOLimit 解析 "device:value" 并合并到同一设备的 IOLimit 中。
+// device 本身可能包含冒号（"254:0"），因此以最后一个冒号分隔。
+// bytes 为 true 时 value 支持 k/m/g 后缀。
+func addIOLimit(limits []sandbox.IOLimit, spec string, bytes bool, set func(*sandbox.IOLimit, uint64)) ([]sandbox.IOLimit, error) {
+	i := strings.LastIndex(spec, ":")
+	if i <= 0 {
+		return nil, fmt.Errorf("%q: expected device:value", spec)
+	}
+	device, value := spec[:i], spec[i+1:]
+
+	var v int64
+	var err error
+	if bytes {
+		v, err = parseMemorySize(value)
+	} else {
+		v, err = strconv.ParseInt(value, 10, 64)
+	}
+	if err != nil || v <= 0 {
+		return nil, fmt.Errorf("%q: invalid value %q", spec, value)
+	}
+
+	for j := range limits {
+		if limits[j].Device == device {
+			set(&limits[j], uint64(v))
+			return limits, nil
+		}
+	}
+	l := sandbox.IOLimit{Device: device}
+	set(&l, uint64(v))
+	return append(limits, l), nil
+}
+
+// parseSwapMax 解析 --memory-swap-max："0" 禁止 swap，"max" 继承父 cgroup，其余同 parseMemorySize。
+func parseSwapMax(s string) (int64, error) {
+	switch s {
+	case "0":
+		return sandbox.MemorySwapNone, nil
+	case "max":
+		return 0, nil
+	}
+	return parseMemorySize(s)
+}
+
 // parseMemorySize 解析带后缀的内存大小字符串。
 // 支持 k/K（KB）、m/M（MB）、g/G（GB）后缀，纯数字视为字节。
 // 例如："512m" → 536870912, "1g" → 1073741824, "0" → 0
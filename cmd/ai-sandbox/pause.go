@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"aisandbox/pkg/sandbox"
+)
+
+// pauseCmd 实现 `ai-sandbox pause|resume <id>...`：通过 cgroup.freeze 冻结或解冻运行中的沙箱。
+func pauseCmd(name string, argv []string, freeze bool) int {
+	var baseDir string
+	fs := flag.NewFlagSet("ai-sandbox "+name, flag.ExitOnError)
+	fs.StringVar(&baseDir, "base-dir", sandbox.DefaultCgroupsConfig().BaseDir, "cgroup2 mount point")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ai-sandbox %s [options] <id>...\n", name)
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(argv)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return ExitFailure
+	}
+
+	code := ExitSuccess
+	for _, id := range fs.Args() {
+		dir, id := sandboxCgroupDir(baseDir, id)
+		if _, err := os.Stat(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: %s: no such sandbox\n", id)
+			code = ExitFailure
+			continue
+		}
+		if err := sandbox.SetCgroupFrozen(dir, freeze); err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: %s: %v\n", id, err)
+			code = ExitFailure
+			continue
+		}
+		fmt.Println(id)
+	}
+	return code
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"aisandbox/pkg/sandbox"
+)
+
+// rootfsCmd 实现 `ai-sandbox rootfs` 子命令：从宿主机可执行文件生成最小 rootfs。
+func rootfsCmd(argv []string) int {
+	if len(argv) == 0 || argv[0] != "build" {
+		fmt.Fprintln(os.Stderr, "Usage: ai-sandbox rootfs build -o <dir> [options] <binary>...")
+		return ExitFailure
+	}
+
+	var (
+		out      string
+		files    stringSliceFlag
+		hardlink bool
+		verbose  bool
+	)
+	fs := flag.NewFlagSet("ai-sandbox rootfs build", flag.ExitOnError)
+	fs.StringVar(&out, "o", "", "output directory (required)")
+	fs.Var(&files, "file", "extra host file or directory to copy recursively, e.g. /usr/lib/python3.12 (repeatable)")
+	fs.BoolVar(&hardlink, "hardlink", false, "hardlink files instead of copying (only for read-only use such as --overlay-lower)")
+	fs.BoolVar(&verbose, "v", false, "print copied files")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ai-sandbox rootfs build -o <dir> [options] <binary>...")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Example:")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox rootfs build -o /var/lib/ai-sandbox/rootfs/strict --file /usr/lib/python3.12 python3 sh git")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox --overlay-lower /var/lib/ai-sandbox/rootfs/strict python3 agent.py")
+	}
+	fs.Parse(argv[1:])
+
+	if out == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return ExitFailure
+	}
+
+	cfg := sandbox.DefaultRootfsConfig(fs.Args()...)
+	cfg.Files = files
+	cfg.Hardlink = hardlink
+	copied, err := sandbox.NewRootfsBuilder(cfg).Build(out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return ExitFailure
+	}
+
+	if verbose {
+		for _, f := range copied {
+			fmt.Println(f)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "sandbox: rootfs %s: %d files\n", out, len(copied))
+	return ExitSuccess
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"aisandbox/pkg/sandbox"
+)
+
+// statsCmd 实现 `ai-sandbox stats` 子命令：类似 top 周期性显示运行中沙箱的资源使用。
+// id 为 run 日志中的 cgroup_id（也接受 "sandbox-<id>"），不指定时显示全部沙箱。
+func statsCmd(argv []string) int {
+	var (
+		baseDir  string
+		interval time.Duration
+		once     bool
+		jsonOut  bool
+	)
+	fs := flag.NewFlagSet("ai-sandbox stats", flag.ExitOnError)
+	fs.StringVar(&baseDir, "base-dir", sandbox.DefaultCgroupsConfig().BaseDir, "cgroup2 mount point")
+	fs.DurationVar(&interval, "interval", time.Second, "refresh interval")
+	fs.BoolVar(&once, "once", false, "print a single sample and exit")
+	fs.BoolVar(&jsonOut, "json", false, "print one JSON object per sandbox and sample instead of a table")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ai-sandbox stats [options] [id...]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Example:")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox stats")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox stats --json --interval 5s 1a2b3c4d >> agent-usage.jsonl")
+	}
+	fs.Parse(argv)
+
+	if interval <= 0 {
+		fmt.Fprintln(os.Stderr, "sandbox: --interval must be positive")
+		return ExitFailure
+	}
+
+	ids := fs.Args()
+	for i, id := range ids {
+		_, ids[i] = sandboxCgroupDir(baseDir, id)
+	}
+
+	prev := make(map[string]*sandbox.CgroupStats)
+	for {
+		// id → cgroup 目录（沙箱可能位于嵌套的父 cgroup 中）
+		dirs := make(map[string]string)
+		targets := ids
+		if len(targets) == 0 {
+			dirs = listSandboxCgroups(baseDir)
+			for id := range dirs {
+				targets = append(targets, id)
+			}
+			sort.Strings(targets)
+		} else {
+			for _, id := range ids {
+				dirs[id], _ = sandboxCgroupDir(baseDir, id)
+			}
+		}
+
+		cur := make(map[string]*sandbox.CgroupStats)
+		for _, id := range targets {
+			s, err := sandbox.ReadCgroupStats(dirs[id])
+			if s == nil {
+				// 显式指定的沙箱不存在（或已退出）
+				if len(ids) > 0 {
+					fmt.Fprintf(os.Stderr, "sandbox: %s: %v\n", id, err)
+				}
+				continue
+			}
+			cur[id] = s
+		}
+		if len(ids) > 0 && len(cur) == 0 {
+			return ExitFailure
+		}
+
+		switch {
+		case jsonOut:
+			printStatsJSON(targets, cur)
+		case once && len(prev) == 0 && len(cur) > 0:
+			// 第一次采样没有 CPU 使用率，--once 时等待一个周期后再输出
+			prev = cur
+			time.Sleep(interval)
+			continue
+		default:
+			if !once {
+				fmt.Print("\033[H\033[2J")
+			}
+			printStatsTable(targets, cur, prev)
+		}
+		if once {
+			return ExitSuccess
+		}
+		prev = cur
+		time.Sleep(interval)
+	}
+}
+
+// sandboxCgroupDir 返回沙箱 id 对应的 cgroup 目录和规范化后的 id（去掉 "sandbox-" 前缀）。
+// 沙箱不直接位于 baseDir 下时在嵌套的父 cgroup 中查找；
+// 包含 "/" 的参数视为相对 baseDir 的 cgroup 路径（例如租户父 cgroup "ai-sandbox/tenant-a"）。
+func sandboxCgroupDir(baseDir, id string) (string, string) {
+	if strings.Contains(id, "/") {
+		return filepath.Join(baseDir, id), id
+	}
+	id = strings.TrimPrefix(id, "sandbox-")
+	dir := filepath.Join(baseDir, "sandbox-"+id)
+	if _, err := os.Stat(dir); err != nil {
+		if found, ok := listSandboxCgroups(baseDir)[id]; ok {
+			dir = found
+		}
+	}
+	return dir, id
+}
+
+// listSandboxCgroups 返回 baseDir 下（包括嵌套的父 cgroup 中）所有沙箱 cgroup 的 id 到目录的映射。
+func listSandboxCgroups(baseDir string) map[string]string {
+	dirs := make(map[string]string)
+	filepath.WalkDir(baseDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if id, ok := strings.CutPrefix(d.Name(), "sandbox-"); ok && path != baseDir {
+			dirs[id] = path
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs
+}
+
+func printStatsJSON(ids []string, cur map[string]*sandbox.CgroupStats) {
+	enc := json.NewEncoder(os.Stdout)
+	for _, id := range ids {
+		s, ok := cur[id]
+		if !ok {
+			continue
+		}
+		enc.Encode(struct {
+			ID string `json:"id"`
+			*sandbox.CgroupStats
+		}{id, s})
+	}
+}
+
+func printStatsTable(ids []string, cur, prev map[string]*sandbox.CgroupStats) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATE\tCPU%\tMEM\tPEAK\tPIDS\tIO READ\tIO WRITE\tTHROTTLED\tOOM KILLS\tCPU PSI\tMEM PSI")
+	for _, id := range ids {
+		s, ok := cur[id]
+		if !ok {
+			continue
+		}
+		cpu := "-"
+		if p := prev[id]; p != nil {
+			cpu = fmt.Sprintf("%.1f", s.CPUPercent(p))
+		}
+		var rbytes, wbytes uint64
+		for _, d := range s.IO {
+			rbytes += d.RBytes
+			wbytes += d.WBytes
+		}
+		state := sandbox.StateRunning
+		switch {
+		case s.Frozen:
+			state = sandbox.StatePaused
+		case !s.Populated:
+			state = sandbox.StateStopped
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			id, state, cpu,
+			formatBytes(s.Memory.Current), formatBytes(s.Memory.Peak),
+			s.Pids.Current,
+			formatBytes(rbytes), formatBytes(wbytes),
+			s.CPU.NrThrottled, s.Memory.Events.OOMKill,
+			formatPSI(s.Pressure.CPU), formatPSI(s.Pressure.Memory))
+	}
+	tw.Flush()
+}
+
+// formatPSI 显示 some avg10（最近 10 秒因资源停顿的时间百分比）。
+func formatPSI(p *sandbox.PSIStats) string {
+	if p == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", p.Some.Avg10)
+}
+
+// formatBytes 以 1024 进制显示字节数，例如 "12.3M"。
+func formatBytes(n uint64) string {
+	const units = "KMGT"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	v := float64(n)
+	i := -1
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%c", v, units[i])
+}
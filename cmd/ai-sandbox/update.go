@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"aisandbox/pkg/sandbox"
+)
+
+// updateCmd 实现 `ai-sandbox update <id>`：修改运行中沙箱的资源限制。
+// 只修改命令行上显式指定的限制，其余保持不变。
+func updateCmd(argv []string) int {
+	var (
+		baseDir       string
+		cpuQuota      int
+		cpuPeriod     int
+		memoryMax     string
+		memoryHigh    string
+		memorySwapMax string
+		memoryLow     string
+		memoryMin     string
+		oomGroup      bool
+		pidsMax       int
+		ioReadBps     stringSliceFlag
+		ioWriteBps    stringSliceFlag
+		ioReadIOPS    stringSliceFlag
+		ioWriteIOPS   stringSliceFlag
+		ioWeight      int
+		cpusetCPUs    string
+		cpusetMems    string
+	)
+	fs := flag.NewFlagSet("ai-sandbox update", flag.ExitOnError)
+	fs.StringVar(&baseDir, "base-dir", sandbox.DefaultCgroupsConfig().BaseDir, "cgroup2 mount point")
+	fs.IntVar(&cpuQuota, "cpu-quota", 0, "CPU quota in microseconds per period (0=unlimited)")
+	fs.IntVar(&cpuPeriod, "cpu-period", 100000, "CPU period in microseconds")
+	fs.StringVar(&memoryMax, "memory-max", "0", "memory limit (supports k/m/g suffixes, 0=unlimited)")
+	fs.StringVar(&memoryHigh, "memory-high", "0", "soft memory limit (0=unset)")
+	fs.StringVar(&memorySwapMax, "memory-swap-max", "max", "swap limit (0=no swap, max=inherit from parent)")
+	fs.StringVar(&memoryLow, "memory-low", "0", "best-effort memory protection from reclaim (0=unset)")
+	fs.StringVar(&memoryMin, "memory-min", "0", "hard memory protection from reclaim (0=unset)")
+	fs.BoolVar(&oomGroup, "oom-group", false, "on OOM, kill the whole sandbox instead of only the chosen process")
+	fs.IntVar(&pidsMax, "pids-max", 0, "maximum number of processes (0=unlimited)")
+	fs.Var(&ioReadBps, "io-read-bps", "read bandwidth limit: device:rate (repeatable)")
+	fs.Var(&ioWriteBps, "io-write-bps", "write bandwidth limit: device:rate (repeatable)")
+	fs.Var(&ioReadIOPS, "io-read-iops", "read IOPS limit: device:iops (repeatable)")
+	fs.Var(&ioWriteIOPS, "io-write-iops", "write IOPS limit: device:iops (repeatable)")
+	fs.IntVar(&ioWeight, "io-weight", 0, "relative IO weight 1-10000 (0=kernel default)")
+	fs.StringVar(&cpusetCPUs, "cpuset-cpus", "", "pin the sandbox to these CPUs, e.g. 0-3 (empty=inherit)")
+	fs.StringVar(&cpusetMems, "cpuset-mems", "", "restrict memory allocation to these NUMA nodes (empty=inherit)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ai-sandbox update [options] <id>")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Example:")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox update --memory-max 2g --cpu-quota 200000 1a2b3c4d")
+	}
+	fs.Parse(argv)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return ExitFailure
+	}
+
+	dir, id := sandboxCgroupDir(baseDir, fs.Arg(0))
+	cg, err := sandbox.OpenCgroupsV2(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %s: %v\n", id, err)
+		return ExitFailure
+	}
+
+	// 以当前限制为基础，只覆盖显式指定的 flag
+	cfg := cg.Config()
+	var parseErr error
+	size := func(name, value string, dst *int64) {
+		if parseErr != nil {
+			return
+		}
+		if *dst, parseErr = parseMemorySize(value); parseErr != nil {
+			parseErr = fmt.Errorf("invalid --%s %q: %v", name, value, parseErr)
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "cpu-quota":
+			cfg.CPUQuota = cpuQuota
+		case "cpu-period":
+			cfg.CPUPeriod = cpuPeriod
+		case "memory-max":
+			size(f.Name, memoryMax, &cfg.MemoryMax)
+		case "memory-high":
+			size(f.Name, memoryHigh, &cfg.MemoryHigh)
+		case "memory-low":
+			size(f.Name, memoryLow, &cfg.MemoryLow)
+		case "memory-min":
+			size(f.Name, memoryMin, &cfg.MemoryMin)
+		case "memory-swap-max":
+			if parseErr == nil {
+				if cfg.MemorySwapMax, parseErr = parseSwapMax(memorySwapMax); parseErr != nil {
+					parseErr = fmt.Errorf("invalid --memory-swap-max %q: %v", memorySwapMax, parseErr)
+				}
+			}
+		case "oom-group":
+			cfg.MemoryOOMGroup = oomGroup
+		case "pids-max":
+			cfg.PidsMax = pidsMax
+		case "io-weight":
+			cfg.IOWeight = ioWeight
+		case "cpuset-cpus":
+			cfg.CPUSetCPUs = cpusetCPUs
+		case "cpuset-mems":
+			cfg.CPUSetMems = cpusetMems
+		}
+	})
+	if parseErr != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", parseErr)
+		return ExitFailure
+	}
+	if cfg.IOLimits, err = applyIOLimitFlags(cfg.IOLimits, ioReadBps, ioWriteBps, ioReadIOPS, ioWriteIOPS); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return ExitFailure
+	}
+
+	if err := cg.Update(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %s: %v\n", id, err)
+		return ExitFailure
+	}
+	fmt.Println(id)
+	return ExitSuccess
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"aisandbox/pkg/sandbox"
+)
+
+// usageGroup 是 usage 报告中一个分组的累计用量。
+type usageGroup struct {
+	Group            string  `json:"group"`
+	Runs             int     `json:"runs"`
+	WallSeconds      float64 `json:"wall_seconds"`
+	CPUSeconds       float64 `json:"cpu_seconds"`
+	MemoryGBSeconds  float64 `json:"memory_gb_seconds"`
+	MemoryPeakBytes  uint64  `json:"memory_peak_bytes"` // 组内单次运行的最大峰值
+	IOReadBytes      uint64  `json:"io_read_bytes"`
+	IOWriteBytes     uint64  `json:"io_write_bytes"`
+	NetRxBytes       uint64  `json:"net_rx_bytes"`
+	NetTxBytes       uint64  `json:"net_tx_bytes"`
+	OverlayPeakBytes uint64  `json:"overlay_peak_bytes"` // 组内单次运行的最大峰值
+}
+
+// usageCmd 实现 `ai-sandbox usage`：汇总 run 写入日志目录的资源记账账本。
+func usageCmd(argv []string) int {
+	var (
+		logDir  string
+		since   string
+		until   string
+		groupBy string
+		jsonOut bool
+	)
+	fs := flag.NewFlagSet("ai-sandbox usage", flag.ExitOnError)
+	fs.StringVar(&logDir, "log-dir", "/var/log/ai-sandbox", "log directory containing "+sandbox.LedgerFileName)
+	fs.StringVar(&since, "since", "", "only runs that ended after this time: a duration ago (24h, 7d), a date (2006-01-02) or RFC3339")
+	fs.StringVar(&until, "until", "", "only runs that ended before this time (same formats as --since)")
+	fs.StringVar(&groupBy, "group-by", "", "label key to group by, e.g. team (default: one row per sandbox)")
+	fs.BoolVar(&jsonOut, "json", false, "print one JSON object per group instead of a table")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ai-sandbox usage [options]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Example:")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox --label team=search python agent.py")
+		fmt.Fprintln(os.Stderr, "  ai-sandbox usage --since 7d --group-by team")
+	}
+	fs.Parse(argv)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return ExitFailure
+	}
+	now := time.Now()
+	sinceT, err := parseReportTime(since, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: invalid --since: %v\n", err)
+		return ExitFailure
+	}
+	untilT, err := parseReportTime(until, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: invalid --until: %v\n", err)
+		return ExitFailure
+	}
+
+	records, err := sandbox.ReadUsageRecords(filepath.Join(logDir, sandbox.LedgerFileName), sinceT, untilT)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return ExitFailure
+	}
+	groups := groupUsage(records, groupBy)
+
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		for _, g := range groups {
+			enc.Encode(g)
+		}
+		return ExitSuccess
+	}
+
+	header := "ID"
+	if groupBy != "" {
+		header = strings.ToUpper(groupBy)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tRUNS\tWALL\tCPU SEC\tMEM GB-SEC\tMEM PEAK\tIO READ\tIO WRITE\tNET RX\tNET TX\tOVERLAY PEAK\n", header)
+	for _, g := range groups {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%.1f\t%.2f\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			g.Group, g.Runs,
+			(time.Duration(g.WallSeconds) * time.Second).String(),
+			g.CPUSeconds, g.MemoryGBSeconds, formatBytes(g.MemoryPeakBytes),
+			formatBytes(g.IOReadBytes), formatBytes(g.IOWriteBytes),
+			formatBytes(g.NetRxBytes), formatBytes(g.NetTxBytes),
+			formatBytes(g.OverlayPeakBytes))
+	}
+	tw.Flush()
+	return ExitSuccess
+}
+
+// groupUsage 按标签 key 汇总记录，key 为空时按沙箱 ID 汇总。没有该标签的记录归入 "-"。
+// 结果按组名排序。
+func groupUsage(records []sandbox.UsageRecord, key string) []*usageGroup {
+	byName := make(map[string]*usageGroup)
+	for _, r := range records {
+		name := r.ID
+		if key != "" {
+			name = r.Labels[key]
+			if name == "" {
+				name = "-"
+			}
+		}
+		g := byName[name]
+		if g == nil {
+			g = &usageGroup{Group: name}
+			byName[name] = g
+		}
+		g.Runs++
+		g.WallSeconds += r.WallSeconds
+		g.CPUSeconds += r.CPUSeconds
+		g.MemoryGBSeconds += r.MemoryGBSeconds
+		g.MemoryPeakBytes = max(g.MemoryPeakBytes, r.MemoryPeakBytes)
+		g.IOReadBytes += r.IOReadBytes
+		g.IOWriteBytes += r.IOWriteBytes
+		g.NetRxBytes += r.NetRxBytes
+		g.NetTxBytes += r.NetTxBytes
+		g.OverlayPeakBytes = max(g.OverlayPeakBytes, r.OverlayPeakBytes)
+	}
+	groups := make([]*usageGroup, 0, len(byName))
+	for _, g := range byName {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Group < groups[j].Group })
+	return groups
+}
+
+// parseReportTime 解析 --since/--until：相对 now 的时长（"24h"、"7d"）、日期（本地时区）或 RFC3339。
+// 空字符串返回零值（不限制）。
+func parseReportTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a duration (24h, 7d), date (2006-01-02) or RFC3339 time", s)
+}
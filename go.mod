@@ -2,9 +2,9 @@ module aisandbox
 
 go 1.24.4
 
-require go.uber.org/zap v1.27.1
-
 require (
-	go.uber.org/multierr v1.10.0 // indirect
-	golang.org/x/sys v0.41.0 // indirect
+	go.uber.org/zap v1.27.1
+	golang.org/x/sys v0.41.0
 )
+
+require go.uber.org/multierr v1.10.0 // indirect
@@ -0,0 +1,330 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LedgerFileName 是日志目录下资源记账账本的文件名。
+const LedgerFileName = "usage.jsonl"
+
+// AccountingConfig 定义资源记账配置。
+type AccountingConfig struct {
+	Enabled    bool              // 是否启用
+	LedgerPath string            // JSONL 账本路径，为空时只填充 ExecResult.Usage 不落盘
+	ID         string            // 记录的沙箱 ID，为空时使用 cgroup ID
+	Labels     map[string]string // 调用方提供的标签（团队、工作流等），用于按标签汇总计费
+	Interval   time.Duration     // 内存和网络的采样间隔，默认 1s
+}
+
+// DefaultAccountingConfig 返回默认配置：账本位于 logDir/usage.jsonl，每秒采样一次。
+func DefaultAccountingConfig(logDir string) AccountingConfig {
+	if logDir == "" {
+		logDir = DefaultLogConfig().Dir
+	}
+	return AccountingConfig{
+		Enabled:    true,
+		LedgerPath: filepath.Join(logDir, LedgerFileName),
+		Interval:   time.Second,
+	}
+}
+
+// UsageRecord 是一次沙箱运行的资源记账记录，即账本中的一行。
+type UsageRecord struct {
+	ID     string            `json:"id"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Start  time.Time         `json:"start"`
+	End    time.Time         `json:"end"`
+
+	WallSeconds      float64 `json:"wall_seconds"`
+	CPUSeconds       float64 `json:"cpu_seconds"`        // 用户态 + 内核态 CPU 时间
+	MemoryGBSeconds  float64 `json:"memory_gb_seconds"`  // 内存用量（v2 memory.current，v1 memory.usage_in_bytes）按采样间隔对时间的积分
+	MemoryPeakBytes  uint64  `json:"memory_peak_bytes"`  // 内存峰值
+	IOReadBytes      uint64  `json:"io_read_bytes"`      // 块设备读取字节数
+	IOWriteBytes     uint64  `json:"io_write_bytes"`     // 块设备写入字节数
+	NetRxBytes       uint64  `json:"net_rx_bytes"`       // 沙箱网络命名空间中除 lo 外的接收字节数
+	NetTxBytes       uint64  `json:"net_tx_bytes"`       // 沙箱网络命名空间中除 lo 外的发送字节数
+	OverlayPeakBytes uint64  `json:"overlay_peak_bytes"` // 写入沙箱文件系统（overlay 上层）的峰值字节数
+	ExitCode         int     `json:"exit_code"`
+
+	Termination TerminationReason `json:"termination,omitempty"` // 结束原因，例如 CPU 时间预算耗尽
+}
+
+// SetAccounting 设置资源记账。
+// 必须在Start()之前调用。运行期间按 Interval 采样内存和网络用量，Wait() 在进程退出后
+// 生成 UsageRecord 写入 ExecResult.Usage，并追加到账本。
+func (ns *Namespace) SetAccounting(cfg *AccountingConfig) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.accountingConfig = cfg
+}
+
+// usageMeter 在运行期间采样需要积分或会随进程退出消失的计数器。
+type usageMeter struct {
+	cgroupDir string            // cgroup v2 目录
+	v1Dirs    map[string]string // cgroup v1 控制器 → 目录；两者都为空时不采样内存
+	pid       int
+	netns     bool // 沙箱有独立的网络命名空间时才统计网络流量
+
+	mu        sync.Mutex
+	start     time.Time
+	last      time.Time
+	lastMem   uint64
+	memBytesS float64 // 字节·秒
+	memPeak   uint64
+	netRx     uint64
+	netTx     uint64
+}
+
+func newUsageMeter(cgroupDir string, v1Dirs map[string]string, pid int, netns bool) *usageMeter {
+	now := time.Now()
+	m := &usageMeter{cgroupDir: cgroupDir, v1Dirs: v1Dirs, pid: pid, netns: netns, start: now, last: now}
+	m.sample(now)
+	return m
+}
+
+// run 每隔 interval 采样一次，直到 done 被关闭。
+func (m *usageMeter) run(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			m.sample(now)
+		}
+	}
+}
+
+// sample 将上一个采样值按经过的时间累加到内存积分，并更新网络计数。
+// 进程退出后网络命名空间随之销毁，最后一个采样间隔内的流量不计入。
+func (m *usageMeter) sample(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.memBytesS += float64(m.lastMem) * now.Sub(m.last).Seconds()
+	m.last = now
+	if memFile := m.memoryFile(); memFile != "" {
+		if cur, err := readUintFile(memFile); err == nil {
+			m.lastMem = cur
+			if cur > m.memPeak {
+				m.memPeak = cur
+			}
+		}
+	}
+
+	if m.netns {
+		if rx, tx, err := readNetDev(fmt.Sprintf("/proc/%d/net/dev", m.pid)); err == nil {
+			m.netRx, m.netTx = rx, tx
+		}
+	}
+}
+
+// memoryFile 返回当前内存用量的控制文件，没有 cgroup 时返回空。
+func (m *usageMeter) memoryFile() string {
+	if m.cgroupDir != "" {
+		return filepath.Join(m.cgroupDir, "memory.current")
+	}
+	if dir := m.v1Dirs["memory"]; dir != "" {
+		return filepath.Join(dir, "memory.usage_in_bytes")
+	}
+	return ""
+}
+
+// record 生成记账记录。cgroup 统计在进程退出后、Cleanup 之前读取；
+// cgroup v1 上 CPU 时间和内存峰值来自 cpuacct.usage 和 memory.max_usage_in_bytes（没有 I/O 统计）。
+// 两者都不可用时退回到 init 进程（含已回收的子进程）的 rusage，不包含脱离 init 的后台进程。
+func (m *usageMeter) record(state *os.ProcessState) UsageRecord {
+	end := time.Now()
+	m.sample(end)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := UsageRecord{
+		Start:           m.start,
+		End:             end,
+		WallSeconds:     end.Sub(m.start).Seconds(),
+		MemoryGBSeconds: m.memBytesS / (1 << 30),
+		MemoryPeakBytes: m.memPeak,
+		NetRxBytes:      m.netRx,
+		NetTxBytes:      m.netTx,
+	}
+	if state != nil {
+		r.ExitCode = state.ExitCode()
+	}
+
+	if m.cgroupDir != "" {
+		// 个别统计文件不可用（控制器未启用）时使用其余统计
+		if s, _ := ReadCgroupStats(m.cgroupDir); s != nil {
+			r.CPUSeconds = float64(s.CPU.UsageUsec) / 1e6
+			if s.Memory.Peak > r.MemoryPeakBytes {
+				r.MemoryPeakBytes = s.Memory.Peak
+			}
+			for _, d := range s.IO {
+				r.IOReadBytes += d.RBytes
+				r.IOWriteBytes += d.WBytes
+			}
+			return r
+		}
+	}
+	if dir := m.v1Dirs["cpuacct"]; dir != "" {
+		if usage, err := readUintFile(filepath.Join(dir, "cpuacct.usage")); err == nil {
+			r.CPUSeconds = float64(usage) / 1e9
+			if dir := m.v1Dirs["memory"]; dir != "" {
+				if peak, err := readUintFile(filepath.Join(dir, "memory.max_usage_in_bytes")); err == nil && peak > r.MemoryPeakBytes {
+					r.MemoryPeakBytes = peak
+				}
+			}
+			return r
+		}
+	}
+	if state != nil {
+		if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+			r.CPUSeconds = time.Duration(syscall.TimevalToNsec(ru.Utime) + syscall.TimevalToNsec(ru.Stime)).Seconds()
+			if peak := uint64(ru.Maxrss) * 1024; peak > r.MemoryPeakBytes {
+				r.MemoryPeakBytes = peak
+			}
+		}
+	}
+	return r
+}
+
+// recordUsage 生成记账记录写入 result，并追加到账本。
+// 账本写入失败只记录警告，不影响运行结果（记录仍在 ExecResult.Usage 中）。
+func (ns *Namespace) recordUsage(result *ExecResult, state *os.ProcessState) {
+	ns.mu.Lock()
+	cfg, meter, cg, ov, logger := ns.accountingConfig, ns.meter, ns.cgroups, ns.overlayFS, ns.logger
+	ns.mu.Unlock()
+
+	if cfg == nil || !cfg.Enabled || meter == nil {
+		return
+	}
+
+	r := meter.record(state)
+	r.ID = cfg.ID
+	if r.ID == "" && cg != nil {
+		r.ID = cg.ID()
+	}
+	if r.ID == "" {
+		r.ID = generateID()
+	}
+	r.Labels = cfg.Labels
+	r.Termination = result.Termination
+	if ov != nil {
+		r.OverlayPeakBytes = ov.PeakUsage().BytesUsed
+	}
+	result.Usage = &r
+
+	if logger != nil {
+		logger.Info("sandbox usage",
+			zap.String("id", r.ID),
+			zap.Float64("wall_seconds", r.WallSeconds),
+			zap.Float64("cpu_seconds", r.CPUSeconds),
+			zap.Float64("memory_gb_seconds", r.MemoryGBSeconds),
+			zap.Uint64("memory_peak_bytes", r.MemoryPeakBytes),
+		)
+	}
+
+	if cfg.LedgerPath == "" {
+		return
+	}
+	if err := AppendUsageRecord(cfg.LedgerPath, r); err != nil && logger != nil {
+		logger.Warn("usage record not written", zap.String("id", r.ID), zap.Error(err))
+	}
+}
+
+// AppendUsageRecord 将一条记录追加到 JSONL 账本。
+// 每条记录以单次 O_APPEND 写入，多个沙箱进程并发追加时行不会交错。
+func AppendUsageRecord(path string, r UsageRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("accounting: marshal: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("accounting: mkdir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("accounting: open %s: %w", path, err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("accounting: write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadUsageRecords 读取账本中结束时间位于 [since, until) 的记录，零值表示不限制。
+// 无法解析的行（例如写入中断留下的半行）被跳过。
+func ReadUsageRecords(path string, since, until time.Time) ([]UsageRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("accounting: %w", err)
+	}
+	defer f.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r UsageRecord
+		if json.Unmarshal(scanner.Bytes(), &r) != nil {
+			continue
+		}
+		if (!since.IsZero() && r.End.Before(since)) || (!until.IsZero() && !r.End.Before(until)) {
+			continue
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("accounting: read %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// readNetDev 解析 /proc/<pid>/net/dev，返回除 lo 外所有接口的接收/发送字节数之和：
+//
+//	Inter-|   Receive                            |  Transmit
+//	 face |bytes    packets errs drop ...        |bytes    packets ...
+//	  eth0: 1234    10      0    0    ...         5678     12 ...
+func readNetDev(path string) (rx, tx uint64, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 9 {
+			continue
+		}
+		r, err1 := strconv.ParseUint(fields[0], 10, 64)
+		t, err2 := strconv.ParseUint(fields[8], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		rx += r
+		tx += t
+	}
+	return rx, tx, nil
+}
@@ -0,0 +1,216 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ===================================================================
+// 资源记账单元测试（不需要 root）
+// ===================================================================
+
+func TestReadNetDev(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dev")
+	data := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  999999      10    0    0    0     0          0         0   999999      10    0    0    0     0       0          0
+  eth0:    1000       5    0    0    0     0          0         0      300       3    0    0    0     0       0          0
+ veth1:      24       1    0    0    0     0          0         0       76       1    0    0    0     0       0          0
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rx, tx, err := readNetDev(path)
+	if err != nil {
+		t.Fatalf("readNetDev failed: %v", err)
+	}
+	if rx != 1024 || tx != 376 {
+		t.Errorf("readNetDev = %d/%d, want 1024/376 (lo excluded)", rx, tx)
+	}
+
+	if _, _, err := readNetDev(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestUsageLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", LedgerFileName)
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		r := UsageRecord{
+			ID:         id,
+			Labels:     map[string]string{"team": "search"},
+			Start:      base.Add(time.Duration(i) * time.Hour),
+			End:        base.Add(time.Duration(i)*time.Hour + time.Minute),
+			CPUSeconds: float64(i),
+		}
+		if err := AppendUsageRecord(path, r); err != nil {
+			t.Fatalf("AppendUsageRecord failed: %v", err)
+		}
+	}
+	// 写入中断留下的半行应被跳过
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"trunc`)
+	f.Close()
+
+	all, err := ReadUsageRecords(path, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadUsageRecords failed: %v", err)
+	}
+	if len(all) != 3 || all[0].ID != "a" || all[2].Labels["team"] != "search" || all[2].CPUSeconds != 2 {
+		t.Errorf("unexpected records: %+v", all)
+	}
+
+	// [since, until) 按结束时间过滤
+	some, err := ReadUsageRecords(path, base.Add(time.Hour+time.Minute), base.Add(2*time.Hour+time.Minute))
+	if err != nil {
+		t.Fatalf("ReadUsageRecords failed: %v", err)
+	}
+	if len(some) != 1 || some[0].ID != "b" {
+		t.Errorf("filtered records = %+v, want [b]", some)
+	}
+
+	if _, err := ReadUsageRecords(filepath.Join(t.TempDir(), "missing"), time.Time{}, time.Time{}); err == nil {
+		t.Error("expected error for missing ledger")
+	}
+}
+
+func TestUsageMeterMemoryIntegral(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "memory.current"), []byte("1073741824\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newUsageMeter(dir, nil, 0, false)
+	m.sample(m.start.Add(2 * time.Second))
+	os.WriteFile(filepath.Join(dir, "memory.current"), []byte("3221225472\n"), 0644)
+	m.sample(m.start.Add(3 * time.Second))
+	m.sample(m.start.Add(4 * time.Second))
+
+	// 1GiB × 2s + 1GiB × 1s（第二个采样点之前仍按旧值）+ 3GiB × 1s
+	m.mu.Lock()
+	gbs, peak := m.memBytesS/(1<<30), m.memPeak
+	m.mu.Unlock()
+	if gbs < 5.99 || gbs > 6.01 {
+		t.Errorf("memory GB-seconds = %f, want 6", gbs)
+	}
+	if peak != 3<<30 {
+		t.Errorf("memory peak = %d, want %d", peak, uint64(3<<30))
+	}
+}
+
+func TestUsageMeterCgroupV1(t *testing.T) {
+	memDir, cpuDir := t.TempDir(), t.TempDir()
+	for file, content := range map[string]string{
+		filepath.Join(memDir, "memory.usage_in_bytes"):     "1073741824\n",
+		filepath.Join(memDir, "memory.max_usage_in_bytes"): "4294967296\n",
+		filepath.Join(cpuDir, "cpuacct.usage"):             "2500000000\n",
+	} {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := newUsageMeter("", map[string]string{"memory": memDir, "cpuacct": cpuDir}, 0, false)
+	m.start = m.start.Add(-2 * time.Second)
+	m.last = m.start
+	r := m.record(nil)
+	if r.MemoryGBSeconds < 1.99 {
+		t.Errorf("memory GB-seconds = %f, want >= 2", r.MemoryGBSeconds)
+	}
+	if r.MemoryPeakBytes != 4<<30 {
+		t.Errorf("memory peak = %d, want %d", r.MemoryPeakBytes, uint64(4<<30))
+	}
+	if r.CPUSeconds != 2.5 {
+		t.Errorf("cpu seconds = %f, want 2.5", r.CPUSeconds)
+	}
+}
+
+func TestDefaultAccountingConfig(t *testing.T) {
+	cfg := DefaultAccountingConfig("/tmp/logs")
+	if !cfg.Enabled || cfg.LedgerPath != "/tmp/logs/usage.jsonl" || cfg.Interval != time.Second {
+		t.Errorf("unexpected default config: %+v", cfg)
+	}
+}
+
+// ===================================================================
+// 资源记账集成测试（需要 root）
+// ===================================================================
+
+func TestAccountingInResult(t *testing.T) {
+	base := cgroup2Mount(t)
+
+	cg := NewCgroupsV2(CgroupsConfig{Enabled: true, BaseDir: base})
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	ledger := filepath.Join(t.TempDir(), LedgerFileName)
+	ns := NewNamespace(NamespaceConfig{Mount: true})
+	ns.SetCgroupsV2(cg)
+	ns.SetAccounting(&AccountingConfig{
+		Enabled:    true,
+		LedgerPath: ledger,
+		Labels:     map[string]string{"team": "search"},
+		Interval:   100 * time.Millisecond,
+	})
+	defer ns.Cleanup()
+	ns.Stdout, ns.Stderr = nil, nil
+
+	result, err := ns.Execute("sh", "-c", "sleep 0.3; exit 3")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	u := result.Usage
+	if u == nil {
+		t.Fatal("result.Usage is nil")
+	}
+	if u.ID != cg.ID() || u.Labels["team"] != "search" || u.ExitCode != 3 {
+		t.Errorf("unexpected usage record: %+v", u)
+	}
+	if u.WallSeconds < 0.3 || !u.End.After(u.Start) {
+		t.Errorf("wall time %f too short (start %v, end %v)", u.WallSeconds, u.Start, u.End)
+	}
+
+	records, err := ReadUsageRecords(ledger, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadUsageRecords failed: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != u.ID || records[0].ExitCode != 3 {
+		t.Errorf("ledger records = %+v", records)
+	}
+}
+
+func TestAccountingCgroupV1(t *testing.T) {
+	skipIfNoCgroupsV1(t)
+
+	cg := NewCgroupsV1(CgroupsConfig{Enabled: true})
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	ns := NewNamespace(NamespaceConfig{Mount: true})
+	ns.SetCgroups(cg)
+	ns.SetAccounting(&AccountingConfig{Enabled: true, Interval: 50 * time.Millisecond})
+	defer ns.Cleanup()
+	ns.Stdout, ns.Stderr = nil, nil
+
+	result, err := ns.Execute("sh", "-c", "i=0; while [ $i -lt 100000 ]; do i=$((i+1)); done")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	u := result.Usage
+	if u == nil {
+		t.Fatal("result.Usage is nil")
+	}
+	if u.CPUSeconds <= 0 || u.MemoryPeakBytes == 0 || u.MemoryGBSeconds <= 0 {
+		t.Errorf("expected cgroup v1 usage, got %+v", u)
+	}
+}
@@ -0,0 +1,480 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// ArtifactConfig 定义沙箱结束后从 OverlayFS 收集输出文件的配置。
+//
+// 收集发生在进程退出之后、Cleanup() 销毁 tmpfs 之前。
+// 此时 overlay 合并挂载点只存在于已退出的子进程 Mount Namespace 中，
+// 因此父进程直接按 overlay 语义（上层优先、whiteout、opaque 目录）遍历各层目录。
+type ArtifactConfig struct {
+	Patterns      []string  // 沙箱内路径的 glob 模式，支持 "**" 匹配任意层目录，如 "out/**/*.png"
+	BaseDir       string    // 相对模式的基准目录（沙箱内路径），默认 "/"
+	DestDir       string    // 宿主机目标目录（与 TarWriter 二选一）
+	TarWriter     io.Writer // 输出 tar 流（与 DestDir 二选一）
+	UpperOnly     bool      // 仅收集沙箱内新建或修改过的文件（上层），忽略下层原有文件
+	MaxFiles      int       // 最多收集的文件数，0 表示默认 1000
+	MaxTotalBytes int64     // 收集的总字节数上限，0 表示默认 64MB
+	MaxFileBytes  int64     // 单个文件字节数上限，0 表示不单独限制
+}
+
+// DefaultArtifactConfig 返回默认的收集配置：输出到 destDir，1000 个文件、64MB 上限。
+func DefaultArtifactConfig(destDir string, patterns ...string) ArtifactConfig {
+	return ArtifactConfig{
+		Patterns:      patterns,
+		BaseDir:       "/",
+		DestDir:       destDir,
+		MaxFiles:      defaultArtifactMaxFiles,
+		MaxTotalBytes: defaultArtifactMaxTotalBytes,
+	}
+}
+
+const (
+	defaultArtifactMaxFiles      = 1000
+	defaultArtifactMaxTotalBytes = 64 * 1024 * 1024
+)
+
+// Artifact 描述一个被收集的输出文件。
+type Artifact struct {
+	Path string      // 沙箱内的绝对路径
+	Size int64       // 文件大小（字节）
+	Mode os.FileMode // 权限位
+}
+
+// ArtifactResult 是一次收集的结果。
+type ArtifactResult struct {
+	Files     []Artifact // 已收集的文件（按路径排序）
+	Skipped   []string   // 因符号链接、特殊文件或单文件上限被跳过的路径
+	Truncated bool       // 是否因数量或总大小上限提前停止
+}
+
+// mergedNode 是 overlay 合并视图中的一个节点，记录它在哪些层中存在。
+// 目录可能跨越多层（按优先级排序），非目录只来自最上面的一层。
+type mergedNode struct {
+	path   string   // 沙箱内绝对路径
+	layers []string // 该节点所在的层目录（高优先级在前）
+	fi     os.FileInfo
+}
+
+// CollectArtifacts 按 glob 模式从沙箱文件系统中收集文件到宿主机目录或 tar 流。
+//
+// 安全约束：
+//   - 从不跟随符号链接：匹配到的符号链接被跳过，遍历时也不会进入符号链接目录
+//   - 文件通过 openat2(RESOLVE_BENEATH|RESOLVE_NO_SYMLINKS) 打开，防止竞争条件下的路径替换
+//   - 只收集普通文件；设备、FIFO、socket 被跳过
+//   - 数量与总大小超限时停止收集并标记 Truncated
+func (ov *OverlayFS) CollectArtifacts(cfg ArtifactConfig) (*ArtifactResult, error) {
+	ov.mu.Lock()
+	defer ov.mu.Unlock()
+
+	if !ov.setupDone {
+		return nil, fmt.Errorf("overlayfs: not set up")
+	}
+	if (cfg.DestDir == "") == (cfg.TarWriter == nil) {
+		return nil, fmt.Errorf("overlayfs: collect: exactly one of DestDir and TarWriter must be set")
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = defaultArtifactMaxFiles
+	}
+	if cfg.MaxTotalBytes <= 0 {
+		cfg.MaxTotalBytes = defaultArtifactMaxTotalBytes
+	}
+	baseDir := cfg.BaseDir
+	if baseDir == "" {
+		baseDir = "/"
+	}
+
+	var layers []string
+	if !ov.config.ReadOnly {
+		layers = append(layers, ov.upperDir)
+	}
+	if !cfg.UpperOnly {
+		layers = append(layers, ov.config.LowerDirs...)
+	}
+
+	// 收集所有模式的匹配结果并去重
+	matched := make(map[string]*mergedNode)
+	for _, p := range cfg.Patterns {
+		if !path.IsAbs(p) {
+			p = path.Join(baseDir, p)
+		}
+		if _, err := path.Match(strings.ReplaceAll(p, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("overlayfs: collect: bad pattern %q: %w", p, err)
+		}
+		if err := walkMerged(layers, path.Clean(p), matched); err != nil {
+			return nil, fmt.Errorf("overlayfs: collect %q: %w", p, err)
+		}
+	}
+
+	paths := make([]string, 0, len(matched))
+	for p := range matched {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var tw *tar.Writer
+	if cfg.TarWriter != nil {
+		tw = tar.NewWriter(cfg.TarWriter)
+	}
+
+	result := &ArtifactResult{}
+	var total int64
+	for _, p := range paths {
+		node := matched[p]
+		if !node.fi.Mode().IsRegular() {
+			result.Skipped = append(result.Skipped, p)
+			continue
+		}
+		size := node.fi.Size()
+		if cfg.MaxFileBytes > 0 && size > cfg.MaxFileBytes {
+			result.Skipped = append(result.Skipped, p)
+			continue
+		}
+		if len(result.Files) >= cfg.MaxFiles || total+size > cfg.MaxTotalBytes {
+			result.Truncated = true
+			break
+		}
+
+		// 遍历时的大小已通过上面的检查；存活的后台进程可能在此之后让文件变大或替换它，
+		// 复制量以此为上限，不能按重新 stat 的大小复制
+		limit := min(size, cfg.MaxTotalBytes-total)
+		if cfg.MaxFileBytes > 0 {
+			limit = min(limit, cfg.MaxFileBytes)
+		}
+		n, grew, err := copyArtifact(node, &cfg, tw, limit)
+		if err != nil {
+			return result, fmt.Errorf("overlayfs: collect %s: %w", p, err)
+		}
+		if grew {
+			result.Truncated = true
+		}
+		total += n
+		result.Files = append(result.Files, Artifact{
+			Path: p,
+			Size: n,
+			Mode: node.fi.Mode() & os.ModePerm,
+		})
+	}
+
+	if tw != nil {
+		if err := tw.Close(); err != nil {
+			return result, fmt.Errorf("overlayfs: collect: close tar: %w", err)
+		}
+	}
+
+	if ov.logger != nil {
+		ov.logger.Info("overlay collect artifacts",
+			zap.String("overlay_id", ov.id),
+			zap.Strings("patterns", cfg.Patterns),
+			zap.Int("files", len(result.Files)),
+			zap.Int64("bytes", total),
+			zap.Int("skipped", len(result.Skipped)),
+			zap.Bool("truncated", result.Truncated),
+		)
+	}
+	return result, nil
+}
+
+// walkMerged 在合并视图中查找匹配 pattern 的所有节点并加入 matched。
+// 遍历从模式中不含通配符的最长前缀目录开始，避免扫描整个下层（通常是宿主机根目录）。
+func walkMerged(layers []string, pattern string, matched map[string]*mergedNode) error {
+	segs := splitPath(pattern)
+
+	// 定位字面前缀目录
+	root := &mergedNode{path: "/", layers: layers}
+	i := 0
+	for ; i < len(segs)-1 && !hasGlobMeta(segs[i]); i++ {
+		child, err := lookupMerged(root, segs[i])
+		if err != nil || child == nil || !child.fi.IsDir() {
+			return err
+		}
+		root = child
+	}
+
+	return walkMergedDir(root, segs[i:], nil, matched)
+}
+
+// walkMergedDir 递归遍历 dir，rest 是尚未匹配的模式分量，rel 是相对 dir 起点已走过的路径分量。
+func walkMergedDir(dir *mergedNode, rest []string, rel []string, matched map[string]*mergedNode) error {
+	names, err := readMergedDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		child, err := lookupMerged(dir, name)
+		if err != nil {
+			return err
+		}
+		if child == nil {
+			continue
+		}
+		childRel := append(append([]string(nil), rel...), name)
+
+		if matchSegments(rest, childRel) {
+			matched[child.path] = child
+		}
+		// 符号链接从不进入；只有可能匹配更深路径的目录才继续遍历
+		if child.fi.IsDir() && matchPrefix(rest, childRel) {
+			if err := walkMergedDir(child, rest, childRel, matched); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookupMerged 按 overlay 语义在 dir 下查找 name：
+//   - 依次在 dir 所在的各层中 lstat
+//   - whiteout（0:0 字符设备）隐藏更低层的同名条目
+//   - 非目录只取最上面一层；目录合并各层，遇到 opaque 目录停止向下合并
+//
+// 条目不存在时返回 nil, nil。
+func lookupMerged(dir *mergedNode, name string) (*mergedNode, error) {
+	node := &mergedNode{path: path.Join(dir.path, name)}
+
+	for _, layer := range dir.layers {
+		p := filepath.Join(layer, node.path)
+		fi, err := os.Lstat(p)
+		if err != nil {
+			if os.IsNotExist(err) || isNotDir(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		if isWhiteout(fi) {
+			break
+		}
+		if !fi.IsDir() {
+			if node.fi == nil {
+				node.fi = fi
+				node.layers = []string{layer}
+			}
+			break
+		}
+		if node.fi == nil {
+			node.fi = fi
+		}
+		node.layers = append(node.layers, layer)
+		if isOpaqueDir(p) {
+			break
+		}
+	}
+
+	if node.fi == nil {
+		return nil, nil
+	}
+	return node, nil
+}
+
+// readMergedDir 返回目录在合并视图中的条目名称（排序去重，whiteout 由 lookupMerged 过滤）。
+func readMergedDir(dir *mergedNode) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, layer := range dir.layers {
+		entries, err := os.ReadDir(filepath.Join(layer, dir.path))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				names = append(names, e.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// copyArtifact 将节点对应的文件复制到目标目录或 tar 流，至多复制 limit 字节，
+// 返回复制的字节数以及文件打开时是否已超过 limit（超出部分被截断）。
+// 文件在遍历之后被截短时只复制剩余内容（tar 条目用零补齐到 limit）。
+func copyArtifact(node *mergedNode, cfg *ArtifactConfig, tw *tar.Writer, limit int64) (int64, bool, error) {
+	src, err := openBeneath(node.layers[0], node.path)
+	if err != nil {
+		return 0, false, err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	if !fi.Mode().IsRegular() {
+		return 0, false, fmt.Errorf("not a regular file")
+	}
+	grew := fi.Size() > limit
+	rel := strings.TrimPrefix(node.path, "/")
+	mode := node.fi.Mode() & os.ModePerm
+
+	if tw != nil {
+		hdr := &tar.Header{
+			Name:     rel,
+			Typeflag: tar.TypeReg,
+			Mode:     int64(mode),
+			Size:     limit,
+			ModTime:  fi.ModTime(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return 0, grew, err
+		}
+		n, err := copyPadded(tw, src, limit)
+		return n, grew, err
+	}
+
+	dst := filepath.Join(cfg.DestDir, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, grew, err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|syscall.O_NOFOLLOW, mode)
+	if err != nil {
+		return 0, grew, err
+	}
+	n, err := io.Copy(out, io.LimitReader(src, limit))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return n, grew, err
+}
+
+// copyPadded 从 r 复制至多 size 字节到 w，不足 size 时写入零补齐，返回实际从 r 复制的字节数。
+func copyPadded(w io.Writer, r io.Reader, size int64) (int64, error) {
+	n, err := io.Copy(w, io.LimitReader(r, size))
+	if err != nil {
+		return n, err
+	}
+	if n < size {
+		if _, err := io.CopyN(w, zeroReader{}, size-n); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// zeroReader 是无限输出零字节的 io.Reader。
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// openBeneath 以只读方式打开 root 下的 rel，路径解析过程中拒绝任何符号链接且不允许越过 root。
+func openBeneath(root, rel string) (*os.File, error) {
+	dirfd, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", root, err)
+	}
+	defer unix.Close(dirfd)
+
+	how := &unix.OpenHow{
+		Flags:   unix.O_RDONLY | unix.O_NOFOLLOW | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS | unix.RESOLVE_NO_MAGICLINKS,
+	}
+	fd, err := unix.Openat2(dirfd, strings.TrimPrefix(rel, "/"), how)
+	if err != nil {
+		return nil, fmt.Errorf("openat2 %s: %w", rel, err)
+	}
+	return os.NewFile(uintptr(fd), filepath.Join(root, rel)), nil
+}
+
+// isWhiteout 判断是否为 overlay whiteout（设备号 0:0 的字符设备）。
+func isWhiteout(fi os.FileInfo) bool {
+	if fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && st.Rdev == 0
+}
+
+// isOpaqueDir 判断目录是否带有 overlay opaque 标记（隐藏更低层的同名目录内容）。
+func isOpaqueDir(p string) bool {
+	buf := make([]byte, 1)
+	n, err := unix.Lgetxattr(p, "trusted.overlay.opaque", buf)
+	return err == nil && n == 1 && buf[0] == 'y'
+}
+
+// isNotDir 判断错误是否为 ENOTDIR（路径中间分量不是目录）。
+func isNotDir(err error) bool {
+	if pe, ok := err.(*os.PathError); ok {
+		return pe.Err == syscall.ENOTDIR
+	}
+	return false
+}
+
+// splitPath 将绝对路径拆分为分量（忽略空分量）。
+func splitPath(p string) []string {
+	var segs []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+// hasGlobMeta 判断路径分量是否包含通配符。
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[\\")
+}
+
+// matchSegments 判断路径分量是否完整匹配模式分量。"**" 匹配零个或多个分量。
+func matchSegments(pat, segs []string) bool {
+	if len(pat) == 0 {
+		return len(segs) == 0
+	}
+	if pat[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pat[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	ok, err := path.Match(pat[0], segs[0])
+	if err != nil || !ok {
+		return false
+	}
+	return matchSegments(pat[1:], segs[1:])
+}
+
+// matchPrefix 判断路径分量是否可能是某个匹配路径的前缀（用于剪枝目录遍历）。
+func matchPrefix(pat, segs []string) bool {
+	if len(segs) == 0 {
+		return true
+	}
+	if len(pat) == 0 {
+		return false
+	}
+	if pat[0] == "**" {
+		return true
+	}
+	ok, err := path.Match(pat[0], segs[0])
+	if err != nil || !ok {
+		return false
+	}
+	return matchPrefix(pat[1:], segs[1:])
+}
@@ -0,0 +1,260 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ===================================================================
+// Artifact 单元测试（不需要 root）
+// ===================================================================
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"out/*.png", "out/a.png", true},
+		{"out/*.png", "out/sub/a.png", false},
+		{"out/**/*.png", "out/a.png", true},
+		{"out/**/*.png", "out/x/y/a.png", true},
+		{"out/**/*.png", "out/x/y/a.txt", false},
+		{"**", "any/thing", true},
+		{"out/[ab].txt", "out/b.txt", true},
+	}
+
+	for _, tt := range tests {
+		got := matchSegments(splitPath(tt.pattern), splitPath(tt.path))
+		if got != tt.want {
+			t.Errorf("matchSegments(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPrefix(t *testing.T) {
+	if !matchPrefix(splitPath("out/*/x.txt"), splitPath("out/a")) {
+		t.Error("out/a should be a possible prefix of out/*/x.txt")
+	}
+	if matchPrefix(splitPath("out/*/x.txt"), splitPath("other")) {
+		t.Error("other should not be a possible prefix of out/*/x.txt")
+	}
+	if matchPrefix(splitPath("out/*.txt"), splitPath("out/a/b")) {
+		t.Error("out/a/b is deeper than out/*.txt")
+	}
+}
+
+func TestCopyPadded(t *testing.T) {
+	// 文件在 stat 之后被截短：复制剩余内容并补齐 tar 条目
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "out.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 10})
+	n, err := copyPadded(tw, strings.NewReader("abc"), 10)
+	if err != nil || n != 3 {
+		t.Fatalf("copyPadded = %d, %v, want 3, nil", n, err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar entry not padded to its header size: %v", err)
+	}
+	tr := tar.NewReader(&buf)
+	if _, err := tr.Next(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(tr)
+	if want := "abc\x00\x00\x00\x00\x00\x00\x00"; string(data) != want {
+		t.Errorf("entry = %q, want %q", data, want)
+	}
+
+	// 文件在 stat 之后变长：只复制声明的大小
+	buf.Reset()
+	if n, err := copyPadded(&buf, strings.NewReader("abcdef"), 4); err != nil || n != 4 || buf.String() != "abcd" {
+		t.Errorf("copyPadded = %d, %v, %q, want 4, nil, \"abcd\"", n, err, buf.String())
+	}
+}
+
+func TestCopyArtifactGrownFile(t *testing.T) {
+	layer := t.TempDir()
+	file := filepath.Join(layer, "log.txt")
+	os.WriteFile(file, []byte("abcd"), 0644)
+	fi, err := os.Lstat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 遍历之后文件被后台进程追加：复制量不能超过遍历时检查过的大小
+	os.WriteFile(file, []byte("abcdefghij"), 0644)
+	node := &mergedNode{path: "/log.txt", layers: []string{layer}, fi: fi}
+
+	cfg := DefaultArtifactConfig(t.TempDir())
+	n, grew, err := copyArtifact(node, &cfg, nil, fi.Size())
+	if err != nil || n != 4 || !grew {
+		t.Fatalf("copyArtifact = %d, %v, %v, want 4, true, nil", n, grew, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cfg.DestDir, "log.txt")); string(data) != "abcd" {
+		t.Errorf("dest content = %q, want %q", data, "abcd")
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if n, grew, err := copyArtifact(node, &cfg, tw, fi.Size()); err != nil || n != 4 || !grew {
+		t.Fatalf("copyArtifact (tar) = %d, %v, %v, want 4, true, nil", n, grew, err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	hdr, err := tar.NewReader(&buf).Next()
+	if err != nil || hdr.Size != 4 {
+		t.Errorf("tar header = %+v, %v, want size 4", hdr, err)
+	}
+}
+
+func TestCollectArtifactsValidation(t *testing.T) {
+	ov := NewOverlayFS(DefaultOverlayConfig("/"))
+	if _, err := ov.CollectArtifacts(DefaultArtifactConfig(t.TempDir(), "*")); err == nil {
+		t.Error("expected error before Setup")
+	}
+}
+
+// ===================================================================
+// Artifact 集成测试（需要 root）
+// ===================================================================
+
+// runArtifactSandbox 在 overlay + pivot_root 沙箱中执行脚本并按 acfg 收集输出文件。
+func runArtifactSandbox(t *testing.T, lower, script string, acfg ArtifactConfig) *ExecResult {
+	t.Helper()
+
+	ov := NewOverlayFS(DefaultOverlayConfig(lower))
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("overlay setup: %v", err)
+	}
+
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetOverlayFS(ov)
+	pcfg := DefaultPivotRootConfig()
+	ns.SetPivotRoot(&pcfg)
+	ns.SetArtifacts(&acfg)
+
+	result, err := ns.Execute("sh", "-c", script)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code: %d", result.ExitCode)
+	}
+	if result.Artifacts == nil {
+		t.Fatal("expected artifacts in result")
+	}
+	return result
+}
+
+func TestCollectArtifactsGlob(t *testing.T) {
+	skipIfNotRoot(t)
+
+	dest := t.TempDir()
+	script := "mkdir -p /out/sub && echo a > /out/a.png && echo b > /out/sub/b.png && " +
+		"echo c > /out/c.txt && ln -s /etc/shadow /out/evil.png"
+	result := runArtifactSandbox(t, "/", script, DefaultArtifactConfig(dest, "out/**/*.png"))
+
+	files := result.Artifacts.Files
+	if len(files) != 2 || files[0].Path != "/out/a.png" || files[1].Path != "/out/sub/b.png" {
+		t.Fatalf("unexpected artifacts: %+v", files)
+	}
+	if len(result.Artifacts.Skipped) != 1 || result.Artifacts.Skipped[0] != "/out/evil.png" {
+		t.Errorf("symlink should be skipped, got %v", result.Artifacts.Skipped)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "out", "sub", "b.png"))
+	if err != nil || string(data) != "b\n" {
+		t.Errorf("unexpected collected content: %q, %v", data, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dest, "out", "evil.png")); !os.IsNotExist(err) {
+		t.Error("symlink target must not be collected")
+	}
+}
+
+func TestCollectArtifactsWhiteout(t *testing.T) {
+	skipIfNotRoot(t)
+
+	lower := t.TempDir()
+	os.MkdirAll(filepath.Join(lower, "data"), 0755)
+	os.WriteFile(filepath.Join(lower, "data", "keep.txt"), []byte("keep"), 0644)
+	os.WriteFile(filepath.Join(lower, "data", "gone.txt"), []byte("gone"), 0644)
+
+	// 下层只有测试数据、没有可执行文件，因此不启用 pivot_root
+	ov := NewOverlayFS(DefaultOverlayConfig(lower))
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("overlay setup: %v", err)
+	}
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetOverlayFS(ov)
+	acfg := DefaultArtifactConfig(t.TempDir(), "/data/*.txt")
+	ns.SetArtifacts(&acfg)
+
+	result, err := ns.Execute("rm", filepath.Join(ov.MergeDir(), "data", "gone.txt"))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code: %d", result.ExitCode)
+	}
+
+	files := result.Artifacts.Files
+	if len(files) != 1 || files[0].Path != "/data/keep.txt" {
+		t.Errorf("deleted file should not be collected, got %+v", files)
+	}
+}
+
+func TestCollectArtifactsLimits(t *testing.T) {
+	skipIfNotRoot(t)
+
+	acfg := DefaultArtifactConfig(t.TempDir(), "/out/*")
+	acfg.MaxFiles = 2
+	acfg.MaxFileBytes = 100
+	script := "mkdir /out && for i in 1 2 3; do echo $i > /out/f$i; done && " +
+		"head -c 1000 /dev/zero > /out/big"
+	result := runArtifactSandbox(t, "/", script, acfg)
+
+	if len(result.Artifacts.Files) != 2 {
+		t.Errorf("expected 2 files, got %+v", result.Artifacts.Files)
+	}
+	if !result.Artifacts.Truncated {
+		t.Error("expected Truncated when exceeding MaxFiles")
+	}
+	if len(result.Artifacts.Skipped) != 1 || result.Artifacts.Skipped[0] != "/out/big" {
+		t.Errorf("oversized file should be skipped, got %v", result.Artifacts.Skipped)
+	}
+}
+
+func TestCollectArtifactsTar(t *testing.T) {
+	skipIfNotRoot(t)
+
+	var buf bytes.Buffer
+	acfg := DefaultArtifactConfig("", "/out/*.txt")
+	acfg.TarWriter = &buf
+	result := runArtifactSandbox(t, "/", "mkdir /out && echo hello > /out/r.txt", acfg)
+
+	if len(result.Artifacts.Files) != 1 {
+		t.Fatalf("expected 1 artifact, got %+v", result.Artifacts.Files)
+	}
+
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatalf("read tar: %v", err)
+	}
+	if hdr.Name != "out/r.txt" {
+		t.Errorf("expected out/r.txt, got %q", hdr.Name)
+	}
+	data, _ := io.ReadAll(tr)
+	if string(data) != "hello\n" {
+		t.Errorf("unexpected content %q", data)
+	}
+}
@@ -0,0 +1,720 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// BuildConfig 定义镜像构建参数。RUN 步骤使用与生产运行相同的隔离和资源限制。
+type BuildConfig struct {
+	ContextDir  string           // COPY 源文件所在的本地上下文目录
+	Namespace   NamespaceConfig  // RUN 步骤的 Namespace 配置
+	Cgroups     *CgroupsConfig   // RUN 步骤的资源限制（nil 表示不限制）
+	Seccomp     *SeccompConfig   // RUN 步骤的 seccomp 过滤（nil 表示不启用）
+	MaskPaths   *MaskPathsConfig // RUN 步骤的 /proc、/sys 加固（nil 表示不启用）
+	OverlaySize string           // 每个步骤上层 tmpfs 的大小
+	NoCache     bool             // 忽略构建缓存，重新执行所有步骤
+
+	// RUN 步骤的输出；Stderr 同时接收构建进度（nil 表示丢弃）
+	Stdout *os.File
+	Stderr *os.File
+}
+
+// DefaultBuildConfig 返回推荐的构建配置：完整 Namespace 隔离、seccomp 和路径屏蔽。
+func DefaultBuildConfig(contextDir string) BuildConfig {
+	scfg := DefaultSeccompConfig()
+	mcfg := DefaultMaskPathsConfig()
+	return BuildConfig{
+		ContextDir:  contextDir,
+		Namespace:   DefaultNamespaceConfig(),
+		Seccomp:     &scfg,
+		MaskPaths:   &mcfg,
+		OverlaySize: "1g",
+		Stdout:      os.Stdout,
+		Stderr:      os.Stderr,
+	}
+}
+
+// BuildStep 是 Sandboxfile 中的一条指令。
+type BuildStep struct {
+	Instruction string // 大写指令名：FROM、RUN、COPY、ENV、WORKDIR、USER、ENTRYPOINT、CMD
+	Args        string // 指令参数（原文）
+	Line        int    // 起始行号
+}
+
+func (s BuildStep) String() string {
+	return s.Instruction + " " + s.Args
+}
+
+// buildInstructions 是支持的 Sandboxfile 指令。
+var buildInstructions = map[string]bool{
+	"FROM": true, "RUN": true, "COPY": true, "ENV": true,
+	"WORKDIR": true, "USER": true, "ENTRYPOINT": true, "CMD": true,
+}
+
+// buildRuntimeDirs 是运行时挂载点，RUN 步骤对它们的修改不提交到层中。
+var buildRuntimeDirs = []string{"/dev", "/proc", "/sys"}
+
+// ParseSandboxfile 解析 Sandboxfile。
+//
+// 语法是 Dockerfile 的子集：每行一条指令，"#" 开头为注释，行尾 "\" 续行。
+// 第一条指令必须是 FROM（"FROM scratch" 表示空基础镜像）。
+func ParseSandboxfile(r io.Reader) ([]BuildStep, error) {
+	var steps []BuildStep
+	var cur strings.Builder
+	start := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if cur.Len() == 0 && (line == "" || strings.HasPrefix(line, "#")) {
+			continue
+		}
+		if cur.Len() == 0 {
+			start = n
+		}
+		if cont, ok := strings.CutSuffix(line, "\\"); ok {
+			cur.WriteString(cont)
+			cur.WriteString(" ")
+			continue
+		}
+		cur.WriteString(line)
+
+		instr, args, _ := strings.Cut(strings.TrimSpace(cur.String()), " ")
+		cur.Reset()
+		instr = strings.ToUpper(instr)
+		if !buildInstructions[instr] {
+			return nil, fmt.Errorf("build: line %d: unknown instruction %q", start, instr)
+		}
+		args = strings.TrimSpace(args)
+		if args == "" {
+			return nil, fmt.Errorf("build: line %d: %s requires arguments", start, instr)
+		}
+		if (len(steps) == 0) != (instr == "FROM") {
+			return nil, fmt.Errorf("build: line %d: FROM must be the first and only FROM instruction", start)
+		}
+		steps = append(steps, BuildStep{Instruction: instr, Args: args, Line: start})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("build: read: %w", err)
+	}
+	if cur.Len() > 0 {
+		return nil, fmt.Errorf("build: line %d: unterminated line continuation", start)
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("build: no instructions")
+	}
+	return steps, nil
+}
+
+// Builder 按 Sandboxfile 步骤构建镜像。
+//
+// 每个 RUN 步骤在以前序层为下层的 OverlayFS + Namespace 沙箱中执行，
+// 结束后将上层目录提交为新层；COPY 步骤将上下文目录中的文件写入上层后同样提交。
+// 每个步骤的缓存键是父步骤键与步骤内容的哈希（COPY 还包含源文件内容），
+// 命中缓存时直接复用已提交的层。
+//
+// 使用方式：
+//
+//	steps, err := sandbox.ParseSandboxfile(f)
+//	b := sandbox.NewBuilder(store, sandbox.DefaultBuildConfig("."))
+//	m, err := b.Build(steps, "agent-base:v1")
+type Builder struct {
+	store  *LayerStore
+	config BuildConfig
+	logger *zap.Logger
+}
+
+// NewBuilder 创建镜像构建器。
+func NewBuilder(store *LayerStore, config BuildConfig) *Builder {
+	return &Builder{store: store, config: config}
+}
+
+// SetLogger 设置此Builder的日志记录器。
+func (b *Builder) SetLogger(l *zap.Logger) {
+	b.logger = l
+}
+
+// buildState 是构建过程中逐步累积的镜像状态。
+type buildState struct {
+	layers []string // 层摘要，从底到顶
+	config ImageConfig
+	key    string // 当前步骤的缓存键
+	cmdSet bool   // 本 Sandboxfile 是否设置过 CMD
+}
+
+// Build 依次执行步骤并将结果保存为镜像 name。
+func (b *Builder) Build(steps []BuildStep, name string) (*ImageManifest, error) {
+	if _, err := b.store.imagePath(name); err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 || steps[0].Instruction != "FROM" {
+		return nil, errors.New("build: first instruction must be FROM")
+	}
+
+	var st buildState
+	for i, step := range steps {
+		b.progress("Step %d/%d : %s\n", i+1, len(steps), step)
+		if err := b.execStep(&st, step); err != nil {
+			return nil, fmt.Errorf("build: line %d: %s: %w", step.Line, step.Instruction, err)
+		}
+	}
+	if len(st.layers) == 0 {
+		return nil, errors.New("build: image has no layers")
+	}
+
+	config := st.config
+	m := ImageManifest{Name: name, Layers: st.layers, Config: &config}
+	if err := b.store.CreateImage(m); err != nil {
+		return nil, err
+	}
+	b.progress("Successfully built %s\n", name)
+
+	if b.logger != nil {
+		b.logger.Info("image built", zap.String("image", name), zap.Int("layers", len(st.layers)))
+	}
+	return b.store.Image(name)
+}
+
+// execStep 执行单个步骤并更新构建状态。
+func (b *Builder) execStep(st *buildState, step BuildStep) error {
+	switch step.Instruction {
+	case "FROM":
+		if step.Args != "scratch" {
+			m, err := b.store.Image(step.Args)
+			if err != nil {
+				return err
+			}
+			st.layers = append([]string{}, m.Layers...)
+			if m.Config != nil {
+				st.config = *m.Config
+			}
+		}
+		st.key = chainKey("", "FROM "+strings.Join(st.layers, " "))
+		return nil
+
+	case "ENV":
+		words, err := splitWords(step.Args)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(words[0], "=") {
+			// 旧语法：ENV KEY value with spaces
+			key, value, _ := strings.Cut(step.Args, " ")
+			words = []string{key + "=" + strings.TrimSpace(value)}
+		}
+		for _, w := range words {
+			if !strings.Contains(w, "=") {
+				return fmt.Errorf("invalid %q, expected KEY=VALUE", w)
+			}
+			st.config.Env = mergeEnvKeep(st.config.Env, w)
+		}
+
+	case "WORKDIR":
+		dir := step.Args
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join("/", st.config.WorkingDir, dir)
+		}
+		st.config.WorkingDir = filepath.Clean(dir)
+
+	case "USER":
+		st.config.User = step.Args
+
+	case "ENTRYPOINT":
+		argv, err := parseCommandForm(step.Args)
+		if err != nil {
+			return err
+		}
+		st.config.Entrypoint = argv
+		// 与 Docker 一致：设置 ENTRYPOINT 会清除从基础镜像继承的 CMD
+		if !st.cmdSet {
+			st.config.Cmd = nil
+		}
+
+	case "CMD":
+		argv, err := parseCommandForm(step.Args)
+		if err != nil {
+			return err
+		}
+		st.config.Cmd = argv
+		st.cmdSet = true
+
+	case "RUN":
+		argv, err := parseCommandForm(step.Args)
+		if err != nil {
+			return err
+		}
+		// 缓存键包含当前的 Env/WorkingDir/User（它们影响 RUN 的结果）
+		cfgJSON, _ := json.Marshal(&st.config)
+		key := chainKey(st.key, step.String()+"\x00"+string(cfgJSON))
+		return b.commitStep(st, key, func(ov *OverlayFS) (func() error, error) {
+			return b.runStep(ov, st, argv)
+		})
+
+	case "COPY":
+		return b.copyStep(st, step)
+	}
+
+	st.key = chainKey(st.key, step.String())
+	return nil
+}
+
+// commitStep 在以当前层为下层的 OverlayFS 中执行 fn，并将上层提交为新层。
+// fn 返回的清理函数在提交完成后执行。缓存命中时跳过执行。
+func (b *Builder) commitStep(st *buildState, key string, fn func(ov *OverlayFS) (func() error, error)) error {
+	if !b.config.NoCache {
+		if digest, ok := b.store.cachedLayer(key); ok {
+			b.progress(" ---> Using cache %s\n", digest)
+			st.layers = append(st.layers, digest)
+			st.key = key
+			return nil
+		}
+	}
+
+	// 登记下层引用，防止构建期间被并发的 Prune 回收
+	unlock, err := b.store.lock()
+	if err != nil {
+		return err
+	}
+	lowerDirs, release, err := b.store.acquireLayers(st.layers)
+	unlock()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if len(lowerDirs) == 0 {
+		// FROM scratch：overlay 至少需要一个下层，使用空目录
+		empty, removeEmpty, err := b.store.tempDir("scratch-")
+		if err != nil {
+			return err
+		}
+		defer removeEmpty()
+		os.Chmod(empty, 0755)
+		lowerDirs = []string{empty}
+	}
+
+	ovConfig := DefaultOverlayConfig("")
+	ovConfig.LowerDirs = lowerDirs
+	if b.config.OverlaySize != "" {
+		ovConfig.TmpfsSize = b.config.OverlaySize
+	}
+	ov := NewOverlayFS(ovConfig)
+	ov.SetLogger(b.logger)
+	if err := ov.Setup(); err != nil {
+		return err
+	}
+	defer ov.Cleanup()
+
+	cleanup, err := fn(ov)
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(ov.ExportDiff(pw, buildRuntimeDirs...))
+	}()
+	info, err := b.store.ImportLayer(pr)
+	pr.CloseWithError(err)
+	if err != nil {
+		return err
+	}
+	if err := b.store.setCachedLayer(key, info.Digest); err != nil {
+		return err
+	}
+	b.progress(" ---> %s\n", info.Digest)
+
+	st.layers = append(st.layers, info.Digest)
+	st.key = key
+	return nil
+}
+
+// runStep 在沙箱中执行 RUN 命令，非零退出码视为失败。
+// 返回 Namespace 的清理函数：Namespace 清理时会卸载 OverlayFS，必须在提交上层之后调用。
+func (b *Builder) runStep(ov *OverlayFS, st *buildState, argv []string) (func() error, error) {
+	// WORKDIR 不存在时自动创建（与 Docker 一致）
+	if dir := st.config.WorkingDir; dir != "" {
+		ov.mu.Lock()
+		p, err := ov.resolveMerged(dir)
+		if err == nil {
+			err = ov.ensureUpperDirs(p)
+		}
+		ov.mu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("workdir %s: %w", dir, err)
+		}
+	}
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return nil, err
+	}
+	defer devNull.Close()
+
+	ns := NewNamespace(b.config.Namespace)
+	ns.SetLogger(b.logger)
+
+	ns.SetOverlayFS(ov)
+	pcfg := DefaultPivotRootConfig()
+	ns.SetPivotRoot(&pcfg)
+	if b.config.Seccomp != nil {
+		ns.SetSeccomp(b.config.Seccomp)
+	}
+	if b.config.MaskPaths != nil {
+		ns.SetMaskPaths(b.config.MaskPaths)
+	}
+	if b.config.Cgroups != nil {
+		cg := NewCgroups(*b.config.Cgroups)
+		cg.SetLogger(b.logger)
+		if err := cg.Setup(); err != nil {
+			return nil, err
+		}
+		ns.SetCgroups(cg)
+	}
+
+	config := st.config
+	config.Apply(ns)
+	ns.Stdin, ns.Stdout, ns.Stderr = devNull, devNull, devNull
+	if b.config.Stdout != nil {
+		ns.Stdout = b.config.Stdout
+	}
+	if b.config.Stderr != nil {
+		ns.Stderr = b.config.Stderr
+	}
+
+	result, err := ns.Execute(argv[0], argv[1:]...)
+	if err != nil {
+		return ns.Cleanup, err
+	}
+	if result.ExitCode != 0 {
+		return ns.Cleanup, fmt.Errorf("command exited with code %d", result.ExitCode)
+	}
+	return ns.Cleanup, nil
+}
+
+// copyStep 将上下文目录中的文件复制到镜像中。
+//
+// 语义与 Dockerfile COPY 一致：源为目录时复制其内容；多个源或目标以 "/" 结尾时，
+// 目标视为目录。复制的文件属主为 0:0，保留权限位和修改时间。
+func (b *Builder) copyStep(st *buildState, step BuildStep) error {
+	words, err := splitWords(step.Args)
+	if err != nil {
+		return err
+	}
+	if len(words) < 2 {
+		return errors.New("requires at least one source and a destination")
+	}
+	srcs, dst := words[:len(words)-1], words[len(words)-1]
+	toDir := len(srcs) > 1 || strings.HasSuffix(dst, "/")
+	if !filepath.IsAbs(dst) {
+		dst = filepath.Join("/", st.config.WorkingDir, dst)
+	}
+
+	// 先将源文件打包到临时文件：打包流的哈希作为缓存键的一部分
+	tmpDir, removeTmp, err := b.store.tempDir("copy-")
+	if err != nil {
+		return err
+	}
+	defer removeTmp()
+	tmp, err := os.Create(filepath.Join(tmpDir, "context.tar"))
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+
+	h := sha256.New()
+	tw := tar.NewWriter(io.MultiWriter(tmp, h))
+	for _, src := range srcs {
+		if err := b.writeCopyTar(tw, src, dst, toDir); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	key := chainKey(st.key, step.String()+"\x00"+hex.EncodeToString(h.Sum(nil)))
+	return b.commitStep(st, key, func(ov *OverlayFS) (func() error, error) {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return nil, ov.InjectTar(tmp)
+	})
+}
+
+// writeCopyTar 将上下文目录中的 src 写入 tar，条目路径为镜像内的目标路径。
+func (b *Builder) writeCopyTar(tw *tar.Writer, src, dst string, toDir bool) error {
+	root, err := securejoin(b.config.ContextDir, src)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Lstat(root)
+	if err != nil {
+		return fmt.Errorf("source %s: %w", src, err)
+	}
+	if !fi.IsDir() && toDir {
+		dst = filepath.Join(dst, filepath.Base(root))
+	}
+
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		} else if !fi.IsDir() && !fi.Mode().IsRegular() {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = strings.TrimPrefix(filepath.Join(dst, rel), "/")
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// progress 输出构建进度。
+func (b *Builder) progress(format string, args ...any) {
+	if b.config.Stderr != nil {
+		fmt.Fprintf(b.config.Stderr, format, args...)
+	}
+}
+
+// ExportDiff 将上层目录（相对下层的变更）写为 OCI 层格式的 tar 流。
+//
+// overlay 的 whiteout（0:0 字符设备）转换为 ".wh.<name>"，opaque 目录追加
+// ".wh..wh..opq" 条目，硬链接保持为 tar 硬链接。exclude 中的路径及其子树被跳过。
+func (ov *OverlayFS) ExportDiff(w io.Writer, exclude ...string) error {
+	ov.mu.Lock()
+	defer ov.mu.Unlock()
+
+	if !ov.setupDone {
+		return fmt.Errorf("overlayfs: not set up")
+	}
+
+	skip := make(map[string]bool, len(exclude))
+	for _, e := range exclude {
+		skip[filepath.Clean("/"+e)] = true
+	}
+
+	type inode struct{ dev, ino uint64 }
+	links := make(map[inode]string)
+
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(ov.upperDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(ov.upperDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if skip["/"+rel] {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if isWhiteout(fi) {
+			return tw.WriteHeader(&tar.Header{
+				Name:     filepath.Join(filepath.Dir(rel), whiteoutPrefix+fi.Name()),
+				Typeflag: tar.TypeReg,
+				Mode:     0600,
+			})
+		}
+
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		hdr.Uname, hdr.Gname = "", ""
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+
+		st, _ := fi.Sys().(*syscall.Stat_t)
+		if st != nil && fi.Mode().IsRegular() && st.Nlink > 1 {
+			id := inode{uint64(st.Dev), st.Ino}
+			if first, ok := links[id]; ok {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = first
+				hdr.Size = 0
+				return tw.WriteHeader(hdr)
+			}
+			links[id] = rel
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() && isOpaqueDir(path) {
+			return tw.WriteHeader(&tar.Header{
+				Name:     filepath.Join(rel, whiteoutOpaque),
+				Typeflag: tar.TypeReg,
+				Mode:     0600,
+			})
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("overlayfs: export diff: %w", err)
+	}
+	return tw.Close()
+}
+
+// cachedLayer 返回构建缓存中 key 对应的层摘要；层已被回收时视为未命中。
+func (s *LayerStore) cachedLayer(key string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(s.root, "cache", key))
+	if err != nil {
+		return "", false
+	}
+	digest := strings.TrimSpace(string(data))
+	if _, err := s.LayerDir(digest); err != nil {
+		return "", false
+	}
+	return digest, true
+}
+
+// setCachedLayer 记录构建缓存项。
+func (s *LayerStore) setCachedLayer(key, digest string) error {
+	p := filepath.Join(s.root, "cache", key)
+	if err := os.WriteFile(p+".tmp", []byte(digest), 0600); err != nil {
+		return fmt.Errorf("layerstore: write cache: %w", err)
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+// chainKey 由父键和步骤内容计算缓存键。
+func chainKey(parent, step string) string {
+	sum := sha256.Sum256([]byte(parent + "\n" + step))
+	return hex.EncodeToString(sum[:])
+}
+
+// mergeEnvKeep 设置单个 KEY=VALUE，已存在同名变量时原位替换。
+func mergeEnvKeep(env []string, kv string) []string {
+	key, _, _ := strings.Cut(kv, "=")
+	out := append([]string{}, env...)
+	for i, e := range out {
+		if strings.HasPrefix(e, key+"=") {
+			out[i] = kv
+			return out
+		}
+	}
+	return append(out, kv)
+}
+
+// parseCommandForm 解析命令参数：JSON 数组为 exec 形式，否则为 shell 形式（/bin/sh -c）。
+func parseCommandForm(s string) ([]string, error) {
+	if strings.HasPrefix(s, "[") {
+		var argv []string
+		if err := json.Unmarshal([]byte(s), &argv); err != nil {
+			return nil, fmt.Errorf("invalid JSON command: %w", err)
+		}
+		if len(argv) == 0 {
+			return nil, errors.New("empty command")
+		}
+		return argv, nil
+	}
+	return []string{"/bin/sh", "-c", s}, nil
+}
+
+// splitWords 按空白拆分参数，支持单引号和双引号（双引号内支持 \" 转义）。
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' && i+1 < len(runes) {
+				i++
+				cur.WriteRune(runes[i])
+			} else {
+				cur.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
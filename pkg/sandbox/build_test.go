@@ -0,0 +1,229 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ===================================================================
+// 镜像构建单元测试（不需要 root）
+// ===================================================================
+
+func TestParseSandboxfile(t *testing.T) {
+	src := `# base image
+FROM python:3.12
+ENV A=1 B="two words"
+RUN apt-get update && \
+    apt-get install -y curl
+copy . /app
+CMD ["python", "main.py"]
+`
+	steps, err := ParseSandboxfile(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ParseSandboxfile failed: %v", err)
+	}
+	if len(steps) != 5 {
+		t.Fatalf("expected 5 steps, got %d: %+v", len(steps), steps)
+	}
+	if steps[2].Instruction != "RUN" || steps[2].Args != "apt-get update &&  apt-get install -y curl" || steps[2].Line != 4 {
+		t.Errorf("unexpected continued step: %+v", steps[2])
+	}
+	if steps[3].Instruction != "COPY" {
+		t.Errorf("instructions should be case-insensitive, got %q", steps[3].Instruction)
+	}
+}
+
+func TestParseSandboxfileErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"RUN echo hi",
+		"FROM a\nFROM b",
+		"FROM a\nADD x /y",
+		"FROM a\nRUN",
+		"FROM a\nRUN echo \\",
+	}
+	for _, src := range tests {
+		if _, err := ParseSandboxfile(strings.NewReader(src)); err == nil {
+			t.Errorf("expected error for %q", src)
+		}
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	got, err := splitWords(`A=1 B="two words" C='x y' D="q\"q"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"A=1", "B=two words", "C=x y", `D=q"q`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := splitWords(`A="open`); err == nil {
+		t.Error("expected error for unterminated quote")
+	}
+}
+
+func TestParseCommandForm(t *testing.T) {
+	if got, _ := parseCommandForm(`["python", "-c", "print(1)"]`); !reflect.DeepEqual(got, []string{"python", "-c", "print(1)"}) {
+		t.Errorf("exec form: got %q", got)
+	}
+	if got, _ := parseCommandForm("echo $HOME"); !reflect.DeepEqual(got, []string{"/bin/sh", "-c", "echo $HOME"}) {
+		t.Errorf("shell form: got %q", got)
+	}
+	if _, err := parseCommandForm("[]"); err == nil {
+		t.Error("expected error for empty exec form")
+	}
+}
+
+func TestBuildConfigOnlySteps(t *testing.T) {
+	store, _ := NewLayerStore(t.TempDir())
+	layer, _ := store.ImportLayer(bytes.NewReader(buildLayerTar(t, map[string]string{"a": "1"})))
+	store.CreateImage(ImageManifest{Name: "base", Layers: []string{layer.Digest},
+		Config: &ImageConfig{Entrypoint: []string{"old"}, Cmd: []string{"inherited"}}})
+
+	src := "FROM base\nENV PATH=/opt/bin LANG=C\nWORKDIR /app\nWORKDIR sub\nUSER agent\nENTRYPOINT [\"python\"]\n"
+	steps, err := ParseSandboxfile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultBuildConfig(t.TempDir())
+	cfg.Stdout, cfg.Stderr = nil, nil
+	m, err := NewBuilder(store, cfg).Build(steps, "derived")
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	want := ImageConfig{
+		Env:        []string{"PATH=/opt/bin", "LANG=C"},
+		WorkingDir: "/app/sub",
+		User:       "agent",
+		Entrypoint: []string{"python"},
+	}
+	if !reflect.DeepEqual(*m.Config, want) {
+		t.Errorf("config: got %+v, want %+v", *m.Config, want)
+	}
+	if !reflect.DeepEqual(m.Layers, []string{layer.Digest}) {
+		t.Errorf("metadata-only steps should not add layers, got %v", m.Layers)
+	}
+}
+
+// ===================================================================
+// 镜像构建集成测试（需要 root）
+// ===================================================================
+
+// hostBaseImage 在层存储中登记一个以宿主机根目录为内容的基础镜像，
+// 使 RUN 步骤可以使用宿主机的 /bin/sh 等工具。
+func hostBaseImage(t *testing.T, store *LayerStore) {
+	t.Helper()
+	digest := "sha256:" + strings.Repeat("f", 64)
+	p, _ := store.layerPath(digest)
+	os.MkdirAll(p, 0700)
+	if err := os.Symlink("/", filepath.Join(p, "diff")); err != nil {
+		t.Fatal(err)
+	}
+	writeJSONFile(filepath.Join(p, "layer.json"), &LayerInfo{Digest: digest, Created: time.Now()})
+	if err := store.CreateImage(ImageManifest{Name: "host", Layers: []string{digest}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildRunAndCopy(t *testing.T) {
+	skipIfNotRoot(t)
+
+	store, _ := NewLayerStore(t.TempDir())
+	hostBaseImage(t, store)
+
+	ctx := t.TempDir()
+	os.WriteFile(filepath.Join(ctx, "main.py"), []byte("print('hi')"), 0644)
+
+	src := `FROM host
+WORKDIR /build-test
+ENV GREETING=hello
+RUN echo $GREETING > out.txt && rm -f /etc/hostname
+COPY main.py /build-test/
+`
+	steps, err := ParseSandboxfile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build := func() (*ImageManifest, string) {
+		r, w, _ := os.Pipe()
+		cfg := DefaultBuildConfig(ctx)
+		cfg.Stdout, cfg.Stderr = w, w
+		m, err := NewBuilder(store, cfg).Build(steps, "built")
+		w.Close()
+		var out bytes.Buffer
+		out.ReadFrom(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("Build failed: %v\n%s", err, out.String())
+		}
+		return m, out.String()
+	}
+
+	m, _ := build()
+	if len(m.Layers) != 3 {
+		t.Fatalf("expected base + RUN + COPY layers, got %v", m.Layers)
+	}
+
+	runDir, _ := store.LayerDir(m.Layers[1])
+	data, err := os.ReadFile(filepath.Join(runDir, "build-test", "out.txt"))
+	if err != nil || string(data) != "hello\n" {
+		t.Errorf("unexpected RUN output: %q, %v", data, err)
+	}
+	if fi, err := os.Lstat(filepath.Join(runDir, "etc", "hostname")); err == nil && !isWhiteout(fi) {
+		t.Error("deleted file should be committed as a whiteout")
+	}
+	if _, err := os.Lstat(filepath.Join(runDir, "dev")); !os.IsNotExist(err) {
+		t.Error("runtime /dev should not be committed")
+	}
+
+	copyDir, _ := store.LayerDir(m.Layers[2])
+	if data, _ := os.ReadFile(filepath.Join(copyDir, "build-test", "main.py")); string(data) != "print('hi')" {
+		t.Errorf("unexpected COPY content: %q", data)
+	}
+
+	// 第二次构建全部命中缓存
+	m2, out := build()
+	if !reflect.DeepEqual(m.Layers, m2.Layers) {
+		t.Errorf("cached build should produce the same layers: %v vs %v", m.Layers, m2.Layers)
+	}
+	if n := strings.Count(out, "Using cache"); n != 2 {
+		t.Errorf("expected 2 cache hits, got %d:\n%s", n, out)
+	}
+
+	// 修改 COPY 源文件只使 COPY 步骤失效
+	os.WriteFile(filepath.Join(ctx, "main.py"), []byte("print('changed')"), 0644)
+	m3, out := build()
+	if m3.Layers[1] != m.Layers[1] || m3.Layers[2] == m.Layers[2] {
+		t.Errorf("only the COPY layer should change: %v vs %v", m.Layers, m3.Layers)
+	}
+	if n := strings.Count(out, "Using cache"); n != 1 {
+		t.Errorf("expected 1 cache hit, got %d:\n%s", n, out)
+	}
+}
+
+func TestBuildRunFailure(t *testing.T) {
+	skipIfNotRoot(t)
+
+	store, _ := NewLayerStore(t.TempDir())
+	hostBaseImage(t, store)
+
+	steps, _ := ParseSandboxfile(strings.NewReader("FROM host\nRUN exit 3\n"))
+	cfg := DefaultBuildConfig(t.TempDir())
+	cfg.Stdout, cfg.Stderr = nil, nil
+	if _, err := NewBuilder(store, cfg).Build(steps, "fail"); err == nil || !strings.Contains(err.Error(), "code 3") {
+		t.Errorf("expected exit code error, got %v", err)
+	}
+	if _, err := store.Image("fail"); err == nil {
+		t.Error("failed build should not create an image")
+	}
+}
@@ -0,0 +1,259 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// CgroupEventType 是 cgroup 事件的类型。
+type CgroupEventType string
+
+const (
+	// CgroupEventOOM：内存达到 memory.max 且回收失败，即将触发 OOM Killer（memory.events oom）
+	CgroupEventOOM CgroupEventType = "oom"
+	// CgroupEventOOMKill：进程被 OOM Killer 杀死（memory.events oom_kill）
+	CgroupEventOOMKill CgroupEventType = "oom_kill"
+	// CgroupEventPidsMax：fork/clone 因达到 pids.max 失败，进程看到 EAGAIN（pids.events max）
+	CgroupEventPidsMax CgroupEventType = "pids_max"
+	// CgroupEventPopulated：cgroup 中出现第一个进程（cgroup.events populated 0→1）
+	CgroupEventPopulated CgroupEventType = "populated"
+	// CgroupEventEmpty：cgroup 中最后一个进程退出（cgroup.events populated 1→0）
+	CgroupEventEmpty CgroupEventType = "empty"
+)
+
+// CgroupEvent 是一次 cgroup 事件。
+type CgroupEvent struct {
+	Type  CgroupEventType `json:"type"`
+	Time  time.Time       `json:"time"`
+	Count uint64          `json:"count"` // 计数类事件自上次通知以来新增的次数，状态类事件为 1
+}
+
+// eventSubscriberBuffer 是每个订阅者 channel 的缓冲大小。
+const eventSubscriberBuffer = 16
+
+// eventCounters 列出被监视的计数器：文件 → 计数键 → 事件类型。
+var eventCounters = map[string]map[string]CgroupEventType{
+	"memory.events": {"oom": CgroupEventOOM, "oom_kill": CgroupEventOOMKill},
+	"pids.events":   {"max": CgroupEventPidsMax},
+}
+
+// eventWatcher 用 inotify 监视一个 cgroup 的 memory.events、pids.events 和 cgroup.events，
+// 将计数变化转换为 CgroupEvent，记录到日志、历史和订阅者 channel。
+//
+// 内核在这些文件内容变化时产生 IN_MODIFY（cgroup_file_notify）。
+// 未启用的控制器没有对应文件，跳过。
+type eventWatcher struct {
+	dir    string
+	id     string
+	logger *zap.Logger
+
+	inotify *os.File
+	exited  chan struct{}
+
+	mu        sync.Mutex
+	counts    map[string]uint64 // "<file>:<key>" → 上次读取的计数
+	populated bool
+	history   []CgroupEvent
+	subs      map[chan CgroupEvent]struct{}
+	stopped   bool
+}
+
+// startEventWatcher 读取当前计数作为基线，并启动 inotify 监听 goroutine。
+func startEventWatcher(dir, id string, logger *zap.Logger) (*eventWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init: %w", err)
+	}
+	// 非阻塞 fd 交给 Go 运行时的 poller，Close 可以中断阻塞中的 Read
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	files := []string{"cgroup.events"}
+	for file := range eventCounters {
+		files = append(files, file)
+	}
+	watched := 0
+	for _, file := range files {
+		_, err := unix.InotifyAddWatch(fd, filepath.Join(dir, file), unix.IN_MODIFY)
+		if errors.Is(err, unix.ENOENT) {
+			continue
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("inotify watch %s: %w", file, err)
+		}
+		watched++
+	}
+	if watched == 0 {
+		f.Close()
+		return nil, fmt.Errorf("no event files in %s", dir)
+	}
+
+	w := &eventWatcher{
+		dir:     dir,
+		id:      id,
+		logger:  logger,
+		inotify: f,
+		exited:  make(chan struct{}),
+		counts:  make(map[string]uint64),
+		subs:    make(map[chan CgroupEvent]struct{}),
+	}
+	w.check(false)
+
+	go w.run()
+	return w, nil
+}
+
+// run 在每次 inotify 通知后重新读取事件文件。事件内容本身不需要解析。
+func (w *eventWatcher) run() {
+	defer close(w.exited)
+	buf := make([]byte, 4096)
+	for {
+		if _, err := w.inotify.Read(buf); err != nil {
+			return
+		}
+		w.check(true)
+	}
+}
+
+// check 读取事件文件，与上次的计数比较并发出事件。emit 为 false 时只更新基线。
+func (w *eventWatcher) check(emit bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopped {
+		return
+	}
+	now := time.Now()
+	for file, keys := range eventCounters {
+		readKeyValueFile(filepath.Join(w.dir, file), func(key string, v uint64) {
+			typ, ok := keys[key]
+			if !ok {
+				return
+			}
+			k := file + ":" + key
+			if prev := w.counts[k]; emit && v > prev {
+				w.emit(CgroupEvent{Type: typ, Time: now, Count: v - prev})
+			}
+			w.counts[k] = v
+		})
+	}
+
+	events, err := readCgroupEvents(w.dir)
+	if err != nil || events["populated"] == "" {
+		return
+	}
+	populated := events["populated"] == "1"
+	if emit && populated != w.populated {
+		typ := CgroupEventEmpty
+		if populated {
+			typ = CgroupEventPopulated
+		}
+		w.emit(CgroupEvent{Type: typ, Time: now, Count: 1})
+	}
+	w.populated = populated
+}
+
+// emit 记录事件并发送给订阅者。调用方持有 w.mu。
+// 订阅者处理不及时时丢弃该事件（历史中仍然保留）。
+func (w *eventWatcher) emit(ev CgroupEvent) {
+	w.history = append(w.history, ev)
+	for ch := range w.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+
+	if w.logger == nil {
+		return
+	}
+	fields := []zap.Field{zap.String("cgroup_id", w.id), zap.String("event", string(ev.Type)), zap.Uint64("count", ev.Count)}
+	switch ev.Type {
+	case CgroupEventOOM, CgroupEventOOMKill, CgroupEventPidsMax:
+		w.logger.Warn("cgroup event", fields...)
+	default:
+		w.logger.Info("cgroup event", fields...)
+	}
+}
+
+// subscribe 注册一个订阅者。
+func (w *eventWatcher) subscribe() (<-chan CgroupEvent, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ch := make(chan CgroupEvent, eventSubscriberBuffer)
+	if w.stopped {
+		close(ch)
+		return ch, func() {}
+	}
+	w.subs[ch] = struct{}{}
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subs[ch]; ok {
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// events 返回目前为止的所有事件。
+func (w *eventWatcher) events() []CgroupEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]CgroupEvent(nil), w.history...)
+}
+
+// stop 补读一次事件文件（不遗漏尚未处理的通知），然后停止监听并关闭所有订阅者 channel。
+func (w *eventWatcher) stop() {
+	w.check(true)
+	w.inotify.Close()
+	<-w.exited
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	for ch := range w.subs {
+		close(ch)
+	}
+	w.subs = nil
+}
+
+// SubscribeEvents 订阅此 cgroup 的 OOM、pids.max 和进程进出事件。
+//
+// 调用返回的取消函数或 Cleanup 时关闭 channel。接收方处理不及时时丢弃事件，
+// 完整记录可通过 Events() 获取。
+func (cg *CgroupsV2) SubscribeEvents() (<-chan CgroupEvent, func(), error) {
+	cg.mu.Lock()
+	w := cg.events
+	cg.mu.Unlock()
+
+	if w == nil {
+		return nil, nil, fmt.Errorf("cgroups: event watcher not running")
+	}
+	ch, cancel := w.subscribe()
+	return ch, cancel, nil
+}
+
+// Events 返回 Setup 以来发生的所有 cgroup 事件（不依赖订阅）。
+// 先补读一次事件文件，使刚发生尚未处理通知的事件也包含在内。
+func (cg *CgroupsV2) Events() []CgroupEvent {
+	cg.mu.Lock()
+	w := cg.events
+	cg.mu.Unlock()
+
+	if w == nil {
+		return nil
+	}
+	w.check(true)
+	return w.events()
+}
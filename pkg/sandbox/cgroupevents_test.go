@@ -0,0 +1,167 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ===================================================================
+// cgroup 事件单元测试（不需要 root）
+// ===================================================================
+
+func writeEventFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func recvEvent(t *testing.T, ch <-chan CgroupEvent) CgroupEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatal("event channel closed")
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return CgroupEvent{}
+}
+
+func TestEventWatcherFixture(t *testing.T) {
+	// 基线中已有的计数不产生事件
+	dir := writeCgroupFixture(t, map[string]string{
+		"memory.events": "low 0\nhigh 0\nmax 0\noom 1\noom_kill 1\n",
+		"pids.events":   "max 0\n",
+		"cgroup.events": "populated 0\nfrozen 0\n",
+	})
+	w, err := startEventWatcher(dir, "test", nil)
+	if err != nil {
+		t.Fatalf("startEventWatcher failed: %v", err)
+	}
+	ch, cancel := w.subscribe()
+	defer cancel()
+
+	writeEventFile(t, dir, "cgroup.events", "populated 1\nfrozen 0\n")
+	if ev := recvEvent(t, ch); ev.Type != CgroupEventPopulated {
+		t.Errorf("expected populated, got %+v", ev)
+	}
+
+	writeEventFile(t, dir, "pids.events", "max 3\n")
+	if ev := recvEvent(t, ch); ev.Type != CgroupEventPidsMax || ev.Count != 3 {
+		t.Errorf("expected pids_max x3, got %+v", ev)
+	}
+
+	writeEventFile(t, dir, "memory.events", "low 0\nhigh 5\nmax 2\noom 2\noom_kill 3\n")
+	if ev := recvEvent(t, ch); ev.Type != CgroupEventOOM || ev.Count != 1 {
+		t.Errorf("expected oom x1, got %+v", ev)
+	}
+	if ev := recvEvent(t, ch); ev.Type != CgroupEventOOMKill || ev.Count != 2 {
+		t.Errorf("expected oom_kill x2, got %+v", ev)
+	}
+
+	// stop 补读尚未处理的变化，然后关闭订阅者 channel
+	writeEventFile(t, dir, "cgroup.events", "populated 0\nfrozen 0\n")
+	w.stop()
+	var last CgroupEvent
+	for ev := range ch {
+		last = ev
+	}
+	if last.Type != CgroupEventEmpty {
+		t.Errorf("expected a final empty event, got %+v", last)
+	}
+
+	var types []CgroupEventType
+	for _, ev := range w.events() {
+		types = append(types, ev.Type)
+	}
+	want := []CgroupEventType{CgroupEventPopulated, CgroupEventPidsMax, CgroupEventOOM, CgroupEventOOMKill, CgroupEventEmpty}
+	if len(types) != len(want) {
+		t.Fatalf("history = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("history = %v, want %v", types, want)
+			break
+		}
+	}
+
+	// 停止后订阅得到已关闭的 channel
+	closed, _ := w.subscribe()
+	if _, ok := <-closed; ok {
+		t.Error("expected a closed channel after stop")
+	}
+}
+
+func TestEventWatcherMissingFiles(t *testing.T) {
+	if _, err := startEventWatcher(t.TempDir(), "test", nil); err == nil {
+		t.Error("expected error without any event files")
+	}
+
+	// 只有 cgroup.events（未启用 memory/pids 控制器）时仍可监视
+	dir := writeCgroupFixture(t, map[string]string{"cgroup.events": "populated 0\n"})
+	w, err := startEventWatcher(dir, "test", nil)
+	if err != nil {
+		t.Fatalf("startEventWatcher failed: %v", err)
+	}
+	w.stop()
+}
+
+func TestSubscribeEventsNotSetUp(t *testing.T) {
+	cg := NewCgroupsV2(DefaultCgroupsConfig())
+	if _, _, err := cg.SubscribeEvents(); err == nil {
+		t.Error("expected error before Setup")
+	}
+	if events := cg.Events(); events != nil {
+		t.Errorf("expected no events before Setup, got %v", events)
+	}
+}
+
+// ===================================================================
+// cgroup 事件集成测试（需要 root）
+// ===================================================================
+
+func TestCgroupsEventsInResult(t *testing.T) {
+	base := cgroup2Mount(t)
+
+	cg := NewCgroupsV2(CgroupsConfig{Enabled: true, BaseDir: base})
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	events, cancel, err := cg.SubscribeEvents()
+	if err != nil {
+		cg.Cleanup()
+		t.Fatalf("SubscribeEvents failed: %v", err)
+	}
+	defer cancel()
+
+	ns := NewNamespace(NamespaceConfig{Mount: true})
+	ns.SetCgroupsV2(cg)
+	defer ns.Cleanup()
+	ns.Stdout, ns.Stderr = nil, nil
+
+	result, err := ns.Execute("true")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	var types []CgroupEventType
+	for _, ev := range result.CgroupEvents {
+		types = append(types, ev.Type)
+	}
+	if len(types) != 2 || types[0] != CgroupEventPopulated || types[1] != CgroupEventEmpty {
+		t.Errorf("result.CgroupEvents = %v, want [populated empty]", types)
+	}
+
+	if ev := recvEvent(t, events); ev.Type != CgroupEventPopulated {
+		t.Errorf("expected populated from the subscription, got %+v", ev)
+	}
+	ns.Cleanup()
+	for range events {
+	}
+}
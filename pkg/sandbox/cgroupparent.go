@@ -0,0 +1,177 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CgroupParent 是沙箱 cgroup 之上的一级父 cgroup（例如租户、工作流）。
+//
+// 同一父 cgroup 下所有沙箱的资源总和受 Limits 限制：
+//
+//	BaseDir/ai-sandbox/tenant-a/wf-17/sandbox-<id>
+//	        ^Parents[0] ^Parents[1] ^Parents[2]
+//
+// 父 cgroup 可被多个沙箱共享，每次 Setup 都会（重新）写入其限制。
+type CgroupParent struct {
+	Name   string        // 目录名，不能包含 "/"，不能以 "sandbox-" 开头
+	Limits CgroupsConfig // 此层级的资源限制，只使用 CPU/内存/进程数/I/O 字段（零值表示不限制）
+	Keep   bool          // Cleanup 时保留（例如由运维预先创建并配置的租户 cgroup）
+}
+
+// validateParents 校验父 cgroup 名称和限制。
+func validateParents(parents []CgroupParent) error {
+	for _, p := range parents {
+		if p.Name == "" || p.Name == "." || p.Name == ".." || strings.Contains(p.Name, "/") {
+			return fmt.Errorf("cgroups: invalid parent name %q", p.Name)
+		}
+		if strings.HasPrefix(p.Name, "sandbox-") || strings.HasPrefix(p.Name, "cgroup.") {
+			return fmt.Errorf("cgroups: parent name %q uses a reserved prefix", p.Name)
+		}
+		if err := p.Limits.validate(); err != nil {
+			return fmt.Errorf("cgroups: parent %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// setupParents 从 baseDir 开始逐级创建父 cgroup、启用控制器并写入各级限制，
+// 返回最深一级父 cgroup 的目录。
+//
+// 在 cgroup v2 中，子 cgroup 只能使用父 cgroup 的 subtree_control 中启用的控制器，
+// 因此沙箱和各级父 cgroup 需要的控制器在链上的每一级都要启用。
+func (cg *CgroupsV2) setupParents(baseDir string) (string, error) {
+	controllers := cg.config.controllers()
+	seen := make(map[string]bool)
+	for _, c := range controllers {
+		seen[c] = true
+	}
+	for _, p := range cg.config.Parents {
+		for _, c := range p.Limits.controllers() {
+			if !seen[c] {
+				seen[c] = true
+				controllers = append(controllers, c)
+			}
+		}
+	}
+
+	dir := baseDir
+	for _, p := range cg.config.Parents {
+		if len(controllers) > 0 {
+			if err := enableControllers(dir, controllers); err != nil {
+				return "", fmt.Errorf("cgroups: enable controllers in %s: %w", dir, err)
+			}
+		}
+		dir = filepath.Join(dir, p.Name)
+		if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+			return "", fmt.Errorf("cgroups: mkdir %s: %w", dir, err)
+		}
+		writes, err := p.Limits.limitWrites(nil, nil)
+		if err == nil {
+			err = cg.applyWrites(dir, writes)
+		}
+		if err != nil {
+			return "", fmt.Errorf("cgroups: parent %s: %w", p.Name, err)
+		}
+	}
+
+	if len(controllers) > 0 {
+		if err := enableControllers(dir, controllers); err != nil {
+			return "", fmt.Errorf("cgroups: enable controllers in %s: %w", dir, err)
+		}
+	}
+	return dir, nil
+}
+
+// cleanupParents 自下而上删除已空的父 cgroup。
+// 仍有其他沙箱（EBUSY）或标记为 Keep 的父 cgroup 及其上级保留。
+func (cg *CgroupsV2) cleanupParents() {
+	dir := filepath.Dir(cg.cgroupDir)
+	for i := len(cg.config.Parents) - 1; i >= 0; i-- {
+		if cg.config.Parents[i].Keep || os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// DelegatedBaseDir 返回当前进程所在的 cgroup v2 目录，用作 CgroupsConfig.BaseDir，
+// 使沙箱 cgroup 创建在委派给本进程的子树中（例如 systemd 服务设置了 Delegate=yes），
+// 而不需要写 cgroup 根。
+//
+// cgroup v2 不允许有进程的 cgroup 向子 cgroup 启用控制器（no internal processes），
+// 因此该 cgroup 中的进程（包括当前进程）被移动到子 cgroup "supervisor" 中。
+func DelegatedBaseDir() (string, error) {
+	mount, err := cgroup2MountPoint()
+	if err != nil {
+		return "", fmt.Errorf("cgroups: delegate: %w", err)
+	}
+	rel, err := selfCgroupPath()
+	if err != nil {
+		return "", fmt.Errorf("cgroups: delegate: %w", err)
+	}
+	base := filepath.Join(mount, rel)
+
+	if pids := readPids(base); len(pids) > 0 {
+		leaf := filepath.Join(base, "supervisor")
+		if err := os.Mkdir(leaf, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+			return "", fmt.Errorf("cgroups: delegate: mkdir %s: %w", leaf, err)
+		}
+		for _, pid := range pids {
+			// 进程可能已退出
+			_ = writeFile(filepath.Join(leaf, "cgroup.procs"), strconv.Itoa(pid))
+		}
+		if pids := readPids(base); len(pids) > 0 {
+			return "", fmt.Errorf("cgroups: delegate: cannot move processes %v out of %s", pids, base)
+		}
+	}
+	return base, nil
+}
+
+// cgroup2MountPoint 从 /proc/self/mountinfo 中查找 cgroup2 的挂载点
+// （纯 v2 系统为 /sys/fs/cgroup，混合模式下通常为 /sys/fs/cgroup/unified）。
+func cgroup2MountPoint() (string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式：id parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		line := scanner.Text()
+		_, post, ok := strings.Cut(line, " - ")
+		if !ok || !strings.HasPrefix(post, "cgroup2 ") {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) >= 5 {
+			return fields[4], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no cgroup2 mount found")
+}
+
+// selfCgroupPath 从 /proc/self/cgroup 中读取当前进程的 cgroup v2 路径（"0::/path" 行）。
+func selfCgroupPath() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("process is not in a cgroup v2 hierarchy")
+}
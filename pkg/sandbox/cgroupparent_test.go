@@ -0,0 +1,145 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ===================================================================
+// 父 cgroup 单元测试（不需要 root）
+// ===================================================================
+
+func TestValidateParents(t *testing.T) {
+	valid := []CgroupParent{
+		{Name: "ai-sandbox"},
+		{Name: "tenant-a", Limits: CgroupsConfig{CPUQuota: 200000, CPUPeriod: 100000, PidsMax: 512}},
+	}
+	if err := validateParents(valid); err != nil {
+		t.Errorf("valid parents rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		parent CgroupParent
+	}{
+		{"empty", CgroupParent{}},
+		{"dot", CgroupParent{Name: "."}},
+		{"dotdot", CgroupParent{Name: ".."}},
+		{"slash", CgroupParent{Name: "tenant/a"}},
+		{"sandbox prefix", CgroupParent{Name: "sandbox-1234"}},
+		{"control file", CgroupParent{Name: "cgroup.procs"}},
+		{"bad limits", CgroupParent{Name: "tenant", Limits: CgroupsConfig{IOWeight: 20000}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateParents([]CgroupParent{tt.parent}); err == nil {
+				t.Errorf("expected error for parent %+v", tt.parent)
+			}
+		})
+	}
+}
+
+func TestCgroupsSetupInvalidParent(t *testing.T) {
+	cg := NewCgroupsV2(CgroupsConfig{
+		Enabled: true,
+		BaseDir: t.TempDir(),
+		Parents: []CgroupParent{{Name: "../escape"}},
+	})
+	if err := cg.Setup(); err == nil {
+		cg.Cleanup()
+		t.Fatal("expected Setup to reject an invalid parent name")
+	}
+}
+
+func TestSelfCgroupPath(t *testing.T) {
+	path, err := selfCgroupPath()
+	if err != nil {
+		t.Skipf("skipping: %v", err)
+	}
+	if !strings.HasPrefix(path, "/") {
+		t.Errorf("cgroup path %q should be absolute", path)
+	}
+}
+
+func TestCgroup2MountPoint(t *testing.T) {
+	mount, err := cgroup2MountPoint()
+	if err != nil {
+		t.Skipf("skipping: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mount, "cgroup.procs")); err != nil {
+		t.Errorf("%s does not look like a cgroup2 mount: %v", mount, err)
+	}
+}
+
+// ===================================================================
+// 父 cgroup 集成测试（需要 root）
+// ===================================================================
+
+func TestCgroupsNestedParents(t *testing.T) {
+	base := cgroup2Mount(t)
+
+	root := "ai-sandbox-test-" + generateID()
+	config := CgroupsConfig{
+		Enabled: true,
+		BaseDir: base,
+		Parents: []CgroupParent{{Name: root}, {Name: "tenant-a"}, {Name: "wf-17"}},
+	}
+	cg1 := NewCgroupsV2(config)
+	if err := cg1.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer os.RemoveAll(filepath.Join(base, root))
+	cg2 := NewCgroupsV2(config)
+	if err := cg2.Setup(); err != nil {
+		cg1.Cleanup()
+		t.Fatalf("second Setup failed: %v", err)
+	}
+
+	parent := filepath.Join(base, root, "tenant-a", "wf-17")
+	if filepath.Dir(cg1.CgroupDir()) != parent || filepath.Dir(cg2.CgroupDir()) != parent {
+		t.Fatalf("sandboxes %s, %s should be nested under %s", cg1.CgroupDir(), cg2.CgroupDir(), parent)
+	}
+
+	// 共享的父 cgroup 在最后一个沙箱清理后才删除
+	if err := cg1.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if _, err := os.Stat(parent); err != nil {
+		t.Errorf("parent %s removed while still in use: %v", parent, err)
+	}
+	if err := cg2.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, root)); !os.IsNotExist(err) {
+		t.Errorf("empty parents under %s should be removed", root)
+	}
+}
+
+func TestCgroupsKeepParent(t *testing.T) {
+	base := cgroup2Mount(t)
+
+	root := "ai-sandbox-test-" + generateID()
+	cg := NewCgroupsV2(CgroupsConfig{
+		Enabled: true,
+		BaseDir: base,
+		Parents: []CgroupParent{{Name: root, Keep: true}, {Name: "wf"}},
+	})
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer os.RemoveAll(filepath.Join(base, root))
+
+	if err := cg.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, root, "wf")); !os.IsNotExist(err) {
+		t.Error("non-kept parent should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(base, root)); err != nil {
+		t.Errorf("kept parent should remain: %v", err)
+	}
+}
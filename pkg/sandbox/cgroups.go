@@ -3,12 +3,14 @@
 package sandbox
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"go.uber.org/zap"
@@ -21,10 +23,36 @@ type CgroupsConfig struct {
 	CPUPeriod int    // CPU 周期（微秒），默认 100000（100ms）
 	MemoryMax int64  // 内存上限（字节），0=不限制。536870912=512MB
 	PidsMax   int    // 最大进程数，0=不限制
-	BaseDir   string // cgroup2 挂载点，默认 "/sys/fs/cgroup"
+	BaseDir   string // cgroup2 挂载点或委派的子树（见 DelegatedBaseDir），默认 "/sys/fs/cgroup"
+
+	// 父 cgroup 层级（租户、工作流等），沙箱创建在 BaseDir/Parents[0]/.../sandbox-<id>
+	Parents []CgroupParent
+
+	// 内存软限制与保护（memory 控制器）
+	MemoryHigh     int64 // 软上限（字节），超过后限流并强制回收而不是 OOM Kill，0=不设置
+	MemorySwapMax  int64 // swap 上限（字节），0=不设置（继承），MemorySwapNone=禁止使用 swap
+	MemoryLow      int64 // 尽力保护的内存量（字节），0=不设置
+	MemoryMin      int64 // 硬保护的内存量（字节），0=不设置
+	MemoryOOMGroup bool  // OOM 时杀死 cgroup 内所有进程，不留下残缺的进程树
+
+	// 块设备 I/O 限制（io 控制器）
+	IOLimits []IOLimit // 按设备的带宽/IOPS 上限（io.max）
+	IOWeight int       // 相对权重 1-10000（io.weight），0=不设置（内核默认 100）
+
+	// CPU/内存节点绑定（cpuset 控制器），可由 CPUSetAllocator 分配
+	CPUSetCPUs string // 允许使用的 CPU（cpuset.cpus），如 "0-3,6"，空=继承父 cgroup
+	CPUSetMems string // 允许使用的 NUMA 内存节点（cpuset.mems），如 "0"，空=继承父 cgroup
+
+	// 设备访问控制（BPF_PROG_TYPE_CGROUP_DEVICE）
+	DeviceFilter bool         // 挂载设备白名单 BPF 程序（内核不支持 BPF_PROG_TYPE_CGROUP_DEVICE 时跳过并记录警告）
+	Devices      []DeviceRule // 设备白名单（nil 则使用 DefaultDeviceRules()）
 }
 
+// MemorySwapNone 用于 CgroupsConfig.MemorySwapMax，表示禁止使用 swap（memory.swap.max=0）。
+const MemorySwapNone int64 = -1
+
 // DefaultCgroupsConfig 返回默认配置：1核 CPU、512MB 内存、512 进程。
+// 设备白名单默认启用；软上限、swap 限制、内存保护和整组 OOM 默认不设置，由调用方按需开启。
 func DefaultCgroupsConfig() CgroupsConfig {
 	return CgroupsConfig{
 		Enabled:   true,
@@ -33,6 +61,9 @@ func DefaultCgroupsConfig() CgroupsConfig {
 		MemoryMax: 536870912, // 512MB
 		PidsMax:   512,
 		BaseDir:   "/sys/fs/cgroup",
+
+		DeviceFilter: true,
+		Devices:      nil, // nil 表示使用 DefaultDeviceRules()
 	}
 }
 
@@ -43,6 +74,33 @@ func CgroupsV2Available() bool {
 	return err == nil
 }
 
+// Cgroups 是沙箱 cgroup 后端的公共接口，由 CgroupsV2（统一层级）和 CgroupsV1 实现。
+// 事件监视、PSI、冻结和运行时 Update 等 v2 专有功能需要断言为 *CgroupsV2。
+type Cgroups interface {
+	Setup() error
+	AddProcess(pid int) error
+	Kill() error
+	Cleanup() error
+	ID() string
+	SetLogger(l *zap.Logger)
+}
+
+// NewCgroups 根据宿主机自动选择 cgroup 后端：
+// BaseDir（默认 /sys/fs/cgroup）是 cgroup2 挂载点时使用 CgroupsV2；
+// 使用默认 BaseDir 且宿主机只有 v1 层级时使用 CgroupsV1。
+// 都不满足时返回 CgroupsV2，由 Setup 报告 v2 不可用。
+func NewCgroups(config CgroupsConfig) Cgroups {
+	baseDir := config.BaseDir
+	if baseDir == "" {
+		baseDir = "/sys/fs/cgroup"
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "cgroup.controllers")); err != nil &&
+		baseDir == "/sys/fs/cgroup" && CgroupsV1Available() {
+		return NewCgroupsV1(config)
+	}
+	return NewCgroupsV2(config)
+}
+
 // CgroupsV2 管理单个沙箱的 cgroups v2 生命周期。
 //
 // 使用方式：
@@ -56,6 +114,7 @@ type CgroupsV2 struct {
 	logger    *zap.Logger
 	id        string // 唯一标识，复用 generateID()
 	cgroupDir string // /sys/fs/cgroup/sandbox-<id>/
+	events    *eventWatcher
 	setupDone bool
 	mu        sync.Mutex
 }
@@ -93,9 +152,11 @@ func (cg *CgroupsV2) CgroupDir() string {
 // 执行步骤：
 //  1. 验证配置
 //  2. 检测 cgroups v2 可用性
-//  3. 生成 ID、创建 cgroup 目录
-//  4. 启用所需控制器
-//  5. 写入 cpu.max / memory.max / pids.max
+//  3. 逐级创建父 cgroup、启用所需控制器并写入各级限制
+//  4. 生成 ID、创建 cgroup 目录
+//  5. 写入 cpuset.* / cpu.max / memory.* / pids.max / io.max / io.weight
+//  6. 挂载设备白名单 BPF 程序（可选）
+//  7. 启动 OOM / pids.max / 进程进出事件监视
 func (cg *CgroupsV2) Setup() error {
 	cg.mu.Lock()
 	defer cg.mu.Unlock()
@@ -108,18 +169,11 @@ func (cg *CgroupsV2) Setup() error {
 		return fmt.Errorf("cgroups: not enabled")
 	}
 
-	// 验证配置值
-	if cg.config.CPUQuota < 0 {
-		return fmt.Errorf("cgroups: cpu quota must be non-negative, got %d", cg.config.CPUQuota)
-	}
-	if cg.config.CPUPeriod < 0 {
-		return fmt.Errorf("cgroups: cpu period must be non-negative, got %d", cg.config.CPUPeriod)
-	}
-	if cg.config.MemoryMax < 0 {
-		return fmt.Errorf("cgroups: memory max must be non-negative, got %d", cg.config.MemoryMax)
+	if err := cg.config.validate(); err != nil {
+		return err
 	}
-	if cg.config.PidsMax < 0 {
-		return fmt.Errorf("cgroups: pids max must be non-negative, got %d", cg.config.PidsMax)
+	if err := validateParents(cg.config.Parents); err != nil {
+		return err
 	}
 
 	// 检测 cgroups v2 可用性
@@ -133,37 +187,63 @@ func (cg *CgroupsV2) Setup() error {
 		return fmt.Errorf("cgroups: v2 not available (cannot stat %s): %w", controllersPath, err)
 	}
 
-	// 确定需要启用的控制器
-	var controllers []string
-	if cg.config.CPUQuota > 0 {
-		controllers = append(controllers, "cpu")
+	// 创建父 cgroup 链并生成沙箱目录。
+	// 另一个沙箱的 Cleanup 可能恰好删除了刚变空的共享父 cgroup（ENOENT），此时重建整条链
+	cg.id = generateID()
+	for attempt := 0; ; attempt++ {
+		parentDir, err := cg.setupParents(baseDir)
+		if err != nil {
+			return err
+		}
+		cg.cgroupDir = filepath.Join(parentDir, "sandbox-"+cg.id)
+		err = os.Mkdir(cg.cgroupDir, 0755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) || attempt >= 2 {
+			return fmt.Errorf("cgroups: mkdir %s: %w", cg.cgroupDir, err)
+		}
 	}
-	if cg.config.MemoryMax > 0 {
-		controllers = append(controllers, "memory")
+
+	// 写入资源限制，失败时回滚
+	writes, err := cg.config.limitWrites(nil, nil)
+	if err == nil {
+		err = cg.applyWrites(cg.cgroupDir, writes)
 	}
-	if cg.config.PidsMax > 0 {
-		controllers = append(controllers, "pids")
+	if err != nil {
+		os.Remove(cg.cgroupDir)
+		cg.cleanupParents()
+		return err
 	}
 
-	// 启用控制器
-	if len(controllers) > 0 {
-		if err := enableControllers(baseDir, controllers); err != nil {
-			return fmt.Errorf("cgroups: enable controllers: %w", err)
+	// 挂载设备过滤程序：即使进程获得 CAP_MKNOD，也无法创建或打开白名单外的设备。
+	// 内核无法加载设备过滤程序时退回到没有设备策略的 cgroup，而不是让每个沙箱都启动失败
+	if cg.config.DeviceFilter {
+		if err := deviceFilterSupport(); err != nil {
+			if cg.logger != nil {
+				cg.logger.Warn("cgroup device filter not supported by the kernel, running without it",
+					zap.String("cgroup_id", cg.id), zap.Error(err))
+			}
+		} else {
+			rules := cg.config.Devices
+			if rules == nil {
+				rules = DefaultDeviceRules()
+			}
+			if err := attachDeviceFilter(cg.cgroupDir, rules); err != nil {
+				os.Remove(cg.cgroupDir)
+				cg.cleanupParents()
+				return fmt.Errorf("cgroups: device filter: %w", err)
+			}
 		}
 	}
 
-	// 生成 ID 和创建目录
-	cg.id = generateID()
-	cg.cgroupDir = filepath.Join(baseDir, "sandbox-"+cg.id)
-
-	if err := os.Mkdir(cg.cgroupDir, 0755); err != nil {
-		return fmt.Errorf("cgroups: mkdir %s: %w", cg.cgroupDir, err)
-	}
-
-	// 写入资源限制，失败时回滚
-	if err := cg.writeLimits(); err != nil {
-		os.Remove(cg.cgroupDir)
-		return err
+	// 事件监视失败不影响资源限制，只丢失通知
+	if w, err := startEventWatcher(cg.cgroupDir, cg.id, cg.logger); err != nil {
+		if cg.logger != nil {
+			cg.logger.Warn("cgroup event watcher not started", zap.String("cgroup_id", cg.id), zap.Error(err))
+		}
+	} else {
+		cg.events = w
 	}
 
 	cg.setupDone = true
@@ -171,49 +251,218 @@ func (cg *CgroupsV2) Setup() error {
 	if cg.logger != nil {
 		cg.logger.Info("cgroup setup",
 			zap.String("cgroup_id", cg.id),
+			zap.String("cgroup_dir", cg.cgroupDir),
 			zap.Int("cpu_quota", cg.config.CPUQuota),
 			zap.Int("cpu_period", cg.config.CPUPeriod),
 			zap.Int64("memory_max", cg.config.MemoryMax),
+			zap.Int64("memory_high", cg.config.MemoryHigh),
+			zap.Int64("memory_swap_max", cg.config.MemorySwapMax),
+			zap.Bool("memory_oom_group", cg.config.MemoryOOMGroup),
 			zap.Int("pids_max", cg.config.PidsMax),
+			zap.Int("io_limits", len(cg.config.IOLimits)),
+			zap.Int("io_weight", cg.config.IOWeight),
+			zap.String("cpuset_cpus", cg.config.CPUSetCPUs),
+			zap.String("cpuset_mems", cg.config.CPUSetMems),
+			zap.Bool("device_filter", cg.config.DeviceFilter),
 		)
 	}
 
 	return nil
 }
 
-// writeLimits 写入 CPU、内存、进程数限制到 cgroup 控制文件。
-func (cg *CgroupsV2) writeLimits() error {
-	// CPU 限制：cpu.max 格式为 "quota period"
-	if cg.config.CPUQuota > 0 {
-		period := cg.config.CPUPeriod
-		if period <= 0 {
-			period = 100000
+// validate 校验配置值。
+func (c *CgroupsConfig) validate() error {
+	if c.CPUQuota < 0 {
+		return fmt.Errorf("cgroups: cpu quota must be non-negative, got %d", c.CPUQuota)
+	}
+	if c.CPUPeriod < 0 {
+		return fmt.Errorf("cgroups: cpu period must be non-negative, got %d", c.CPUPeriod)
+	}
+	if c.MemoryMax < 0 {
+		return fmt.Errorf("cgroups: memory max must be non-negative, got %d", c.MemoryMax)
+	}
+	if c.PidsMax < 0 {
+		return fmt.Errorf("cgroups: pids max must be non-negative, got %d", c.PidsMax)
+	}
+	for _, v := range []struct {
+		name  string
+		value int64
+	}{{"memory high", c.MemoryHigh}, {"memory low", c.MemoryLow}, {"memory min", c.MemoryMin}} {
+		if v.value < 0 {
+			return fmt.Errorf("cgroups: %s must be non-negative, got %d", v.name, v.value)
+		}
+	}
+	if c.MemorySwapMax < 0 && c.MemorySwapMax != MemorySwapNone {
+		return fmt.Errorf("cgroups: memory swap max must be non-negative or MemorySwapNone, got %d", c.MemorySwapMax)
+	}
+	if c.IOWeight < 0 || c.IOWeight > 10000 {
+		return fmt.Errorf("cgroups: io weight must be in [1, 10000], got %d", c.IOWeight)
+	}
+	for _, v := range []struct{ name, value string }{{"cpuset cpus", c.CPUSetCPUs}, {"cpuset mems", c.CPUSetMems}} {
+		if v.value == "" {
+			continue
+		}
+		if _, err := parseCPUList(v.value); err != nil {
+			return fmt.Errorf("cgroups: %s: %w", v.name, err)
 		}
-		content := fmt.Sprintf("%d %d", cg.config.CPUQuota, period)
-		if err := writeFile(filepath.Join(cg.cgroupDir, "cpu.max"), content); err != nil {
-			return fmt.Errorf("cgroups: write cpu.max: %w", err)
+	}
+	return nil
+}
+
+// controllers 返回配置需要在父 cgroup 中启用的控制器。
+func (c *CgroupsConfig) controllers() []string {
+	var controllers []string
+	if c.CPUSetCPUs != "" || c.CPUSetMems != "" {
+		controllers = append(controllers, "cpuset")
+	}
+	if c.CPUQuota > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if c.usesMemory() {
+		controllers = append(controllers, "memory")
+	}
+	if c.PidsMax > 0 {
+		controllers = append(controllers, "pids")
+	}
+	if len(c.IOLimits) > 0 || c.IOWeight > 0 {
+		controllers = append(controllers, "io")
+	}
+	return controllers
+}
+
+// cgroupWrite 是一次对 cgroup 控制文件的写入。
+type cgroupWrite struct {
+	file     string
+	content  string
+	optional bool // 文件不存在时跳过（例如未启用 swap 记账时的 memory.swap.max）
+}
+
+// limitWrites 生成配置对应的控制文件写入序列（CPU 绑定、CPU、内存、进程数、I/O 限制）。
+//
+// reset 为 nil 时（Setup）只写入已设置的限制。
+// Update 时 reset 为 cgroup 中可用的控制器，这些控制器下未设置的限制也写入默认值
+// 以清除旧限制；prev 中存在而 c 中已移除的 io.max 设备恢复为不限制。
+func (c *CgroupsConfig) limitWrites(reset map[string]bool, prev []IOLimit) ([]cgroupWrite, error) {
+	var writes []cgroupWrite
+	add := func(file, content string) {
+		writes = append(writes, cgroupWrite{file: file, content: content})
+	}
+
+	// CPU/内存节点绑定：写入空行恢复为继承父 cgroup
+	for _, f := range []struct{ name, value string }{{"cpuset.cpus", c.CPUSetCPUs}, {"cpuset.mems", c.CPUSetMems}} {
+		if f.value != "" {
+			add(f.name, f.value)
+		} else if reset["cpuset"] {
+			add(f.name, "\n")
 		}
 	}
 
-	// 内存限制
-	if cg.config.MemoryMax > 0 {
-		content := strconv.FormatInt(cg.config.MemoryMax, 10)
-		if err := writeFile(filepath.Join(cg.cgroupDir, "memory.max"), content); err != nil {
-			return fmt.Errorf("cgroups: write memory.max: %w", err)
+	// CPU 限制：cpu.max 格式为 "quota period"
+	period := c.CPUPeriod
+	if period <= 0 {
+		period = 100000
+	}
+	if c.CPUQuota > 0 {
+		add("cpu.max", fmt.Sprintf("%d %d", c.CPUQuota, period))
+	} else if reset["cpu"] {
+		add("cpu.max", fmt.Sprintf("max %d", period))
+	}
+
+	// 内存限制与保护
+	if c.usesMemory() || reset["memory"] {
+		for _, f := range []struct {
+			name  string
+			value int64
+			unset string
+		}{
+			{"memory.max", c.MemoryMax, "max"},
+			{"memory.high", c.MemoryHigh, "max"},
+			{"memory.low", c.MemoryLow, "0"},
+			{"memory.min", c.MemoryMin, "0"},
+		} {
+			if f.value > 0 {
+				add(f.name, strconv.FormatInt(f.value, 10))
+			} else if reset["memory"] {
+				add(f.name, f.unset)
+			}
+		}
+
+		// swap 限制：内核未启用 swap 记账（swapaccount=0）时没有 memory.swap.max，
+		// 此时沙箱本来就不能单独使用 swap，跳过
+		switch {
+		case c.MemorySwapMax == MemorySwapNone:
+			writes = append(writes, cgroupWrite{file: "memory.swap.max", content: "0", optional: true})
+		case c.MemorySwapMax > 0:
+			writes = append(writes, cgroupWrite{file: "memory.swap.max", content: strconv.FormatInt(c.MemorySwapMax, 10), optional: true})
+		case reset["memory"]:
+			writes = append(writes, cgroupWrite{file: "memory.swap.max", content: "max", optional: true})
+		}
+
+		if c.MemoryOOMGroup && c.usesMemory() {
+			add("memory.oom.group", "1")
+		} else if reset["memory"] {
+			add("memory.oom.group", "0")
 		}
 	}
 
 	// 进程数限制
-	if cg.config.PidsMax > 0 {
-		content := strconv.Itoa(cg.config.PidsMax)
-		if err := writeFile(filepath.Join(cg.cgroupDir, "pids.max"), content); err != nil {
-			return fmt.Errorf("cgroups: write pids.max: %w", err)
+	if c.PidsMax > 0 {
+		add("pids.max", strconv.Itoa(c.PidsMax))
+	} else if reset["pids"] {
+		add("pids.max", "max")
+	}
+
+	// I/O 限制：io.max 每次写入一个设备
+	devices := make(map[string]bool)
+	for _, l := range c.IOLimits {
+		major, minor, err := resolveIODevice(l.Device)
+		if err != nil {
+			return nil, fmt.Errorf("cgroups: io limit: %w", err)
 		}
+		devices[fmt.Sprintf("%d:%d", major, minor)] = true
+		add("io.max", l.ioMaxLine(major, minor, reset != nil))
+	}
+	if reset["io"] {
+		for _, l := range prev {
+			major, minor, err := resolveIODevice(l.Device)
+			if err != nil || devices[fmt.Sprintf("%d:%d", major, minor)] {
+				continue
+			}
+			add("io.max", IOLimit{}.ioMaxLine(major, minor, true))
+		}
+	}
+	if c.IOWeight > 0 {
+		add("io.weight", fmt.Sprintf("default %d", c.IOWeight))
+	} else if reset["io"] {
+		add("io.weight", "default 100")
 	}
 
+	return writes, nil
+}
+
+// applyWrites 依次执行对 dir 中控制文件的写入。
+func (cg *CgroupsV2) applyWrites(dir string, writes []cgroupWrite) error {
+	for _, w := range writes {
+		err := writeFile(filepath.Join(dir, w.file), w.content)
+		if w.optional && errors.Is(err, os.ErrNotExist) {
+			if cg.logger != nil {
+				cg.logger.Warn("cgroup control file not available, skipped", zap.String("file", w.file))
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cgroups: write %s: %w", w.file, err)
+		}
+	}
 	return nil
 }
 
+// usesMemory 报告配置是否需要 memory 控制器。
+// 单独的 MemoryOOMGroup 不启用 memory 控制器（没有内存限制就不会触发 cgroup OOM）。
+func (c *CgroupsConfig) usesMemory() bool {
+	return c.MemoryMax > 0 || c.MemoryHigh > 0 || c.MemorySwapMax != 0 || c.MemoryLow > 0 || c.MemoryMin > 0
+}
+
 // AddProcess 将指定 PID 写入 cgroup.procs，将进程加入此 cgroup。
 // 失败是致命错误——资源限制失效意味着安全边界被突破。
 func (cg *CgroupsV2) AddProcess(pid int) error {
@@ -235,12 +484,79 @@ func (cg *CgroupsV2) AddProcess(pid int) error {
 	return nil
 }
 
+// Kill 用 SIGKILL 终止 cgroup 内的所有进程（包括脱离进程树的守护进程），并等待 cgroup 清空。
+func (cg *CgroupsV2) Kill() error {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	if !cg.setupDone {
+		return fmt.Errorf("cgroups: not set up")
+	}
+	return cg.kill()
+}
+
+// kill 是 Kill 的实现，调用方持有 cg.mu。
+func (cg *CgroupsV2) kill() error {
+	if err := killCgroup(cg.cgroupDir); err != nil {
+		return err
+	}
+	if cg.logger != nil {
+		cg.logger.Info("cgroup killed", zap.String("cgroup_id", cg.id))
+	}
+	return nil
+}
+
+// killCgroup 终止任意 cgroup v2 目录内的所有进程，并等待 cgroup.events 报告 populated 0。
+//
+// 优先使用 cgroup.kill（内核 5.14+），一次写入原子地杀死所有进程，不受 fork 竞争影响。
+// 旧内核上退回到先冻结 cgroup（阻止新的 fork）、再逐个 kill cgroup.procs 中的进程。
+func killCgroup(dir string) error {
+	err := writeFile(filepath.Join(dir, "cgroup.kill"), "1")
+	if errors.Is(err, os.ErrNotExist) {
+		err = killCgroupProcs(dir)
+	}
+	if err != nil {
+		return fmt.Errorf("cgroups: kill: %w", err)
+	}
+	if err := waitCgroupEvent(dir, "populated", "0", killTimeout); err != nil {
+		return fmt.Errorf("cgroups: kill: %w", err)
+	}
+	return nil
+}
+
+// killCgroupProcs 是没有 cgroup.kill 时的回退实现。
+// SIGKILL 可以终止冻结的进程，冻结只是防止 kill 期间有进程 fork 出新进程。
+func killCgroupProcs(dir string) error {
+	frozen := writeFile(filepath.Join(dir, "cgroup.freeze"), "1") == nil
+	if frozen {
+		defer writeFile(filepath.Join(dir, "cgroup.freeze"), "0")
+	}
+
+	deadline := time.Now().Add(killTimeout)
+	for {
+		pids := readPids(dir)
+		if len(pids) == 0 {
+			return nil
+		}
+		for _, pid := range pids {
+			// 进程可能已退出
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d processes survived SIGKILL in %s", len(pids), dir)
+		}
+		time.Sleep(freezePollInterval)
+	}
+}
+
 // Cleanup 清理 cgroup 资源。
 //
 // 执行步骤：
-//  1. 读取残留进程 PID
-//  2. 迁移残留进程到父 cgroup
-//  3. 删除 cgroup 目录（带单次重试，间隔 10ms）
+//  1. 杀死 cgroup 内残留的所有进程（不迁移到父 cgroup，避免脱离资源限制逃逸到宿主机）
+//  2. 等待 cgroup.events 报告 populated 0
+//  3. 停止事件监视（Events() 仍可读取历史）
+//  4. 删除 cgroup 目录（带单次重试，间隔 10ms）
+//  5. 自下而上删除已空的父 cgroup
 func (cg *CgroupsV2) Cleanup() error {
 	cg.mu.Lock()
 	defer cg.mu.Unlock()
@@ -253,19 +569,16 @@ func (cg *CgroupsV2) Cleanup() error {
 		cg.logger.Info("cgroup cleanup", zap.String("cgroup_id", cg.id))
 	}
 
-	baseDir := cg.config.BaseDir
-	if baseDir == "" {
-		baseDir = "/sys/fs/cgroup"
+	var killErr error
+	if len(readPids(cg.cgroupDir)) > 0 {
+		killErr = cg.kill()
 	}
-
-	// 读取残留进程并迁移到父 cgroup
-	pids := readPids(cg.cgroupDir)
-	if len(pids) > 0 {
-		parentProcs := filepath.Join(baseDir, "cgroup.procs")
-		for _, pid := range pids {
-			// 迁移失败不阻塞清理（进程可能已退出）
-			_ = writeFile(parentProcs, strconv.Itoa(pid))
-		}
+	if cg.events != nil {
+		cg.events.stop()
+	}
+	// 失败时保持 setupDone，调用方可以重试
+	if killErr != nil {
+		return killErr
 	}
 
 	// 删除 cgroup 目录（只能用 os.Remove，不能用 os.RemoveAll）
@@ -275,10 +588,10 @@ func (cg *CgroupsV2) Cleanup() error {
 		time.Sleep(10 * time.Millisecond)
 		err = os.Remove(cg.cgroupDir)
 		if err != nil && !os.IsNotExist(err) {
-			cg.setupDone = false
 			return fmt.Errorf("cgroups: rmdir %s: %w", cg.cgroupDir, err)
 		}
 	}
+	cg.cleanupParents()
 
 	cg.setupDone = false
 	return nil
@@ -299,8 +612,18 @@ func enableControllers(baseDir string, controllers []string) error {
 }
 
 // writeFile 写入内容到 cgroup 控制文件。
+// 不使用 O_CREATE：cgroupfs 中创建文件返回 EACCES，不存在的控制文件
+// （内核不支持或控制器未启用）应报告为 ENOENT。
 func writeFile(path, content string) error {
-	return os.WriteFile(path, []byte(content), 0644)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // readPids 读取 cgroup.procs 中的 PID 列表。
@@ -6,10 +6,14 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 // --- 配置和纯函数测试（不需要root） ---
@@ -34,6 +38,31 @@ func TestDefaultCgroupsConfig(t *testing.T) {
 	if cfg.BaseDir != "/sys/fs/cgroup" {
 		t.Errorf("expected BaseDir=/sys/fs/cgroup, got %q", cfg.BaseDir)
 	}
+	if !cfg.DeviceFilter {
+		t.Error("device filter should be enabled by default")
+	}
+	if cfg.MemoryHigh != 0 || cfg.MemorySwapMax != 0 || cfg.MemoryLow != 0 || cfg.MemoryMin != 0 || cfg.MemoryOOMGroup {
+		t.Errorf("expected no soft limits, swap limit, protection or oom group by default: %+v", cfg)
+	}
+}
+
+func TestCgroupsConfigUsesMemory(t *testing.T) {
+	tests := []struct {
+		cfg  CgroupsConfig
+		want bool
+	}{
+		{CgroupsConfig{}, false},
+		{CgroupsConfig{MemoryOOMGroup: true}, false},
+		{CgroupsConfig{MemoryMax: 1}, true},
+		{CgroupsConfig{MemoryHigh: 1}, true},
+		{CgroupsConfig{MemorySwapMax: MemorySwapNone}, true},
+		{CgroupsConfig{MemoryMin: 1}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.usesMemory(); got != tt.want {
+			t.Errorf("usesMemory(%+v) = %v, want %v", tt.cfg, got, tt.want)
+		}
+	}
 }
 
 func TestNewCgroupsV2(t *testing.T) {
@@ -81,6 +110,24 @@ func TestCgroupsSetupValidation(t *testing.T) {
 	if err := cg.Setup(); err == nil {
 		t.Error("expected error for negative pids max")
 	}
+
+	// 测试负 memory high / low / min 和非法 swap max
+	for _, cfg := range []CgroupsConfig{
+		{Enabled: true, MemoryHigh: -1},
+		{Enabled: true, MemoryLow: -1},
+		{Enabled: true, MemoryMin: -1},
+		{Enabled: true, MemorySwapMax: -2},
+	} {
+		if err := NewCgroupsV2(cfg).Setup(); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+
+	// 测试超出范围的 io weight
+	cg = NewCgroupsV2(CgroupsConfig{Enabled: true, IOWeight: 10001})
+	if err := cg.Setup(); err == nil {
+		t.Error("expected error for out of range io weight")
+	}
 }
 
 func TestCgroupsV2Available(t *testing.T) {
@@ -88,6 +135,33 @@ func TestCgroupsV2Available(t *testing.T) {
 	_ = CgroupsV2Available()
 }
 
+func TestCgroupsCleanupRetry(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sandbox-test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 目录非空时 rmdir 失败，模拟无法删除的 cgroup
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cg := &CgroupsV2{id: "test", cgroupDir: dir, setupDone: true}
+
+	if err := cg.Cleanup(); err == nil {
+		t.Fatal("expected rmdir error")
+	}
+	if !cg.setupDone {
+		t.Fatal("setupDone cleared after a failed Cleanup, retry would be a no-op")
+	}
+
+	os.Remove(filepath.Join(dir, "cgroup.procs"))
+	if err := cg.Cleanup(); err != nil {
+		t.Fatalf("retried Cleanup failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("cgroup dir %s not removed on retry", dir)
+	}
+}
+
 // --- 集成测试（需要 root + cgroups v2） ---
 
 // skipIfNoCgroupsV2 在不支持 cgroups v2 的环境中跳过测试。
@@ -337,6 +411,45 @@ func TestCgroupsMemoryLimit(t *testing.T) {
 	}
 }
 
+func TestCgroupsMemorySoftLimits(t *testing.T) {
+	skipIfNoCgroupsV2(t)
+
+	cfg := DefaultCgroupsConfig()
+	cfg.CPUQuota = 0
+	cfg.PidsMax = 0
+	cfg.MemoryHigh = 256 * 1024 * 1024
+	cfg.MemoryLow = 32 * 1024 * 1024
+	cfg.MemoryMin = 16 * 1024 * 1024
+	cfg.MemorySwapMax = MemorySwapNone
+	cfg.MemoryOOMGroup = true
+	cg := NewCgroupsV2(cfg)
+
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer cg.Cleanup()
+
+	want := map[string]string{
+		"memory.high":      "268435456",
+		"memory.low":       "33554432",
+		"memory.min":       "16777216",
+		"memory.oom.group": "1",
+	}
+	if _, err := os.Stat(filepath.Join(cg.CgroupDir(), "memory.swap.max")); err == nil {
+		want["memory.swap.max"] = "0"
+	}
+	for name, value := range want {
+		data, err := os.ReadFile(filepath.Join(cg.CgroupDir(), name))
+		if err != nil {
+			t.Errorf("read %s: %v", name, err)
+			continue
+		}
+		if got := strings.TrimSpace(string(data)); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
 func TestCgroupsPidsLimit(t *testing.T) {
 	skipIfNoCgroupsV2(t)
 
@@ -537,6 +650,88 @@ func TestCgroupsCleanupAfterCrash(t *testing.T) {
 	}
 }
 
+func TestCgroupsCleanupKillsDaemons(t *testing.T) {
+	base := cgroup2Mount(t)
+
+	cg := NewCgroupsV2(CgroupsConfig{Enabled: true, BaseDir: base})
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	// 不使用 PID Namespace：init 退出后，脱离进程树的守护进程仍然存活
+	ns := NewNamespace(NamespaceConfig{Mount: true})
+	ns.SetCgroupsV2(cg)
+	defer ns.Cleanup()
+	ns.Stdout, ns.Stderr = nil, nil
+	if err := ns.Start("sh", "-c", "setsid sleep 300 </dev/null >/dev/null 2>&1 & exit 0"); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if _, err := ns.Wait(); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+
+	dir := cg.CgroupDir()
+	var pids []int
+	for i := 0; i < 100 && len(pids) == 0; i++ {
+		pids = readPids(dir)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(pids) == 0 {
+		t.Fatal("expected the daemon to survive init exit")
+	}
+
+	if err := cg.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("cgroup dir %s should not exist after cleanup", dir)
+	}
+	// 残留进程被杀死而不是迁移到父 cgroup
+	for _, pid := range readPids(base) {
+		for _, daemon := range pids {
+			if pid == daemon {
+				t.Errorf("daemon %d was migrated to the parent cgroup", pid)
+			}
+		}
+	}
+}
+
+func TestKillCgroupProcsFallback(t *testing.T) {
+	base := cgroup2Mount(t)
+
+	dir := filepath.Join(base, "sandbox-killtest-"+generateID())
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(dir)
+
+	var cmds []*exec.Cmd
+	for i := 0; i < 3; i++ {
+		cmd := exec.Command("sleep", "300")
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+		if err := writeFile(filepath.Join(dir, "cgroup.procs"), strconv.Itoa(cmd.Process.Pid)); err != nil {
+			cmd.Process.Kill()
+			t.Fatal(err)
+		}
+	}
+
+	if err := killCgroupProcs(dir); err != nil {
+		t.Fatalf("killCgroupProcs failed: %v", err)
+	}
+	for _, cmd := range cmds {
+		cmd.Wait()
+		if cmd.ProcessState.Sys().(syscall.WaitStatus).Signal() != syscall.SIGKILL {
+			t.Errorf("process %d was not killed: %v", cmd.Process.Pid, cmd.ProcessState)
+		}
+	}
+	if pids := readPids(dir); len(pids) != 0 {
+		t.Errorf("cgroup still has processes: %v", pids)
+	}
+}
+
 func TestConcurrentCgroups(t *testing.T) {
 	skipIfNoCgroupsV2(t)
 
@@ -0,0 +1,322 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// OpenCgroupsV2 绑定到一个已存在的沙箱 cgroup（例如由另一个 ai-sandbox 进程创建），
+// 配置从 cgroup 控制文件中读回。
+//
+// 返回的实例可以调用 Stats、Freeze、Update 等方法；Cleanup 会删除该 cgroup，
+// 仅在需要销毁沙箱时调用。
+func OpenCgroupsV2(dir string) (*CgroupsV2, error) {
+	if _, err := os.Stat(filepath.Join(dir, "cgroup.procs")); err != nil {
+		return nil, fmt.Errorf("cgroups: open %s: %w", dir, err)
+	}
+	config, err := readCgroupLimits(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cgroups: open %s: %w", dir, err)
+	}
+	config.Enabled = true
+	config.BaseDir = filepath.Dir(dir)
+
+	return &CgroupsV2{
+		config:    config,
+		id:        strings.TrimPrefix(filepath.Base(dir), "sandbox-"),
+		cgroupDir: dir,
+		setupDone: true,
+	}, nil
+}
+
+// Config 返回当前资源限制配置的副本。
+func (cg *CgroupsV2) Config() CgroupsConfig {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+	return cg.config
+}
+
+// Update 在运行中的 cgroup 上应用新的资源限制。
+//
+// config 描述完整的目标限制：未设置（为 0）的限制被清除，而不是保留旧值。
+// 只修改部分限制时先用 Config() 取得当前配置再修改。
+// Enabled、BaseDir 和设备白名单不能通过 Update 修改。
+//
+// 所有控制文件写入前先保存旧值，任一写入失败时恢复已写入的文件，
+// 使 cgroup 保持在更新前的状态。
+func (cg *CgroupsV2) Update(config CgroupsConfig) error {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	if !cg.setupDone {
+		return fmt.Errorf("cgroups: not set up")
+	}
+
+	config.Enabled = cg.config.Enabled
+	config.BaseDir = cg.config.BaseDir
+	config.DeviceFilter = cg.config.DeviceFilter
+	config.Devices = cg.config.Devices
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	// 新限制可能需要此前未启用的控制器
+	if controllers := config.controllers(); len(controllers) > 0 {
+		if err := enableControllers(filepath.Dir(cg.cgroupDir), controllers); err != nil {
+			return fmt.Errorf("cgroups: enable controllers: %w", err)
+		}
+	}
+	available, err := readControllers(cg.cgroupDir)
+	if err != nil {
+		return fmt.Errorf("cgroups: %w", err)
+	}
+
+	writes, err := config.limitWrites(available, cg.config.IOLimits)
+	if err != nil {
+		return err
+	}
+	snapshot := cg.snapshotFiles(writes)
+	if err := cg.applyWrites(cg.cgroupDir, writes); err != nil {
+		cg.restoreFiles(snapshot, writes)
+		return err
+	}
+	cg.config = config
+
+	if cg.logger != nil {
+		cg.logger.Info("cgroup update",
+			zap.String("cgroup_id", cg.id),
+			zap.Int("cpu_quota", config.CPUQuota),
+			zap.Int("cpu_period", config.CPUPeriod),
+			zap.Int64("memory_max", config.MemoryMax),
+			zap.Int64("memory_high", config.MemoryHigh),
+			zap.Int64("memory_swap_max", config.MemorySwapMax),
+			zap.Bool("memory_oom_group", config.MemoryOOMGroup),
+			zap.Int("pids_max", config.PidsMax),
+			zap.Int("io_limits", len(config.IOLimits)),
+			zap.Int("io_weight", config.IOWeight),
+			zap.String("cpuset_cpus", config.CPUSetCPUs),
+			zap.String("cpuset_mems", config.CPUSetMems),
+		)
+	}
+	return nil
+}
+
+// snapshotFiles 读取 writes 涉及的控制文件的当前内容。
+func (cg *CgroupsV2) snapshotFiles(writes []cgroupWrite) map[string]string {
+	snapshot := make(map[string]string)
+	for _, w := range writes {
+		if _, ok := snapshot[w.file]; ok {
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(cg.cgroupDir, w.file)); err == nil {
+			snapshot[w.file] = strings.TrimSpace(string(data))
+		}
+	}
+	return snapshot
+}
+
+// restoreFiles 尽力将控制文件恢复为 snapshot 中的内容。
+// io.max 按设备逐行恢复，更新时新增的设备恢复为不限制。
+func (cg *CgroupsV2) restoreFiles(snapshot map[string]string, writes []cgroupWrite) {
+	for file, content := range snapshot {
+		path := filepath.Join(cg.cgroupDir, file)
+		if file != "io.max" {
+			if content == "" {
+				// 空的 cpuset.cpus/cpuset.mems：写入空行恢复为继承
+				content = "\n"
+			}
+			_ = writeFile(path, content)
+			continue
+		}
+		old := make(map[string]bool)
+		for _, line := range strings.Split(content, "\n") {
+			if dev, _, ok := strings.Cut(line, " "); ok {
+				old[dev] = true
+				_ = writeFile(path, line)
+			}
+		}
+		for _, w := range writes {
+			dev, _, _ := strings.Cut(w.content, " ")
+			if w.file == "io.max" && !old[dev] {
+				_ = writeFile(path, dev+" rbps=max wbps=max riops=max wiops=max")
+			}
+		}
+	}
+	if cg.logger != nil {
+		cg.logger.Warn("cgroup update failed, limits restored", zap.String("cgroup_id", cg.id))
+	}
+}
+
+// readControllers 读取 cgroup 中可用的控制器（cgroup.controllers）。
+func readControllers(dir string) (map[string]bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return nil, err
+	}
+	controllers := make(map[string]bool)
+	for _, c := range strings.Fields(string(data)) {
+		controllers[c] = true
+	}
+	return controllers, nil
+}
+
+// readCgroupLimits 从控制文件读回资源限制，不存在的文件（控制器未启用）视为未设置。
+func readCgroupLimits(dir string) (CgroupsConfig, error) {
+	var c CgroupsConfig
+	read := func(name string) (string, bool, error) {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimSpace(string(data)), true, nil
+	}
+	parse := func(name, v string) (int64, error) {
+		if v == "max" {
+			return 0, nil
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse %s: %w", name, err)
+		}
+		return n, nil
+	}
+
+	// cpuset.cpus/cpuset.mems：空表示继承父 cgroup
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{{"cpuset.cpus", &c.CPUSetCPUs}, {"cpuset.mems", &c.CPUSetMems}} {
+		v, _, err := read(f.name)
+		if err != nil {
+			return c, err
+		}
+		*f.dst = v
+	}
+
+	// cpu.max："quota period"，quota 为 "max" 表示不限制
+	if v, ok, err := read("cpu.max"); err != nil {
+		return c, err
+	} else if ok {
+		fields := strings.Fields(v)
+		if len(fields) != 2 {
+			return c, fmt.Errorf("parse cpu.max: %q", v)
+		}
+		quota, err := parse("cpu.max", fields[0])
+		if err != nil {
+			return c, err
+		}
+		period, err := parse("cpu.max", fields[1])
+		if err != nil {
+			return c, err
+		}
+		c.CPUQuota, c.CPUPeriod = int(quota), int(period)
+	}
+
+	for _, f := range []struct {
+		name string
+		dst  *int64
+	}{
+		{"memory.max", &c.MemoryMax},
+		{"memory.high", &c.MemoryHigh},
+		{"memory.low", &c.MemoryLow},
+		{"memory.min", &c.MemoryMin},
+	} {
+		v, ok, err := read(f.name)
+		if err != nil {
+			return c, err
+		}
+		if ok {
+			if *f.dst, err = parse(f.name, v); err != nil {
+				return c, err
+			}
+		}
+	}
+
+	// memory.swap.max："max" 为继承（0），"0" 为禁止 swap
+	if v, ok, err := read("memory.swap.max"); err != nil {
+		return c, err
+	} else if ok && v == "0" {
+		c.MemorySwapMax = MemorySwapNone
+	} else if ok {
+		if c.MemorySwapMax, err = parse("memory.swap.max", v); err != nil {
+			return c, err
+		}
+	}
+
+	oomGroup, _, err := read("memory.oom.group")
+	if err != nil {
+		return c, err
+	}
+	c.MemoryOOMGroup = oomGroup == "1"
+
+	if v, ok, err := read("pids.max"); err != nil {
+		return c, err
+	} else if ok {
+		n, err := parse("pids.max", v)
+		if err != nil {
+			return c, err
+		}
+		c.PidsMax = int(n)
+	}
+
+	// io.max：每行 "major:minor rbps=.. wbps=.. riops=.. wiops=.."
+	if v, ok, err := read("io.max"); err != nil {
+		return c, err
+	} else if ok && v != "" {
+		for _, line := range strings.Split(v, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			l := IOLimit{Device: fields[0]}
+			for _, kv := range fields[1:] {
+				key, value, _ := strings.Cut(kv, "=")
+				n, err := parse("io.max", value)
+				if err != nil {
+					return c, err
+				}
+				switch key {
+				case "rbps":
+					l.RBps = uint64(n)
+				case "wbps":
+					l.WBps = uint64(n)
+				case "riops":
+					l.RIOPS = uint64(n)
+				case "wiops":
+					l.WIOPS = uint64(n)
+				}
+			}
+			if l != (IOLimit{Device: l.Device}) {
+				c.IOLimits = append(c.IOLimits, l)
+			}
+		}
+	}
+
+	// io.weight："default N"（后面可能还有按设备的权重）
+	if v, ok, err := read("io.weight"); err != nil {
+		return c, err
+	} else if ok {
+		for _, line := range strings.Split(v, "\n") {
+			if w, found := strings.CutPrefix(line, "default "); found {
+				n, err := parse("io.weight", w)
+				if err != nil {
+					return c, err
+				}
+				c.IOWeight = int(n)
+			}
+		}
+	}
+
+	return c, nil
+}
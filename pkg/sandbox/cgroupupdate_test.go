@@ -0,0 +1,230 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================================================================
+// 资源限制更新单元测试（不需要 root）
+// ===================================================================
+
+func readFakeFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestOpenCgroupsV2ReadsLimits(t *testing.T) {
+	dir := writeCgroupFixture(t, map[string]string{
+		"cpu.max":          "200000 100000\n",
+		"memory.max":       "max\n",
+		"memory.high":      "1048576\n",
+		"memory.low":       "0\n",
+		"memory.min":       "0\n",
+		"memory.swap.max":  "0\n",
+		"memory.oom.group": "1\n",
+		"pids.max":         "64\n",
+		"io.max":           "8:0 rbps=max wbps=1048576 riops=max wiops=100\n",
+		"io.weight":        "default 50\n",
+		"cpuset.cpus":      "0-1\n",
+		"cpuset.mems":      "\n",
+	})
+	cg, err := OpenCgroupsV2(dir)
+	if err != nil {
+		t.Fatalf("OpenCgroupsV2 failed: %v", err)
+	}
+	if cg.ID() != "test" || cg.CgroupDir() != dir {
+		t.Errorf("unexpected id %q or dir %q", cg.ID(), cg.CgroupDir())
+	}
+
+	want := CgroupsConfig{
+		Enabled:        true,
+		BaseDir:        filepath.Dir(dir),
+		CPUQuota:       200000,
+		CPUPeriod:      100000,
+		MemoryHigh:     1048576,
+		MemorySwapMax:  MemorySwapNone,
+		MemoryOOMGroup: true,
+		PidsMax:        64,
+		IOLimits:       []IOLimit{{Device: "8:0", WBps: 1048576, WIOPS: 100}},
+		IOWeight:       50,
+		CPUSetCPUs:     "0-1",
+	}
+	if got := cg.Config(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Config() = %+v, want %+v", got, want)
+	}
+
+	if _, err := OpenCgroupsV2(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for a missing cgroup")
+	}
+	bad := writeCgroupFixture(t, map[string]string{"cpu.max": "garbage\n"})
+	if _, err := OpenCgroupsV2(bad); err == nil {
+		t.Error("expected error for an unparsable cpu.max")
+	}
+}
+
+func TestLimitWritesReset(t *testing.T) {
+	cfg := CgroupsConfig{MemoryMax: 1024, IOLimits: []IOLimit{{Device: "8:0", RBps: 1}}}
+	reset := map[string]bool{"cpuset": true, "cpu": true, "memory": true, "pids": true, "io": true}
+	prev := []IOLimit{{Device: "8:0", WBps: 2}, {Device: "8:16", WBps: 2}}
+	writes, err := cfg.limitWrites(reset, prev)
+	if err != nil {
+		t.Fatalf("limitWrites failed: %v", err)
+	}
+
+	got := make(map[string][]string)
+	for _, w := range writes {
+		got[w.file] = append(got[w.file], w.content)
+	}
+	want := map[string][]string{
+		"cpuset.cpus":      {"\n"},
+		"cpuset.mems":      {"\n"},
+		"cpu.max":          {"max 100000"},
+		"memory.max":       {"1024"},
+		"memory.high":      {"max"},
+		"memory.low":       {"0"},
+		"memory.min":       {"0"},
+		"memory.swap.max":  {"max"},
+		"memory.oom.group": {"0"},
+		"pids.max":         {"max"},
+		"io.max":           {"8:0 rbps=1 wbps=max riops=max wiops=max", "8:16 rbps=max wbps=max riops=max wiops=max"},
+		"io.weight":        {"default 100"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("limitWrites =\n%v\nwant\n%v", got, want)
+	}
+
+	// Setup（reset 为 nil）只写入已设置的限制
+	writes, _ = cfg.limitWrites(nil, nil)
+	if len(writes) != 2 {
+		t.Errorf("expected memory.max and io.max only, got %+v", writes)
+	}
+}
+
+func TestCgroupsUpdate(t *testing.T) {
+	dir := writeCgroupFixture(t, map[string]string{
+		"cgroup.controllers": "cpu memory pids\n",
+		"cpu.max":            "100000 100000\n",
+		"memory.max":         "536870912\n",
+		"memory.high":        "max\n",
+		"memory.low":         "0\n",
+		"memory.min":         "0\n",
+		"memory.oom.group":   "0\n",
+		"pids.max":           "512\n",
+	})
+	cg, err := OpenCgroupsV2(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := cg.Config()
+	cfg.CPUQuota = 200000
+	cfg.MemoryMax = 2 << 30
+	cfg.PidsMax = 0
+	if err := cg.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	for name, want := range map[string]string{
+		"cpu.max":    "200000 100000",
+		"memory.max": "2147483648",
+		"pids.max":   "max",
+	} {
+		if got := readFakeFile(t, dir, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if got := readFakeFile(t, filepath.Dir(dir), "cgroup.subtree_control"); got != "+cpu +memory" {
+		t.Errorf("cgroup.subtree_control = %q", got)
+	}
+	if cg.Config().CPUQuota != 200000 {
+		t.Errorf("config not updated: %+v", cg.Config())
+	}
+
+	if err := cg.Update(CgroupsConfig{CPUQuota: -1}); err == nil {
+		t.Error("expected validation error")
+	}
+	if cg.Config().CPUQuota != 200000 {
+		t.Errorf("config changed after a failed update: %+v", cg.Config())
+	}
+}
+
+func TestCgroupsUpdateRollback(t *testing.T) {
+	dir := writeCgroupFixture(t, map[string]string{
+		"cgroup.controllers": "cpu memory pids\n",
+		"cpu.max":            "100000 100000\n",
+		"memory.max":         "536870912\n",
+		"memory.high":        "max\n",
+		"memory.low":         "0\n",
+		"memory.min":         "0\n",
+		"memory.oom.group":   "0\n",
+	})
+	cg, err := OpenCgroupsV2(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pids.max 是目录，写入失败，模拟内核拒绝新限制
+	if err := os.Mkdir(filepath.Join(dir, "pids.max"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := cg.Config()
+	cfg.CPUQuota = 300000
+	cfg.MemoryMax = 1 << 30
+	cfg.PidsMax = 100
+	if err := cg.Update(cfg); err == nil {
+		t.Fatal("expected Update to fail")
+	}
+	if got := readFakeFile(t, dir, "cpu.max"); got != "100000 100000" {
+		t.Errorf("cpu.max not restored: %q", got)
+	}
+	if got := readFakeFile(t, dir, "memory.max"); got != "536870912" {
+		t.Errorf("memory.max not restored: %q", got)
+	}
+	if cg.Config().CPUQuota != 100000 {
+		t.Errorf("config changed after a failed update: %+v", cg.Config())
+	}
+}
+
+// ===================================================================
+// 资源限制更新集成测试（需要 root + cgroups v2）
+// ===================================================================
+
+func TestCgroupsUpdateLive(t *testing.T) {
+	skipIfNoCgroupsV2(t)
+
+	cg := NewCgroupsV2(DefaultCgroupsConfig())
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer cg.Cleanup()
+
+	opened, err := OpenCgroupsV2(cg.CgroupDir())
+	if err != nil {
+		t.Fatalf("OpenCgroupsV2 failed: %v", err)
+	}
+	cfg := opened.Config()
+	if cfg.CPUQuota != 100000 || cfg.MemoryMax != 536870912 || cfg.PidsMax != 512 {
+		t.Errorf("unexpected limits read back: %+v", cfg)
+	}
+
+	cfg.CPUQuota = 200000
+	cfg.MemoryMax = 1 << 30
+	if err := opened.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := readFakeFile(t, cg.CgroupDir(), "cpu.max"); got != "200000 100000" {
+		t.Errorf("cpu.max = %q", got)
+	}
+	if got := readFakeFile(t, cg.CgroupDir(), "memory.max"); got != "1073741824" {
+		t.Errorf("memory.max = %q", got)
+	}
+}
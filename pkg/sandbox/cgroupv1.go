@@ -0,0 +1,534 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// cgroupV1Controllers 是 CgroupsV1 使用的 v1 层级。
+var cgroupV1Controllers = []string{"cpu", "cpuacct", "memory", "pids", "devices"}
+
+// cgroupV1AccountingControllers 是资源记账读取的 v1 层级（cpuacct.usage、memory.usage_in_bytes）。
+// 挂载时总是加入，即使配置没有对应的限制；不写入任何控制文件。
+var cgroupV1AccountingControllers = []string{"cpuacct", "memory"}
+
+// CgroupsV1 管理单个沙箱在 cgroup v1（每个控制器一个层级）中的生命周期，
+// 用于尚未迁移到统一层级的宿主机。通常通过 NewCgroups 自动选择。
+//
+// 与 CgroupsV2 使用相同的 CgroupsConfig，字段映射为：
+//
+//	CPUQuota/CPUPeriod  cpu.cfs_quota_us / cpu.cfs_period_us
+//	MemoryMax           memory.limit_in_bytes
+//	MemorySwapMax       memory.memsw.limit_in_bytes（v1 限制的是内存+swap 总量）
+//	MemoryHigh          memory.soft_limit_in_bytes（只在宿主机内存紧张时回收到该值，不限流）
+//	PidsMax             pids.max
+//	DeviceFilter        devices.deny / devices.allow
+//
+// v1 没有对应机制的 MemoryLow、MemoryMin、IOLimits、IOWeight、CPUSetCPUs、CPUSetMems
+// 设置后 Setup 返回错误；MemoryOOMGroup 被忽略（v1 的 OOM Killer 只杀死单个进程，
+// Cleanup 仍会杀死所有残留进程）。OOM 事件监视、PSI、冻结和运行时 Update 只在 v2 上可用。
+//
+// 沙箱在每个使用的层级中创建目录 <mount>/<Parents...>/sandbox-<id>，进程加入所有层级。
+// pids 层级总是被使用（即使没有进程数限制），用于跟踪和杀死沙箱内的所有进程；
+// cpuacct 和 memory 层级挂载时总是被使用，供资源记账统计 CPU 时间和内存用量。
+type CgroupsV1 struct {
+	config    CgroupsConfig
+	logger    *zap.Logger
+	id        string
+	dirs      map[string]string // 控制器 → 沙箱在该层级中的目录
+	setupDone bool
+	mu        sync.Mutex
+}
+
+// NewCgroupsV1 创建 CgroupsV1 管理器实例。
+func NewCgroupsV1(config CgroupsConfig) *CgroupsV1 {
+	return &CgroupsV1{
+		config: config,
+	}
+}
+
+// CgroupsV1Available 检测系统是否挂载了 cgroup v1 的 cpu、memory 和 pids 层级。
+func CgroupsV1Available() bool {
+	mounts, err := cgroupV1Mounts()
+	if err != nil {
+		return false
+	}
+	for _, c := range []string{"cpu", "memory", "pids"} {
+		if mounts[c] == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// SetLogger 设置此CgroupsV1的日志记录器。
+func (cg *CgroupsV1) SetLogger(l *zap.Logger) {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+	cg.logger = l
+}
+
+// ID 返回 CgroupsV1 实例的唯一标识符。Setup() 之前返回空字符串。
+func (cg *CgroupsV1) ID() string {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+	return cg.id
+}
+
+// CgroupDirs 返回控制器到沙箱 cgroup 目录的映射。Setup() 之前返回 nil。
+func (cg *CgroupsV1) CgroupDirs() map[string]string {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+	if cg.dirs == nil {
+		return nil
+	}
+	dirs := make(map[string]string, len(cg.dirs))
+	for c, d := range cg.dirs {
+		dirs[c] = d
+	}
+	return dirs
+}
+
+// Setup 在各个 v1 层级中创建 cgroup 目录并写入资源限制。
+//
+// 执行步骤：
+//  1. 验证配置（拒绝 v1 不支持的限制）
+//  2. 从 /proc/self/mountinfo 查找所需层级的挂载点
+//  3. 在每个层级中逐级创建父 cgroup 并写入各级限制
+//  4. 生成 ID、创建沙箱 cgroup 目录并写入限制，任一步失败时回滚已创建的目录
+func (cg *CgroupsV1) Setup() error {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	if cg.setupDone {
+		return fmt.Errorf("cgroups: already set up")
+	}
+
+	if !cg.config.Enabled {
+		return fmt.Errorf("cgroups: not enabled")
+	}
+
+	if err := cg.config.validateV1(); err != nil {
+		return err
+	}
+	if err := validateParents(cg.config.Parents); err != nil {
+		return err
+	}
+	for _, p := range cg.config.Parents {
+		if err := p.Limits.validateV1(); err != nil {
+			return fmt.Errorf("cgroups: parent %s: %w", p.Name, err)
+		}
+	}
+
+	mounts, err := cgroupV1Mounts()
+	if err != nil {
+		return fmt.Errorf("cgroups: v1: %w", err)
+	}
+	controllers := cg.config.controllersV1()
+	for _, p := range cg.config.Parents {
+		controllers = append(controllers, p.Limits.controllersV1()...)
+	}
+	var missing []string
+	for _, c := range controllers {
+		if mounts[c] == "" {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("cgroups: v1 hierarchies not mounted: %s", strings.Join(missing, ", "))
+	}
+	for _, c := range cgroupV1AccountingControllers {
+		if mounts[c] != "" {
+			controllers = append(controllers, c)
+		}
+	}
+
+	cg.id = generateID()
+	cg.dirs = make(map[string]string)
+	for _, h := range groupV1Hierarchies(controllers, mounts) {
+		dir, err := cg.setupHierarchy(h.controllers, h.mount)
+		if err != nil {
+			cg.removeDirs()
+			return err
+		}
+		for _, c := range h.controllers {
+			cg.dirs[c] = dir
+		}
+	}
+
+	if cg.config.MemoryOOMGroup && cg.config.usesMemory() && cg.logger != nil {
+		cg.logger.Warn("memory oom group not supported on cgroup v1, ignored", zap.String("cgroup_id", cg.id))
+	}
+
+	cg.setupDone = true
+
+	if cg.logger != nil {
+		cg.logger.Info("cgroup setup",
+			zap.String("cgroup_id", cg.id),
+			zap.String("cgroup_version", "v1"),
+			zap.String("cgroup_dir", cg.dirs["pids"]),
+			zap.Int("cpu_quota", cg.config.CPUQuota),
+			zap.Int("cpu_period", cg.config.CPUPeriod),
+			zap.Int64("memory_max", cg.config.MemoryMax),
+			zap.Int64("memory_high", cg.config.MemoryHigh),
+			zap.Int64("memory_swap_max", cg.config.MemorySwapMax),
+			zap.Int("pids_max", cg.config.PidsMax),
+			zap.Bool("device_filter", cg.config.DeviceFilter),
+		)
+	}
+
+	return nil
+}
+
+// v1Hierarchy 是一个 v1 层级的挂载点及其中被使用的控制器。
+type v1Hierarchy struct {
+	mount       string
+	controllers []string
+}
+
+// groupV1Hierarchies 按挂载点对 controllers 分组并去重，保持首次出现的顺序。
+// 共同挂载在同一层级的控制器（如 "cpu,memory"）共用一个沙箱目录，目录只能创建一次。
+func groupV1Hierarchies(controllers []string, mounts map[string]string) []v1Hierarchy {
+	var hierarchies []v1Hierarchy
+	index := make(map[string]int)
+	for _, c := range controllers {
+		i, ok := index[mounts[c]]
+		if !ok {
+			i = len(hierarchies)
+			index[mounts[c]] = i
+			hierarchies = append(hierarchies, v1Hierarchy{mount: mounts[c]})
+		}
+		if !slices.Contains(hierarchies[i].controllers, c) {
+			hierarchies[i].controllers = append(hierarchies[i].controllers, c)
+		}
+	}
+	return hierarchies
+}
+
+// setupHierarchy 在挂载于 mount 的层级中创建父 cgroup 链和沙箱 cgroup，
+// 并写入挂载在该层级的控制器 controllers 的限制，返回沙箱 cgroup 目录。
+// cg.dirs 中尚未记录此层级，失败时由本函数回滚。
+func (cg *CgroupsV1) setupHierarchy(controllers []string, mount string) (string, error) {
+	writes := func(c *CgroupsConfig) []cgroupWrite {
+		var w []cgroupWrite
+		for _, controller := range controllers {
+			w = append(w, c.limitWritesV1(controller)...)
+		}
+		return w
+	}
+
+	dir := mount
+	for _, p := range cg.config.Parents {
+		dir = filepath.Join(dir, p.Name)
+		if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+			cg.cleanupParents(filepath.Join(dir, "sandbox-"+cg.id))
+			return "", fmt.Errorf("cgroups: mkdir %s: %w", dir, err)
+		}
+		if err := cg.applyWrites(dir, writes(&p.Limits)); err != nil {
+			cg.cleanupParents(filepath.Join(dir, "sandbox-"+cg.id))
+			return "", fmt.Errorf("cgroups: parent %s: %w", p.Name, err)
+		}
+	}
+
+	dir = filepath.Join(dir, "sandbox-"+cg.id)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		cg.cleanupParents(dir)
+		return "", fmt.Errorf("cgroups: mkdir %s: %w", dir, err)
+	}
+	if err := cg.applyWrites(dir, writes(&cg.config)); err != nil {
+		os.Remove(dir)
+		cg.cleanupParents(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// validateV1 在 validate 的基础上拒绝 cgroup v1 中无法实施的限制。
+func (c *CgroupsConfig) validateV1() error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	for _, v := range []struct {
+		name string
+		set  bool
+	}{
+		{"memory low", c.MemoryLow > 0},
+		{"memory min", c.MemoryMin > 0},
+		{"io limits", len(c.IOLimits) > 0},
+		{"io weight", c.IOWeight > 0},
+		{"cpuset", c.CPUSetCPUs != "" || c.CPUSetMems != ""},
+	} {
+		if v.set {
+			return fmt.Errorf("cgroups: %s is not supported on cgroup v1", v.name)
+		}
+	}
+	if c.MemorySwapMax > 0 && c.MemoryMax == 0 {
+		return fmt.Errorf("cgroups: memory swap max requires memory max on cgroup v1")
+	}
+	return nil
+}
+
+// controllersV1 返回配置使用的 v1 层级。pids 总是包含在内，用于跟踪沙箱内的进程。
+func (c *CgroupsConfig) controllersV1() []string {
+	var controllers []string
+	if c.CPUQuota > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if c.usesMemory() {
+		controllers = append(controllers, "memory")
+	}
+	controllers = append(controllers, "pids")
+	if c.DeviceFilter {
+		controllers = append(controllers, "devices")
+	}
+	return controllers
+}
+
+// limitWritesV1 生成配置在层级 controller 中的控制文件写入序列。
+func (c *CgroupsConfig) limitWritesV1(controller string) []cgroupWrite {
+	var writes []cgroupWrite
+	add := func(file, content string) {
+		writes = append(writes, cgroupWrite{file: file, content: content})
+	}
+
+	switch controller {
+	case "cpu":
+		// 先写周期：配额不能超过内核对周期的约束
+		if c.CPUQuota > 0 {
+			period := c.CPUPeriod
+			if period <= 0 {
+				period = 100000
+			}
+			add("cpu.cfs_period_us", strconv.Itoa(period))
+			add("cpu.cfs_quota_us", strconv.Itoa(c.CPUQuota))
+		}
+
+	case "memory":
+		// memory.memsw.limit_in_bytes 不能小于 memory.limit_in_bytes，必须后写。
+		// 内核未启用 swap 记账时没有 memsw 文件，此时退回到禁止换出（swappiness 0）
+		if c.MemoryMax > 0 {
+			add("memory.limit_in_bytes", strconv.FormatInt(c.MemoryMax, 10))
+		}
+		if c.MemoryHigh > 0 {
+			add("memory.soft_limit_in_bytes", strconv.FormatInt(c.MemoryHigh, 10))
+		}
+		switch {
+		case c.MemorySwapMax == MemorySwapNone && c.MemoryMax > 0:
+			writes = append(writes, cgroupWrite{file: "memory.memsw.limit_in_bytes", content: strconv.FormatInt(c.MemoryMax, 10), optional: true})
+			writes = append(writes, cgroupWrite{file: "memory.swappiness", content: "0", optional: true})
+		case c.MemorySwapMax == MemorySwapNone:
+			writes = append(writes, cgroupWrite{file: "memory.swappiness", content: "0", optional: true})
+		case c.MemorySwapMax > 0:
+			writes = append(writes, cgroupWrite{file: "memory.memsw.limit_in_bytes", content: strconv.FormatInt(c.MemoryMax+c.MemorySwapMax, 10), optional: true})
+		}
+
+	case "pids":
+		if c.PidsMax > 0 {
+			add("pids.max", strconv.Itoa(c.PidsMax))
+		}
+
+	case "devices":
+		// 先拒绝所有设备，再逐条加入白名单
+		if c.DeviceFilter {
+			rules := c.Devices
+			if rules == nil {
+				rules = DefaultDeviceRules()
+			}
+			add("devices.deny", "a")
+			for _, r := range rules {
+				add("devices.allow", r.String())
+			}
+		}
+	}
+	return writes
+}
+
+// applyWrites 依次执行对 dir 中控制文件的写入。
+func (cg *CgroupsV1) applyWrites(dir string, writes []cgroupWrite) error {
+	for _, w := range writes {
+		err := writeFile(filepath.Join(dir, w.file), w.content)
+		if w.optional && errors.Is(err, os.ErrNotExist) {
+			if cg.logger != nil {
+				cg.logger.Warn("cgroup control file not available, skipped", zap.String("file", w.file))
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cgroups: write %s: %w", w.file, err)
+		}
+	}
+	return nil
+}
+
+// AddProcess 将指定 PID 写入每个层级的 cgroup.procs。
+// 失败是致命错误——资源限制失效意味着安全边界被突破。
+func (cg *CgroupsV1) AddProcess(pid int) error {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	if !cg.setupDone {
+		return fmt.Errorf("cgroups: not set up")
+	}
+
+	for _, dir := range cg.uniqueDirs() {
+		if err := writeFile(filepath.Join(dir, "cgroup.procs"), strconv.Itoa(pid)); err != nil {
+			return fmt.Errorf("cgroups: add process to %s: %w", dir, err)
+		}
+	}
+
+	if cg.logger != nil {
+		cg.logger.Info("cgroup add process", zap.String("cgroup_id", cg.id), zap.Int("pid", pid))
+	}
+	return nil
+}
+
+// Kill 用 SIGKILL 终止沙箱内的所有进程（包括脱离进程树的守护进程），并等待 cgroup 清空。
+func (cg *CgroupsV1) Kill() error {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	if !cg.setupDone {
+		return fmt.Errorf("cgroups: not set up")
+	}
+	return cg.kill()
+}
+
+// kill 是 Kill 的实现，调用方持有 cg.mu。
+// v1 没有 cgroup.kill 和 cgroup.events，逐个杀死 pids 层级中的进程直到 cgroup.procs 为空。
+func (cg *CgroupsV1) kill() error {
+	if err := killCgroupProcs(cg.dirs["pids"]); err != nil {
+		return fmt.Errorf("cgroups: kill: %w", err)
+	}
+	if cg.logger != nil {
+		cg.logger.Info("cgroup killed", zap.String("cgroup_id", cg.id))
+	}
+	return nil
+}
+
+// Cleanup 杀死残留进程并删除各层级中的 cgroup 目录和已空的父 cgroup。
+func (cg *CgroupsV1) Cleanup() error {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	if !cg.setupDone {
+		return nil
+	}
+
+	if cg.logger != nil {
+		cg.logger.Info("cgroup cleanup", zap.String("cgroup_id", cg.id))
+	}
+
+	// 失败时保持 setupDone，调用方可以重试
+	if len(readPids(cg.dirs["pids"])) > 0 {
+		if err := cg.kill(); err != nil {
+			return err
+		}
+	}
+	if err := cg.removeDirs(); err != nil {
+		return err
+	}
+	cg.setupDone = false
+	return nil
+}
+
+// removeDirs 删除已创建的沙箱 cgroup 目录及已空的父 cgroup，返回第一个错误。
+func (cg *CgroupsV1) removeDirs() error {
+	var firstErr error
+	for _, dir := range cg.uniqueDirs() {
+		// 只能用 os.Remove，不能用 os.RemoveAll
+		err := os.Remove(dir)
+		if err != nil && !os.IsNotExist(err) {
+			// 单次重试：僵尸进程可能尚未完全回收
+			time.Sleep(10 * time.Millisecond)
+			err = os.Remove(dir)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("cgroups: rmdir %s: %w", dir, err)
+			}
+			continue
+		}
+		cg.cleanupParents(dir)
+	}
+	return firstErr
+}
+
+// cleanupParents 自下而上删除沙箱目录 dir 之上已空的父 cgroup。
+// 仍有其他沙箱（EBUSY）或标记为 Keep 的父 cgroup 及其上级保留。
+func (cg *CgroupsV1) cleanupParents(dir string) {
+	dir = filepath.Dir(dir)
+	for i := len(cg.config.Parents) - 1; i >= 0; i-- {
+		if cg.config.Parents[i].Keep || os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// uniqueDirs 返回已创建的沙箱 cgroup 目录，共同挂载的控制器只出现一次，按路径排序。
+func (cg *CgroupsV1) uniqueDirs() []string {
+	dirs := make([]string, 0, len(cg.dirs))
+	for _, d := range cg.dirs {
+		if !slices.Contains(dirs, d) {
+			dirs = append(dirs, d)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// cgroupV1Mounts 从 /proc/self/mountinfo 中查找 cgroupV1Controllers 的挂载点。
+// 多个控制器可能挂载在同一层级（例如 "cpu,cpuacct"），此时返回相同的目录。
+func cgroupV1Mounts() (map[string]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCgroupV1Mounts(bufio.NewScanner(f))
+}
+
+// parseCgroupV1Mounts 解析 mountinfo 中 fstype 为 cgroup 的行，super options 中列出层级的控制器：
+//
+//	33 32 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,relatime - cgroup cgroup rw,cpu,cpuacct
+func parseCgroupV1Mounts(scanner *bufio.Scanner) (map[string]string, error) {
+	wanted := make(map[string]bool)
+	for _, c := range cgroupV1Controllers {
+		wanted[c] = true
+	}
+	mounts := make(map[string]string)
+	for scanner.Scan() {
+		line := scanner.Text()
+		_, post, ok := strings.Cut(line, " - ")
+		if !ok {
+			continue
+		}
+		postFields := strings.Fields(post)
+		fields := strings.Fields(line)
+		if len(postFields) < 3 || postFields[0] != "cgroup" || len(fields) < 5 {
+			continue
+		}
+		for _, opt := range strings.Split(postFields[2], ",") {
+			if wanted[opt] && mounts[opt] == "" {
+				mounts[opt] = fields[4]
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
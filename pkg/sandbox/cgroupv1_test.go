@@ -0,0 +1,288 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================================================================
+// cgroup v1 单元测试（不需要 root）
+// ===================================================================
+
+func TestParseCgroupV1Mounts(t *testing.T) {
+	mountinfo := `32 24 0:28 / /sys/fs/cgroup rw,relatime - tmpfs tmpfs rw,mode=755
+33 32 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid shared:9 - cgroup cgroup rw,cpu,cpuacct
+36 32 0:32 / /sys/fs/cgroup/memory rw,relatime - cgroup cgroup rw,memory
+40 32 0:36 / /sys/fs/cgroup/pids rw,relatime - cgroup cgroup rw,pids
+41 32 0:37 / /sys/fs/cgroup/systemd rw,relatime - cgroup cgroup rw,xattr,name=systemd
+42 32 0:38 / /sys/fs/cgroup/unified rw,relatime - cgroup2 cgroup2 rw
+`
+	mounts, err := parseCgroupV1Mounts(bufio.NewScanner(strings.NewReader(mountinfo)))
+	if err != nil {
+		t.Fatalf("parseCgroupV1Mounts failed: %v", err)
+	}
+	want := map[string]string{
+		"cpu":     "/sys/fs/cgroup/cpu,cpuacct",
+		"cpuacct": "/sys/fs/cgroup/cpu,cpuacct",
+		"memory":  "/sys/fs/cgroup/memory",
+		"pids":    "/sys/fs/cgroup/pids",
+	}
+	if !reflect.DeepEqual(mounts, want) {
+		t.Errorf("mounts = %v, want %v", mounts, want)
+	}
+}
+
+func TestCgroupsV1Validate(t *testing.T) {
+	for _, cfg := range []CgroupsConfig{
+		{Enabled: true, MemoryLow: 1 << 20},
+		{Enabled: true, MemoryMin: 1 << 20},
+		{Enabled: true, IOWeight: 50},
+		{Enabled: true, IOLimits: []IOLimit{{Device: "/", RBps: 1 << 20}}},
+		{Enabled: true, CPUSetCPUs: "0"},
+		{Enabled: true, MemorySwapMax: 1 << 20},
+		{Enabled: true, CPUQuota: -1},
+	} {
+		if err := cfg.validateV1(); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+
+	// 默认配置加上 MemoryHigh、禁止 swap、整组 OOM 在 v1 上可用
+	cfg := DefaultCgroupsConfig()
+	cfg.MemoryHigh = cfg.MemoryMax / 10 * 9
+	cfg.MemorySwapMax = MemorySwapNone
+	cfg.MemoryOOMGroup = true
+	if err := cfg.validateV1(); err != nil {
+		t.Errorf("default config rejected: %v", err)
+	}
+
+	cg := NewCgroupsV1(CgroupsConfig{Enabled: true, IOWeight: 50})
+	if err := cg.Setup(); err == nil || !strings.Contains(err.Error(), "not supported on cgroup v1") {
+		t.Errorf("expected unsupported error from Setup, got %v", err)
+	}
+}
+
+func TestCgroupsV1LimitWrites(t *testing.T) {
+	cfg := CgroupsConfig{
+		CPUQuota:      50000,
+		MemoryMax:     100 << 20,
+		MemoryHigh:    90 << 20,
+		MemorySwapMax: 50 << 20,
+		PidsMax:       64,
+		DeviceFilter:  true,
+		Devices:       []DeviceRule{{Type: 'c', Major: 1, Minor: 3, Access: "rwm"}},
+	}
+	files := func(controller string) []string {
+		var got []string
+		for _, w := range cfg.limitWritesV1(controller) {
+			got = append(got, w.file+"="+w.content)
+		}
+		return got
+	}
+
+	for controller, want := range map[string][]string{
+		"cpu":     {"cpu.cfs_period_us=100000", "cpu.cfs_quota_us=50000"},
+		"memory":  {"memory.limit_in_bytes=104857600", "memory.soft_limit_in_bytes=94371840", "memory.memsw.limit_in_bytes=157286400"},
+		"pids":    {"pids.max=64"},
+		"devices": {"devices.deny=a", "devices.allow=c 1:3 rwm"},
+	} {
+		if got := files(controller); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s writes = %v, want %v", controller, got, want)
+		}
+	}
+
+	cfg = CgroupsConfig{MemoryMax: 100 << 20, MemorySwapMax: MemorySwapNone}
+	want := []string{"memory.limit_in_bytes=104857600", "memory.memsw.limit_in_bytes=104857600", "memory.swappiness=0"}
+	if got := files("memory"); !reflect.DeepEqual(got, want) {
+		t.Errorf("swap none writes = %v, want %v", got, want)
+	}
+
+	if got := (&CgroupsConfig{}).controllersV1(); !reflect.DeepEqual(got, []string{"pids"}) {
+		t.Errorf("controllers for empty config = %v, want [pids]", got)
+	}
+}
+
+func TestGroupV1Hierarchies(t *testing.T) {
+	mounts := map[string]string{
+		"cpu":     "/sys/fs/cgroup/cpu,memory",
+		"memory":  "/sys/fs/cgroup/cpu,memory",
+		"pids":    "/sys/fs/cgroup/pids",
+		"devices": "/sys/fs/cgroup/devices",
+	}
+	got := groupV1Hierarchies([]string{"cpu", "memory", "pids", "devices", "pids", "memory"}, mounts)
+	want := []v1Hierarchy{
+		{mount: "/sys/fs/cgroup/cpu,memory", controllers: []string{"cpu", "memory"}},
+		{mount: "/sys/fs/cgroup/pids", controllers: []string{"pids"}},
+		{mount: "/sys/fs/cgroup/devices", controllers: []string{"devices"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupV1Hierarchies = %+v, want %+v", got, want)
+	}
+
+	// 共同挂载的控制器共用一个目录，清理时只删除一次
+	shared := filepath.Join(t.TempDir(), "sandbox-test")
+	if err := os.Mkdir(shared, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cg := &CgroupsV1{id: "test", dirs: map[string]string{"cpu": shared, "memory": shared}, setupDone: true}
+	if dirs := cg.uniqueDirs(); !reflect.DeepEqual(dirs, []string{shared}) {
+		t.Errorf("uniqueDirs = %v, want [%s]", dirs, shared)
+	}
+	if err := cg.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+}
+
+func TestNewCgroupsSelectsV2(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.controllers"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := NewCgroups(CgroupsConfig{Enabled: true, BaseDir: dir}).(*CgroupsV2); !ok {
+		t.Error("expected CgroupsV2 for a cgroup2 BaseDir")
+	}
+	// 显式指定的非默认 BaseDir 不回退到 v1
+	if _, ok := NewCgroups(CgroupsConfig{Enabled: true, BaseDir: t.TempDir()}).(*CgroupsV2); !ok {
+		t.Error("expected CgroupsV2 for a non-default BaseDir")
+	}
+}
+
+func TestCgroupsV1CleanupRetry(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sandbox-test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 目录非空时 rmdir 失败，模拟无法删除的 cgroup
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cg := &CgroupsV1{id: "test", dirs: map[string]string{"pids": dir}, setupDone: true}
+
+	if err := cg.Cleanup(); err == nil {
+		t.Fatal("expected rmdir error")
+	}
+	if !cg.setupDone {
+		t.Fatal("setupDone cleared after a failed Cleanup, retry would be a no-op")
+	}
+
+	os.Remove(filepath.Join(dir, "cgroup.procs"))
+	if err := cg.Cleanup(); err != nil {
+		t.Fatalf("retried Cleanup failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("cgroup dir %s not removed on retry", dir)
+	}
+}
+
+// ===================================================================
+// cgroup v1 集成测试（需要 root + cgroup v1 层级）
+// ===================================================================
+
+// skipIfNoCgroupsV1 在没有 cgroup v1 层级的环境中跳过测试。
+func skipIfNoCgroupsV1(t *testing.T) {
+	t.Helper()
+	skipIfNotRoot(t)
+	if !CgroupsV1Available() {
+		t.Skip("skipping: requires cgroup v1 cpu, memory and pids hierarchies")
+	}
+}
+
+func TestNewCgroupsSelectsV1(t *testing.T) {
+	skipIfNoCgroupsV1(t)
+	if CgroupsV2Available() {
+		t.Skip("skipping: host uses the unified hierarchy")
+	}
+	if _, ok := NewCgroups(DefaultCgroupsConfig()).(*CgroupsV1); !ok {
+		t.Error("expected CgroupsV1 on a cgroup v1 host")
+	}
+}
+
+func TestCgroupsV1SetupAndCleanup(t *testing.T) {
+	skipIfNoCgroupsV1(t)
+
+	cfg := DefaultCgroupsConfig()
+	cfg.CPUQuota = 50000
+	cfg.MemoryHigh = cfg.MemoryMax / 10 * 9
+	cfg.PidsMax = 32
+	cfg.Parents = []CgroupParent{{Name: "ai-sandbox-test-v1", Limits: CgroupsConfig{PidsMax: 64}}}
+	cg := NewCgroupsV1(cfg)
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer cg.Cleanup()
+
+	dirs := cg.CgroupDirs()
+	for _, c := range []string{"cpu", "cpuacct", "memory", "pids", "devices"} {
+		if !strings.HasSuffix(dirs[c], "/ai-sandbox-test-v1/sandbox-"+cg.ID()) {
+			t.Errorf("%s dir = %q", c, dirs[c])
+		}
+	}
+	for file, want := range map[string]string{
+		filepath.Join(dirs["cpu"], "cpu.cfs_quota_us"):         "50000",
+		filepath.Join(dirs["memory"], "memory.limit_in_bytes"): "536870912",
+		filepath.Join(dirs["pids"], "pids.max"):                "32",
+		filepath.Join(filepath.Dir(dirs["pids"]), "pids.max"):  "64",
+		filepath.Join(dirs["devices"], "devices.list"):         "c 1:3 rwm",
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("read %s: %v", file, err)
+			continue
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, want %q", file, strings.TrimSpace(string(data)), want)
+		}
+	}
+
+	if err := cg.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	for c, dir := range dirs {
+		if _, err := os.Stat(filepath.Dir(dir)); !os.IsNotExist(err) {
+			t.Errorf("%s parent cgroup %s not removed", c, filepath.Dir(dir))
+		}
+	}
+}
+
+func TestCgroupsV1WithNamespace(t *testing.T) {
+	skipIfNoCgroupsV1(t)
+
+	cfg := DefaultCgroupsConfig()
+	cfg.PidsMax = 8
+	cg := NewCgroupsV1(cfg)
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	// 不使用 PID namespace：init 退出时后台进程不会被内核一并杀死
+	ns := NewNamespace(NamespaceConfig{Mount: true})
+	ns.SetCgroups(cg)
+	defer ns.Cleanup()
+	ns.Stdout, ns.Stderr = nil, nil
+
+	// 后台残留进程必须在 Cleanup 时被杀死
+	pidsDir := cg.CgroupDirs()["pids"]
+	result, err := ns.Execute("sh", "-c", "sleep 60 & exit 7")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.ExitCode != 7 {
+		t.Errorf("exit code = %d, want 7", result.ExitCode)
+	}
+	if pids := readPids(pidsDir); len(pids) == 0 {
+		t.Error("expected the background process in the pids hierarchy")
+	}
+
+	if err := ns.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if _, err := os.Stat(pidsDir); !os.IsNotExist(err) {
+		t.Errorf("cgroup %s not removed", pidsDir)
+	}
+}
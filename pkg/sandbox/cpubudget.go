@@ -0,0 +1,129 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TerminationReason 是沙箱进程结束的原因。
+type TerminationReason string
+
+const (
+	TerminationExit      TerminationReason = "exit"       // 进程自行退出（或被信号终止）
+	TerminationCPUBudget TerminationReason = "cpu_budget" // CPU 时间预算耗尽，沙箱被杀死
+
+	// TerminationCPUBudgetFrozen 表示预算耗尽时沙箱按 Freeze 被冻结，之后由调用方杀死或恢复后退出。
+	TerminationCPUBudgetFrozen TerminationReason = "cpu_budget_frozen"
+)
+
+// CPUBudgetConfig 定义沙箱生命周期内累计 CPU 时间的上限。
+//
+// 与 CPUQuota 限制 CPU 使用速率不同，预算限制的是总量；与墙钟超时不同，
+// 阻塞在 I/O 或等待网络的沙箱不消耗预算。需要 CgroupsV2（读取 cpu.stat 的 usage_usec）。
+type CPUBudgetConfig struct {
+	Enabled  bool          // 是否启用
+	Budget   time.Duration // 累计 CPU 时间上限（所有 CPU 上用户态 + 内核态时间之和）
+	Freeze   bool          // 耗尽时冻结沙箱而不是杀死，由调用方检查后决定 Kill 或 Resume
+	Interval time.Duration // cpu.stat 采样间隔，默认 100ms；超出预算的量最多为一个间隔内的 CPU 时间
+}
+
+// DefaultCPUBudgetConfig 返回默认配置：预算耗尽时杀死沙箱，每 100ms 采样一次。
+func DefaultCPUBudgetConfig(budget time.Duration) CPUBudgetConfig {
+	return CPUBudgetConfig{
+		Enabled:  true,
+		Budget:   budget,
+		Interval: 100 * time.Millisecond,
+	}
+}
+
+func (c *CPUBudgetConfig) validate() error {
+	if c.Budget <= 0 {
+		return fmt.Errorf("cpu budget must be positive, got %v", c.Budget)
+	}
+	if c.Interval < 0 {
+		return fmt.Errorf("cpu budget interval must be non-negative, got %v", c.Interval)
+	}
+	return nil
+}
+
+// SetCPUBudget 设置 CPU 时间预算。
+// 必须在Start()之前调用，并且需要通过 SetCgroups 绑定 CgroupsV2。
+// 预算耗尽时杀死沙箱，Wait() 返回的 ExecResult.Termination 为 TerminationCPUBudget；
+// 按 Freeze 冻结时为 TerminationCPUBudgetFrozen（无论调用方之后杀死还是恢复沙箱）。
+func (ns *Namespace) SetCPUBudget(cfg *CPUBudgetConfig) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.cpuBudgetConfig = cfg
+}
+
+// CPUBudgetExhausted 返回一个channel，在 CPU 时间预算耗尽、沙箱被杀死或冻结时关闭。
+// Start() 之前或未设置预算时返回 nil。
+func (ns *Namespace) CPUBudgetExhausted() <-chan struct{} {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.budgetExhausted
+}
+
+// watchCPUBudget 每隔 interval 读取 cpu.stat 的 usage_usec，达到预算时将结束原因写入 reason、
+// 关闭 exhausted 并杀死或冻结沙箱，直到 done 被关闭。
+// 冻结后不再检查：调用方 Resume 后沙箱不受预算限制。
+func watchCPUBudget(cg *CgroupsV2, cfg CPUBudgetConfig, done <-chan struct{}, exhausted chan<- struct{}, reason *TerminationReason, logger *zap.Logger) {
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	statPath := filepath.Join(cg.CgroupDir(), "cpu.stat")
+	budget := uint64(cfg.Budget.Microseconds())
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		var usage uint64
+		readKeyValueFile(statPath, func(key string, v uint64) {
+			if key == "usage_usec" {
+				usage = v
+			}
+		})
+		if usage < budget {
+			continue
+		}
+
+		// 先写入原因并关闭 exhausted：杀死后 Wait() 可能立即返回
+		*reason = TerminationCPUBudget
+		if cfg.Freeze {
+			*reason = TerminationCPUBudgetFrozen
+		}
+		close(exhausted)
+		action, err := "killed", error(nil)
+		if cfg.Freeze {
+			action, err = "frozen", cg.Freeze()
+		} else {
+			err = cg.Kill()
+		}
+		if logger != nil {
+			fields := []zap.Field{
+				zap.String("cgroup_id", cg.ID()),
+				zap.Duration("cpu_budget", cfg.Budget),
+				zap.Duration("cpu_usage", time.Duration(usage)*time.Microsecond),
+				zap.String("action", action),
+			}
+			if err != nil {
+				logger.Error("cpu budget exhausted, enforcement failed", append(fields, zap.Error(err))...)
+			} else {
+				logger.Warn("cpu budget exhausted", fields...)
+			}
+		}
+		return
+	}
+}
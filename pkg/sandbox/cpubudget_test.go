@@ -0,0 +1,218 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ===================================================================
+// CPU 时间预算单元测试（不需要 root）
+// ===================================================================
+
+func TestCPUBudgetConfigValidate(t *testing.T) {
+	cfg := DefaultCPUBudgetConfig(time.Minute)
+	if err := cfg.validate(); err != nil {
+		t.Errorf("default config rejected: %v", err)
+	}
+	for _, cfg := range []CPUBudgetConfig{
+		{Enabled: true},
+		{Enabled: true, Budget: -time.Second},
+		{Enabled: true, Budget: time.Second, Interval: -time.Second},
+	} {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestCPUBudgetRequiresCgroupsV2(t *testing.T) {
+	ns := NewNamespace(NamespaceConfig{})
+	cfg := DefaultCPUBudgetConfig(time.Second)
+	ns.SetCPUBudget(&cfg)
+	err := ns.Start("true")
+	if err == nil {
+		ns.Cleanup()
+		t.Fatal("expected error without cgroups")
+	}
+	if !strings.Contains(err.Error(), "requires cgroups v2") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if ns.CPUBudgetExhausted() != nil {
+		t.Error("CPUBudgetExhausted should be nil before a successful Start")
+	}
+}
+
+func TestWatchCPUBudgetFixture(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("cpu.stat", "usage_usec 400000\nuser_usec 300000\nsystem_usec 100000\n")
+	write("cgroup.events", "populated 0\nfrozen 0\n")
+	write("cgroup.procs", "")
+	cg := &CgroupsV2{cgroupDir: dir, setupDone: true}
+
+	done := make(chan struct{})
+	exhausted := make(chan struct{})
+	exited := make(chan struct{})
+	var reason TerminationReason
+	go func() {
+		watchCPUBudget(cg, CPUBudgetConfig{Enabled: true, Budget: 500 * time.Millisecond, Interval: 10 * time.Millisecond}, done, exhausted, &reason, nil)
+		close(exited)
+	}()
+
+	select {
+	case <-exhausted:
+		t.Fatal("budget exhausted below the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	write("cpu.stat", "usage_usec 500000\nuser_usec 400000\nsystem_usec 100000\n")
+	select {
+	case <-exhausted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("budget not exhausted at the limit")
+	}
+	if reason != TerminationCPUBudget {
+		t.Errorf("reason = %q, want %q", reason, TerminationCPUBudget)
+	}
+	select {
+	case <-exited:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not exit after enforcing the budget")
+	}
+	close(done)
+}
+
+// ===================================================================
+// CPU 时间预算集成测试（需要 root）
+// ===================================================================
+
+// budgetNamespace 在 cgroup v2 中创建一个设置了 CPU 时间预算的 Namespace。
+func budgetNamespace(t *testing.T, cfg CPUBudgetConfig) (*Namespace, *CgroupsV2) {
+	t.Helper()
+	base := cgroup2Mount(t)
+
+	cg := NewCgroupsV2(CgroupsConfig{Enabled: true, BaseDir: base})
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	ns := NewNamespace(NamespaceConfig{Mount: true, PID: true, MountProc: true})
+	ns.SetCgroups(cg)
+	ns.SetCPUBudget(&cfg)
+	ns.Stdout, ns.Stderr = nil, nil
+	t.Cleanup(func() { ns.Cleanup() })
+	return ns, cg
+}
+
+func TestCPUBudgetKill(t *testing.T) {
+	cfg := DefaultCPUBudgetConfig(300 * time.Millisecond)
+	cfg.Interval = 20 * time.Millisecond
+	ns, _ := budgetNamespace(t, cfg)
+
+	start := time.Now()
+	result, err := ns.Execute("sh", "-c", "while :; do :; done")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Termination != TerminationCPUBudget {
+		t.Errorf("termination = %q, want %q", result.Termination, TerminationCPUBudget)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("busy loop ran for %v, budget not enforced", elapsed)
+	}
+}
+
+func TestCPUBudgetNotChargedWhileBlocked(t *testing.T) {
+	cfg := DefaultCPUBudgetConfig(200 * time.Millisecond)
+	cfg.Interval = 20 * time.Millisecond
+	ns, _ := budgetNamespace(t, cfg)
+
+	// 睡眠 1 秒的墙钟时间远超预算，但几乎不消耗 CPU
+	result, err := ns.Execute("sleep", "1")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Termination != TerminationExit || result.ExitCode != 0 {
+		t.Errorf("result = %+v, want a normal exit", result)
+	}
+}
+
+func TestCPUBudgetFreeze(t *testing.T) {
+	cfg := DefaultCPUBudgetConfig(200 * time.Millisecond)
+	cfg.Interval = 20 * time.Millisecond
+	cfg.Freeze = true
+	ns, cg := budgetNamespace(t, cfg)
+
+	if err := ns.Start("sh", "-c", "while :; do :; done"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	select {
+	case <-ns.CPUBudgetExhausted():
+	case <-time.After(10 * time.Second):
+		t.Fatal("budget not exhausted")
+	}
+
+	// 冻结在 exhausted 关闭之后完成
+	deadline := time.Now().Add(freezeTimeout)
+	for ns.State() != StatePaused {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want %s", ns.State(), StatePaused)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := cg.Kill(); err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
+	result, err := ns.Wait()
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if result.Termination != TerminationCPUBudgetFrozen {
+		t.Errorf("termination = %q, want %q", result.Termination, TerminationCPUBudgetFrozen)
+	}
+}
+
+func TestCPUBudgetFreezeResumed(t *testing.T) {
+	cfg := DefaultCPUBudgetConfig(200 * time.Millisecond)
+	cfg.Interval = 20 * time.Millisecond
+	cfg.Freeze = true
+	ns, _ := budgetNamespace(t, cfg)
+
+	// 预算耗尽后被冻结，恢复后自行退出
+	if err := ns.Start("sh", "-c", "i=0; while [ $i -lt 1000000 ]; do i=$((i+1)); done; exit 4"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	select {
+	case <-ns.CPUBudgetExhausted():
+	case <-time.After(10 * time.Second):
+		t.Fatal("budget not exhausted")
+	}
+	deadline := time.Now().Add(freezeTimeout)
+	for ns.State() != StatePaused {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want %s", ns.State(), StatePaused)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := ns.Resume(); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	result, err := ns.Wait()
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if result.ExitCode != 4 || result.Termination != TerminationCPUBudgetFrozen {
+		t.Errorf("result = %+v, want exit code 4 with %q", result, TerminationCPUBudgetFrozen)
+	}
+}
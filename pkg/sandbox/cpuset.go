@@ -0,0 +1,326 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultCPUSetDir 是 CPU 分配状态的默认目录（tmpfs，重启后清空）。
+const DefaultCPUSetDir = "/run/ai-sandbox/cpuset"
+
+// CPUSetAllocation 是一次 CPU 分配的结果，CPUs/Mems 分别用于
+// CgroupsConfig.CPUSetCPUs 和 CgroupsConfig.CPUSetMems。
+type CPUSetAllocation struct {
+	CPUs      string // 分配的 CPU 列表，如 "4-7"
+	Mems      string // 这些 CPU 所在的 NUMA 内存节点，如 "0"
+	Exclusive bool   // 是否独占
+}
+
+// cpuLease 是持久化的一次分配（leases/<id>.json）。
+type cpuLease struct {
+	PID       int   `json:"pid"`
+	CPUs      []int `json:"cpus"`
+	Exclusive bool  `json:"exclusive"`
+}
+
+// CPUSetAllocator 在并发运行的沙箱之间（包括不同的 ai-sandbox 进程）分配 CPU 集合。
+//
+// 独占分配的 CPU 不再分给其他沙箱；共享分配只使用未被独占的 CPU，并优先选择共享者最少的 CPU。
+// 分配尽量落在同一 NUMA 节点内，内存节点随 CPU 一起确定。
+// 未绑定 CPU 的沙箱（CPUSetCPUs 为空）仍可能在独占 CPU 上运行，需要稳定计时的场景
+// 应让所有沙箱都通过分配器绑定 CPU。
+//
+// 目录结构：
+//
+//	<dir>/cpuset.lock        跨进程互斥锁（flock）
+//	<dir>/leases/<id>.json   分配记录（持有者 PID、CPU 列表、是否独占）
+//
+// 持有进程崩溃后残留的分配记录在下一次分配时回收。
+//
+// 使用方式：
+//
+//	alloc, err := sandbox.NewCPUSetAllocator(sandbox.DefaultCPUSetDir, "")
+//	cpus, release, err := alloc.Allocate(4, true)
+//	defer release()
+//	cgConfig.CPUSetCPUs, cgConfig.CPUSetMems = cpus.CPUs, cpus.Mems
+type CPUSetAllocator struct {
+	dir    string
+	pool   []int       // 可分配的 CPU，升序
+	nodes  map[int]int // CPU → NUMA 节点
+	logger *zap.Logger
+}
+
+// NewCPUSetAllocator 打开（必要时创建）位于 dir 的分配状态。
+// pool 为可分配的 CPU 列表（如 "2-15"），为空时使用当前进程允许运行的全部 CPU。
+func NewCPUSetAllocator(dir, pool string) (*CPUSetAllocator, error) {
+	if dir == "" {
+		dir = DefaultCPUSetDir
+	}
+	var cpus []int
+	if pool != "" {
+		var err error
+		if cpus, err = parseCPUList(pool); err != nil {
+			return nil, fmt.Errorf("cpuset: invalid pool: %w", err)
+		}
+	} else {
+		var set unix.CPUSet
+		if err := unix.SchedGetaffinity(0, &set); err != nil {
+			return nil, fmt.Errorf("cpuset: sched_getaffinity: %w", err)
+		}
+		for i := 0; i < len(set)*64; i++ {
+			if set.IsSet(i) {
+				cpus = append(cpus, i)
+			}
+		}
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("cpuset: empty pool")
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "leases"), 0700); err != nil {
+		return nil, fmt.Errorf("cpuset: mkdir %s: %w", dir, err)
+	}
+	return &CPUSetAllocator{dir: dir, pool: cpus, nodes: readCPUNodes(cpus)}, nil
+}
+
+// SetLogger 设置此CPUSetAllocator的日志记录器。
+func (a *CPUSetAllocator) SetLogger(l *zap.Logger) {
+	a.logger = l
+}
+
+// lock 获取跨进程的排他锁，返回解锁函数。
+func (a *CPUSetAllocator) lock() (func(), error) {
+	f, err := os.OpenFile(filepath.Join(a.dir, "cpuset.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cpuset: open lock: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cpuset: flock: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// Allocate 分配 n 个 CPU，返回分配结果和释放函数；释放函数应在沙箱 Cleanup 之后调用。
+func (a *CPUSetAllocator) Allocate(n int, exclusive bool) (CPUSetAllocation, func() error, error) {
+	if n <= 0 {
+		return CPUSetAllocation{}, nil, fmt.Errorf("cpuset: cpu count must be positive, got %d", n)
+	}
+
+	unlock, err := a.lock()
+	if err != nil {
+		return CPUSetAllocation{}, nil, err
+	}
+	defer unlock()
+
+	leases, err := a.leases()
+	if err != nil {
+		return CPUSetAllocation{}, nil, err
+	}
+	cpus, err := a.pick(n, exclusive, leases)
+	if err != nil {
+		return CPUSetAllocation{}, nil, err
+	}
+
+	id := generateID()
+	path := filepath.Join(a.dir, "leases", id+".json")
+	data, _ := json.Marshal(cpuLease{PID: os.Getpid(), CPUs: cpus, Exclusive: exclusive})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return CPUSetAllocation{}, nil, fmt.Errorf("cpuset: write lease: %w", err)
+	}
+
+	nodes := make([]int, 0, 1)
+	seen := make(map[int]bool)
+	for _, c := range cpus {
+		if node := a.nodes[c]; !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Ints(nodes)
+	alloc := CPUSetAllocation{CPUs: formatCPUList(cpus), Mems: formatCPUList(nodes), Exclusive: exclusive}
+
+	if a.logger != nil {
+		a.logger.Info("cpuset allocated",
+			zap.String("lease", id),
+			zap.String("cpus", alloc.CPUs),
+			zap.String("mems", alloc.Mems),
+			zap.Bool("exclusive", exclusive),
+		)
+	}
+
+	release := func() error {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cpuset: release: %w", err)
+		}
+		return nil
+	}
+	return alloc, release, nil
+}
+
+// leases 读取有效的分配记录，删除持有者已退出的记录。调用方必须持有锁。
+func (a *CPUSetAllocator) leases() ([]cpuLease, error) {
+	entries, err := os.ReadDir(filepath.Join(a.dir, "leases"))
+	if err != nil {
+		return nil, fmt.Errorf("cpuset: read leases: %w", err)
+	}
+	var leases []cpuLease
+	for _, e := range entries {
+		path := filepath.Join(a.dir, "leases", e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var l cpuLease
+		if json.Unmarshal(data, &l) != nil || !processAlive(l.PID) {
+			os.Remove(path)
+			continue
+		}
+		leases = append(leases, l)
+	}
+	return leases, nil
+}
+
+// pick 根据现有分配选择 n 个 CPU。
+//
+// 独占：只选择没有任何分配的 CPU，优先放在空闲 CPU 最少但足够的 NUMA 节点内（减少碎片）。
+// 共享：只选择未被独占的 CPU，按共享者数量、NUMA 节点、编号排序。
+func (a *CPUSetAllocator) pick(n int, exclusive bool, leases []cpuLease) ([]int, error) {
+	shared := make(map[int]int)
+	taken := make(map[int]bool)
+	for _, l := range leases {
+		for _, c := range l.CPUs {
+			if l.Exclusive {
+				taken[c] = true
+			} else {
+				shared[c]++
+			}
+		}
+	}
+
+	var free []int
+	for _, c := range a.pool {
+		if !taken[c] && (!exclusive || shared[c] == 0) {
+			free = append(free, c)
+		}
+	}
+	if len(free) < n {
+		kind := "shared"
+		if exclusive {
+			kind = "exclusive"
+		}
+		return nil, fmt.Errorf("cpuset: cannot allocate %d %s cpus, only %d available", n, kind, len(free))
+	}
+
+	if !exclusive {
+		sort.SliceStable(free, func(i, j int) bool {
+			ci, cj := free[i], free[j]
+			if shared[ci] != shared[cj] {
+				return shared[ci] < shared[cj]
+			}
+			return a.nodes[ci] < a.nodes[cj]
+		})
+		cpus := append([]int(nil), free[:n]...)
+		sort.Ints(cpus)
+		return cpus, nil
+	}
+
+	byNode := make(map[int][]int)
+	for _, c := range free {
+		byNode[a.nodes[c]] = append(byNode[a.nodes[c]], c)
+	}
+	best := -1
+	for node, cpus := range byNode {
+		if len(cpus) >= n && (best < 0 || len(cpus) < len(byNode[best]) ||
+			len(cpus) == len(byNode[best]) && node < best) {
+			best = node
+		}
+	}
+	if best >= 0 {
+		return byNode[best][:n], nil
+	}
+	// 单个节点放不下，跨节点分配
+	cpus := append([]int(nil), free[:n]...)
+	return cpus, nil
+}
+
+// readCPUNodes 通过 /sys/devices/system/cpu/cpuN/nodeM 确定每个 CPU 的 NUMA 节点。
+// 没有 NUMA 信息时全部视为节点 0。
+func readCPUNodes(cpus []int) map[int]int {
+	nodes := make(map[int]int, len(cpus))
+	for _, c := range cpus {
+		matches, _ := filepath.Glob(fmt.Sprintf("/sys/devices/system/cpu/cpu%d/node*", c))
+		for _, m := range matches {
+			if n, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(m), "node")); err == nil {
+				nodes[c] = n
+				break
+			}
+		}
+	}
+	return nodes
+}
+
+// maxCPUNumber 是 parseCPUList 接受的最大 CPU/节点编号（内核 NR_CPUS 上限为 8192）。
+// 在展开区间之前检查，"0-4000000000" 这样的输入不会分配巨大的列表。
+const maxCPUNumber = 8191
+
+// parseCPUList 解析 cpuset 列表格式（"0-3,6,8-9"），返回升序去重的编号。
+func parseCPUList(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	var list []int
+	for _, part := range strings.Split(strings.TrimSpace(s), ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid cpu list %q", s)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpu list %q", s)
+			}
+		}
+		if end > maxCPUNumber {
+			return nil, fmt.Errorf("invalid cpu list %q: cpu %d exceeds %d", s, end, maxCPUNumber)
+		}
+		for i := start; i <= end; i++ {
+			if !seen[i] {
+				seen[i] = true
+				list = append(list, i)
+			}
+		}
+	}
+	sort.Ints(list)
+	return list, nil
+}
+
+// formatCPUList 将升序编号格式化为 cpuset 列表格式，连续编号合并为区间。
+func formatCPUList(list []int) string {
+	var parts []string
+	for i := 0; i < len(list); {
+		j := i
+		for j+1 < len(list) && list[j+1] == list[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(list[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", list[i], list[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,262 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================================================================
+// CPU 分配单元测试（不需要 root）
+// ===================================================================
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{"0", []int{0}},
+		{"0-3", []int{0, 1, 2, 3}},
+		{"6,0-2,2", []int{0, 1, 2, 6}},
+		{" 4-5 ,8\n", []int{4, 5, 8}},
+		{"8190-8191", []int{8190, 8191}},
+	}
+	for _, tt := range tests {
+		got, err := parseCPUList(tt.in)
+		if err != nil {
+			t.Errorf("parseCPUList(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCPUList(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "a", "3-1", "-1", "1-", "1,,2", "8192", "0-4000000000", "0-99999999999999999999"} {
+		if _, err := parseCPUList(bad); err == nil {
+			t.Errorf("parseCPUList(%q): expected error", bad)
+		}
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	tests := []struct {
+		in   []int
+		want string
+	}{
+		{nil, ""},
+		{[]int{0}, "0"},
+		{[]int{0, 1, 2, 3}, "0-3"},
+		{[]int{0, 2, 3, 4, 7}, "0,2-4,7"},
+	}
+	for _, tt := range tests {
+		if got := formatCPUList(tt.in); got != tt.want {
+			t.Errorf("formatCPUList(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCgroupsConfigCPUSetValidation(t *testing.T) {
+	if err := (&CgroupsConfig{CPUSetCPUs: "0-3", CPUSetMems: "0"}).validate(); err != nil {
+		t.Errorf("valid cpuset rejected: %v", err)
+	}
+	if err := (&CgroupsConfig{CPUSetCPUs: "3-0"}).validate(); err == nil {
+		t.Error("expected error for an invalid cpuset.cpus")
+	}
+	c := CgroupsConfig{CPUSetMems: "0"}
+	if got := c.controllers(); !reflect.DeepEqual(got, []string{"cpuset"}) {
+		t.Errorf("controllers() = %v, want [cpuset]", got)
+	}
+}
+
+// newTestAllocator 创建使用临时目录的分配器，nodes 为 CPU → NUMA 节点。
+func newTestAllocator(t *testing.T, pool string, nodes map[int]int) *CPUSetAllocator {
+	t.Helper()
+	a, err := NewCPUSetAllocator(t.TempDir(), pool)
+	if err != nil {
+		t.Fatalf("NewCPUSetAllocator failed: %v", err)
+	}
+	a.nodes = nodes
+	return a
+}
+
+func TestCPUSetAllocatorExclusive(t *testing.T) {
+	a := newTestAllocator(t, "0-3", nil)
+
+	first, release1, err := a.Allocate(2, true)
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	second, release2, err := a.Allocate(2, true)
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	if first.CPUs != "0-1" || second.CPUs != "2-3" || first.Mems != "0" {
+		t.Errorf("unexpected allocations %+v, %+v", first, second)
+	}
+
+	// 池已全部独占：独占和共享分配都失败
+	if _, _, err := a.Allocate(1, true); err == nil {
+		t.Error("expected exclusive allocation to fail when the pool is exhausted")
+	}
+	if _, _, err := a.Allocate(1, false); err == nil {
+		t.Error("expected shared allocation to fail when all cpus are exclusive")
+	}
+
+	// 释放后可以重新分配
+	if err := release1(); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	again, _, err := a.Allocate(2, true)
+	if err != nil {
+		t.Fatalf("Allocate after release failed: %v", err)
+	}
+	if again.CPUs != "0-1" {
+		t.Errorf("expected released cpus 0-1, got %s", again.CPUs)
+	}
+	release2()
+}
+
+func TestCPUSetAllocatorShared(t *testing.T) {
+	a := newTestAllocator(t, "0-3", nil)
+
+	excl, _, err := a.Allocate(1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 共享分配避开独占 CPU，并在共享者最少的 CPU 之间分摊
+	s1, _, err := a.Allocate(2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, _, err := a.Allocate(2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if excl.CPUs != "0" || s1.CPUs != "1-2" || s2.CPUs != "1,3" {
+		t.Errorf("unexpected allocations: exclusive %s, shared %s, %s", excl.CPUs, s1.CPUs, s2.CPUs)
+	}
+
+	// 已被共享的 CPU 不能再独占
+	if _, _, err := a.Allocate(1, true); err == nil {
+		t.Error("expected exclusive allocation to fail on shared cpus")
+	}
+}
+
+func TestCPUSetAllocatorNUMA(t *testing.T) {
+	// 节点 0：CPU 0-3，节点 1：CPU 4-5
+	a := newTestAllocator(t, "0-5", map[int]int{0: 0, 1: 0, 2: 0, 3: 0, 4: 1, 5: 1})
+
+	// 选择空闲 CPU 最少但足够的节点，保留大块连续空间
+	small, _, err := a.Allocate(2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small.CPUs != "4-5" || small.Mems != "1" {
+		t.Errorf("expected 4-5 on node 1, got %+v", small)
+	}
+	big, _, err := a.Allocate(4, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if big.CPUs != "0-3" || big.Mems != "0" {
+		t.Errorf("expected 0-3 on node 0, got %+v", big)
+	}
+}
+
+func TestCPUSetAllocatorSpansNodes(t *testing.T) {
+	a := newTestAllocator(t, "0-3", map[int]int{0: 0, 1: 0, 2: 1, 3: 1})
+	alloc, _, err := a.Allocate(3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alloc.CPUs != "0-2" || alloc.Mems != "0-1" {
+		t.Errorf("unexpected cross-node allocation %+v", alloc)
+	}
+}
+
+func TestCPUSetAllocatorStaleLease(t *testing.T) {
+	a := newTestAllocator(t, "0-1", nil)
+
+	// 持有者已退出的分配记录被回收
+	stale := filepath.Join(a.dir, "leases", "stale.json")
+	if err := os.WriteFile(stale, []byte(`{"pid":999999999,"cpus":[0,1],"exclusive":true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	alloc, _, err := a.Allocate(2, true)
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	if alloc.CPUs != "0-1" {
+		t.Errorf("expected stale lease to be reclaimed, got %s", alloc.CPUs)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale lease file should be removed")
+	}
+}
+
+func TestCPUSetAllocatorInvalid(t *testing.T) {
+	if _, err := NewCPUSetAllocator(t.TempDir(), "x"); err == nil {
+		t.Error("expected error for an invalid pool")
+	}
+	a := newTestAllocator(t, "0", nil)
+	if _, _, err := a.Allocate(0, false); err == nil {
+		t.Error("expected error for a zero cpu count")
+	}
+	if _, _, err := a.Allocate(2, false); err == nil || !strings.Contains(err.Error(), "only 1 available") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewCPUSetAllocatorDefaultPool(t *testing.T) {
+	a, err := NewCPUSetAllocator(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("NewCPUSetAllocator failed: %v", err)
+	}
+	if len(a.pool) == 0 {
+		t.Error("default pool should contain the cpus this process may run on")
+	}
+}
+
+// ===================================================================
+// CPU 绑定集成测试（需要 root）
+// ===================================================================
+
+func TestCgroupsCPUSet(t *testing.T) {
+	skipIfNoCgroupsV2(t)
+
+	a, err := NewCPUSetAllocator(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alloc, release, err := a.Allocate(1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	cfg := DefaultCgroupsConfig()
+	cfg.CPUSetCPUs, cfg.CPUSetMems = alloc.CPUs, alloc.Mems
+	cg := NewCgroupsV2(cfg)
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer cg.Cleanup()
+
+	for name, want := range map[string]string{
+		"cpuset.cpus.effective": alloc.CPUs,
+		"cpuset.mems.effective": alloc.Mems,
+	} {
+		data, err := os.ReadFile(filepath.Join(cg.CgroupDir(), name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if got := strings.TrimSpace(string(data)); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
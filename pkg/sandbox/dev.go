@@ -0,0 +1,229 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// DevConfig 定义沙箱内 /dev 的构建配置（父进程侧）。
+//
+// 启用后子进程在 pivot_root 前于新 root 的 /dev 挂载一个全新的 tmpfs，
+// 只创建 Devices 白名单中的设备节点，并按配置挂载：
+//   - /dev/pts：私有的 devpts 实例（newinstance），/dev/ptmx 指向 pts/ptmx
+//   - /dev/shm：限制大小的 tmpfs（POSIX 共享内存，Python multiprocessing 依赖）
+//   - /dev/mqueue：POSIX 消息队列（仅在启用 IPC Namespace 时挂载，否则会暴露宿主机的队列）
+//
+// 未启用时沙箱直接使用根目录中原有的 /dev。
+type DevConfig struct {
+	Enabled bool     // 是否构建全新的 /dev
+	Size    string   // /dev 本身的 tmpfs 大小限制，例如 "64m"（空则使用 64m）
+	Devices []string // 设备节点白名单（空则使用 defaultDevices），可选值见 knownDevices
+	Devpts  bool     // 挂载私有 devpts
+	ShmSize string   // /dev/shm 的 tmpfs 大小限制，例如 "64m"（空则不挂载）
+	Mqueue  bool     // 挂载 /dev/mqueue（需要 IPC Namespace）
+}
+
+// DefaultDevConfig 返回默认配置：启用、64m 的 /dev、默认设备白名单、devpts、64m 的 /dev/shm、mqueue。
+func DefaultDevConfig() DevConfig {
+	return DevConfig{
+		Enabled: true,
+		Size:    defaultDevSize,
+		Devices: nil, // nil 表示使用 defaultDevices
+		Devpts:  true,
+		ShmSize: "64m",
+		Mqueue:  true,
+	}
+}
+
+// devInitConfig 通过管道传递给子进程的 /dev 配置。
+type devInitConfig struct {
+	Size    string   `json:"size"`
+	Devices []string `json:"devices"`
+	Devpts  bool     `json:"devpts,omitempty"`
+	ShmSize string   `json:"shm_size,omitempty"`
+	Mqueue  bool     `json:"mqueue,omitempty"`
+}
+
+// deviceNode 描述一个字符设备节点。
+type deviceNode struct {
+	major, minor uint32
+}
+
+// knownDevices 是允许在沙箱内创建的设备节点。
+// 仅包含不访问宿主机硬件、也不能用于提权的伪设备。
+var knownDevices = map[string]deviceNode{
+	"null":    {1, 3},
+	"zero":    {1, 5},
+	"full":    {1, 7},
+	"random":  {1, 8},
+	"urandom": {1, 9},
+	"tty":     {5, 0},
+}
+
+// defaultDevices 是默认创建的设备节点（knownDevices 的全部）。
+var defaultDevices = []string{"null", "zero", "full", "random", "urandom", "tty"}
+
+// devSymlinks 是 /dev 中的标准符号链接（在 /proc 挂载后才能正常工作）。
+var devSymlinks = []struct {
+	target string
+	link   string
+}{
+	{"/proc/self/fd", "fd"},
+	{"/proc/self/fd/0", "stdin"},
+	{"/proc/self/fd/1", "stdout"},
+	{"/proc/self/fd/2", "stderr"},
+}
+
+// defaultDevSize 是 /dev tmpfs 的默认大小。/dev 中只有设备节点和少量符号链接，
+// 限制大小防止沙箱往 /dev 写入大文件耗尽内存。
+const defaultDevSize = "64m"
+
+// tmpfsSizePattern 匹配 tmpfs 的 size= 选项：字节数，可带 k/m/g 后缀或百分比。
+var tmpfsSizePattern = regexp.MustCompile(`^[0-9]+[kKmMgG%]?$`)
+
+// initConfig 校验配置并转换为传递给子进程的配置。
+// ipcNamespace 为 false 时不挂载 /dev/mqueue。
+func (c *DevConfig) initConfig(ipcNamespace bool) (*devInitConfig, error) {
+	devices := c.Devices
+	if devices == nil {
+		devices = defaultDevices
+	}
+	for _, name := range devices {
+		if _, ok := knownDevices[name]; !ok {
+			known := make([]string, 0, len(knownDevices))
+			for k := range knownDevices {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("dev: device %q not allowed (allowed: %v)", name, known)
+		}
+	}
+	size := c.Size
+	if size == "" {
+		size = defaultDevSize
+	}
+	if !tmpfsSizePattern.MatchString(size) {
+		return nil, fmt.Errorf("dev: invalid size %q", c.Size)
+	}
+	if c.ShmSize != "" {
+		if !tmpfsSizePattern.MatchString(c.ShmSize) {
+			return nil, fmt.Errorf("dev: invalid shm size %q", c.ShmSize)
+		}
+	}
+	return &devInitConfig{
+		Size:    size,
+		Devices: devices,
+		Devpts:  c.Devpts,
+		ShmSize: c.ShmSize,
+		Mqueue:  c.Mqueue && ipcNamespace,
+	}, nil
+}
+
+// setupDev 在子进程中构建 rootDir/dev。
+// 此函数在新 Mount Namespace 内运行，由 nsInit() 在用户 bind mount 和 pivot_root 之前调用，
+// 目标位于 /dev 下的 bind mount 挂载在新的 tmpfs 之上而不是被它遮住，
+// 挂载随 pivot_root 的递归 bind 一起进入新 root。
+//
+// 步骤：
+//  1. 在 rootDir/dev 挂载全新的 tmpfs（nosuid，不能 nodev）
+//  2. 按白名单 mknod 设备节点，mknod 不被允许时 bind mount 宿主机的同名设备
+//  3. 创建 fd/stdin/stdout/stderr 等符号链接
+//  4. 挂载 devpts、/dev/shm、/dev/mqueue
+func setupDev(rootDir string, cfg *devInitConfig) error {
+	devDir, err := securejoin(rootDir, "/dev")
+	if err != nil {
+		return fmt.Errorf("resolve /dev: %w", err)
+	}
+	if err := os.MkdirAll(devDir, 0755); err != nil {
+		return fmt.Errorf("mkdir %s: %w", devDir, err)
+	}
+	if err := syscall.Mount("tmpfs", devDir, "tmpfs", syscall.MS_NOSUID|syscall.MS_STRICTATIME, "mode=755,size="+cfg.Size); err != nil {
+		return fmt.Errorf("mount tmpfs on %s: %w", devDir, err)
+	}
+
+	for _, name := range cfg.Devices {
+		if err := createDevice(devDir, name, knownDevices[name]); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range devSymlinks {
+		if err := os.Symlink(s.target, filepath.Join(devDir, s.link)); err != nil {
+			return fmt.Errorf("symlink %s -> %s: %w", s.link, s.target, err)
+		}
+	}
+
+	if cfg.Devpts {
+		pts := filepath.Join(devDir, "pts")
+		if err := os.Mkdir(pts, 0755); err != nil {
+			return fmt.Errorf("mkdir %s: %w", pts, err)
+		}
+		// newinstance：与宿主机及其他沙箱的伪终端完全隔离；gid=5 为惯例的 tty 组
+		if err := syscall.Mount("devpts", pts, "devpts", syscall.MS_NOSUID|syscall.MS_NOEXEC,
+			"newinstance,ptmxmode=0666,mode=0620,gid=5"); err != nil {
+			return fmt.Errorf("mount devpts: %w", err)
+		}
+		if err := os.Symlink("pts/ptmx", filepath.Join(devDir, "ptmx")); err != nil {
+			return fmt.Errorf("symlink ptmx: %w", err)
+		}
+	}
+
+	if cfg.ShmSize != "" {
+		shm := filepath.Join(devDir, "shm")
+		if err := os.Mkdir(shm, 0755); err != nil {
+			return fmt.Errorf("mkdir %s: %w", shm, err)
+		}
+		if err := syscall.Mount("shm", shm, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC,
+			"mode=1777,size="+cfg.ShmSize); err != nil {
+			return fmt.Errorf("mount /dev/shm: %w", err)
+		}
+	}
+
+	if cfg.Mqueue {
+		mq := filepath.Join(devDir, "mqueue")
+		if err := os.Mkdir(mq, 0755); err != nil {
+			return fmt.Errorf("mkdir %s: %w", mq, err)
+		}
+		if err := syscall.Mount("mqueue", mq, "mqueue", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+			return fmt.Errorf("mount /dev/mqueue: %w", err)
+		}
+	}
+	return nil
+}
+
+// createDevice 在 devDir 下创建名为 name 的字符设备节点（权限 0666）。
+// 在不允许 mknod 的环境中（例如容器内缺少 CAP_MKNOD）退回到 bind mount 宿主机设备。
+func createDevice(devDir, name string, dev deviceNode) error {
+	path := filepath.Join(devDir, name)
+	err := unix.Mknod(path, unix.S_IFCHR|0666, int(unix.Mkdev(dev.major, dev.minor)))
+	if err == nil {
+		// mknod 受 umask 影响
+		if err := os.Chmod(path, 0666); err != nil {
+			return fmt.Errorf("chmod %s: %w", path, err)
+		}
+		return nil
+	}
+	if !errors.Is(err, unix.EPERM) {
+		return fmt.Errorf("mknod %s: %w", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	f.Close()
+	src := filepath.Join("/dev", name)
+	if err := syscall.Mount(src, path, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("bind mount %s -> %s: %w", src, path, err)
+	}
+	return nil
+}
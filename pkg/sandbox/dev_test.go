@@ -0,0 +1,179 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================================================================
+// /dev 配置单元测试（不需要 root）
+// ===================================================================
+
+func TestDefaultDevConfig(t *testing.T) {
+	cfg := DefaultDevConfig()
+	if !cfg.Enabled || cfg.Size != "64m" || !cfg.Devpts || !cfg.Mqueue || cfg.ShmSize != "64m" {
+		t.Errorf("unexpected default config: %+v", cfg)
+	}
+	icfg, err := cfg.initConfig(true)
+	if err != nil {
+		t.Fatalf("initConfig failed: %v", err)
+	}
+	if !reflect.DeepEqual(icfg.Devices, defaultDevices) {
+		t.Errorf("expected default devices, got %v", icfg.Devices)
+	}
+	for _, name := range defaultDevices {
+		if _, ok := knownDevices[name]; !ok {
+			t.Errorf("default device %q is not in knownDevices", name)
+		}
+	}
+}
+
+func TestDevInitConfig(t *testing.T) {
+	cfg := DefaultDevConfig()
+	cfg.Devices = []string{"null", "tty"}
+	cfg.ShmSize = "50%"
+	icfg, err := cfg.initConfig(false)
+	if err != nil {
+		t.Fatalf("initConfig failed: %v", err)
+	}
+	if !reflect.DeepEqual(icfg.Devices, []string{"null", "tty"}) || icfg.ShmSize != "50%" {
+		t.Errorf("unexpected init config: %+v", icfg)
+	}
+	if icfg.Mqueue {
+		t.Error("mqueue should be disabled without an IPC namespace")
+	}
+	if icfg, _ := (&DevConfig{Enabled: true}).initConfig(true); icfg == nil || icfg.Size != defaultDevSize {
+		t.Errorf("expected /dev size to default to %s, got %+v", defaultDevSize, icfg)
+	}
+
+	invalid := []DevConfig{
+		{Enabled: true, Devices: []string{"sda"}},
+		{Enabled: true, Devices: []string{"../null"}},
+		{Enabled: true, Size: "1m,mode=777"},
+		{Enabled: true, ShmSize: "64 m"},
+		{Enabled: true, ShmSize: "-1"},
+	}
+	for _, c := range invalid {
+		if _, err := c.initConfig(true); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
+
+// ===================================================================
+// /dev 集成测试（需要 root）
+// ===================================================================
+
+// runDevSandbox 在 overlay + pivot_root 沙箱中执行脚本并返回输出。
+func runDevSandbox(t *testing.T, cfg *DevConfig, script string, mounts ...BindMount) string {
+	t.Helper()
+
+	ov := NewOverlayFS(DefaultOverlayConfig("/"))
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("overlay setup: %v", err)
+	}
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetOverlayFS(ov)
+	pcfg := DefaultPivotRootConfig()
+	ns.SetPivotRoot(&pcfg)
+	if cfg != nil {
+		ns.SetDev(cfg)
+	}
+	if len(mounts) > 0 {
+		ns.SetBindMounts(mounts)
+	}
+
+	var buf bytes.Buffer
+	r, w, _ := os.Pipe()
+	ns.Stdout = w
+
+	if err := ns.Start("sh", "-c", script); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	result, err := ns.Wait()
+	w.Close()
+	buf.ReadFrom(r)
+	r.Close()
+
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code %d, output: %s", result.ExitCode, buf.String())
+	}
+	return buf.String()
+}
+
+func TestDevDefault(t *testing.T) {
+	skipIfNotRoot(t)
+
+	out := runDevSandbox(t, nil, `
+		ls /dev | tr '\n' ' '; echo
+		head -c 4 /dev/random | wc -c
+		echo x > /dev/full 2>/dev/null || echo full-ok
+		stat -c '%a' /dev/shm
+		grep -E ' /dev/(pts|shm|mqueue) ' /proc/self/mounts | cut -d' ' -f3 | tr '\n' ' '
+	`)
+	lines := strings.Split(out, "\n")
+	for _, name := range append(defaultDevices, "pts", "shm", "mqueue", "ptmx", "fd") {
+		if !strings.Contains(" "+lines[0], " "+name+" ") {
+			t.Errorf("expected /dev/%s, got: %s", name, lines[0])
+		}
+	}
+	// 宿主机的其他设备不可见
+	if strings.Contains(lines[0], "sda") || strings.Contains(lines[0], "kmsg") || strings.Contains(lines[0], "mem ") {
+		t.Errorf("unexpected host devices in /dev: %s", lines[0])
+	}
+	for _, want := range []string{"4", "full-ok", "1777", "devpts tmpfs mqueue"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output: %s", want, out)
+		}
+	}
+}
+
+func TestDevShmSizeLimit(t *testing.T) {
+	skipIfNotRoot(t)
+
+	cfg := DefaultDevConfig()
+	cfg.Devices = []string{"null", "zero"}
+	cfg.ShmSize = "1m"
+	cfg.Devpts = false
+	out := runDevSandbox(t, &cfg, `
+		ls /dev | tr '\n' ' '; echo
+		head -c 2097152 /dev/zero > /dev/shm/big 2>/dev/null || echo shm-full
+	`)
+	if strings.Contains(out, "urandom") || strings.Contains(out, "pts") {
+		t.Errorf("devices outside the allowlist should not exist: %s", out)
+	}
+	if !strings.Contains(out, "shm-full") {
+		t.Errorf("expected /dev/shm to be limited to 1m: %s", out)
+	}
+}
+
+func TestDevSizeAndBindMount(t *testing.T) {
+	skipIfNotRoot(t)
+
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "model.bin"), []byte("weights"), 0644)
+
+	cfg := DefaultDevConfig()
+	cfg.Size = "1m"
+	// 目标位于 /dev 下的 bind mount 不会被 /dev 的 tmpfs 遮住
+	out := runDevSandbox(t, &cfg, `
+		cat /dev/models/model.bin; echo
+		head -c 2097152 /dev/zero > /dev/big 2>/dev/null || echo dev-full
+	`, BindMount{Source: src, Destination: "/dev/models", ReadOnly: true, Recursive: true})
+	if !strings.Contains(out, "weights") {
+		t.Errorf("bind mount under /dev hidden by the /dev tmpfs: %s", out)
+	}
+	if !strings.Contains(out, "dev-full") {
+		t.Errorf("expected /dev to be limited to 1m: %s", out)
+	}
+}
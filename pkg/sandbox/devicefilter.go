@@ -0,0 +1,360 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// DeviceRule 描述一条设备访问白名单规则，语义与 cgroup v1 的 devices.allow 相同。
+//
+// 例如 "c 1:3 rwm" 允许对 /dev/null 读、写和 mknod；"c 136:* rw" 允许读写所有伪终端从设备；
+// "a" 允许所有设备（相当于关闭过滤）。
+type DeviceRule struct {
+	Type   byte   // 'c' 字符设备、'b' 块设备、'a' 所有类型
+	Major  int64  // 主设备号，-1 表示任意
+	Minor  int64  // 次设备号，-1 表示任意
+	Access string // "r"、"w"、"m" 的组合
+}
+
+// 设备号通配符。
+const deviceWildcard = -1
+
+// String 返回规则的 devices.allow 格式表示。
+func (r DeviceRule) String() string {
+	if r.Type == 'a' {
+		return "a"
+	}
+	num := func(n int64) string {
+		if n == deviceWildcard {
+			return "*"
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	return fmt.Sprintf("%c %s:%s %s", r.Type, num(r.Major), num(r.Minor), r.Access)
+}
+
+// ParseDeviceRule 解析 devices.allow 格式的规则："type major:minor access"。
+//
+// type 为 c/b/a，major、minor 为数字或 "*"，access 为 r/w/m 的组合（省略时为 rwm）。
+// 单独的 "a" 表示允许所有设备。
+func ParseDeviceRule(spec string) (DeviceRule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 3 || len(fields[0]) != 1 {
+		return DeviceRule{}, fmt.Errorf("invalid device rule %q: expected \"type major:minor access\"", spec)
+	}
+
+	r := DeviceRule{Type: fields[0][0], Major: deviceWildcard, Minor: deviceWildcard, Access: "rwm"}
+	switch r.Type {
+	case 'a':
+		if len(fields) != 1 {
+			return DeviceRule{}, fmt.Errorf("invalid device rule %q: \"a\" takes no arguments", spec)
+		}
+		return r, nil
+	case 'c', 'b':
+	default:
+		return DeviceRule{}, fmt.Errorf("invalid device rule %q: unknown type %q", spec, fields[0])
+	}
+	if len(fields) < 2 {
+		return DeviceRule{}, fmt.Errorf("invalid device rule %q: missing major:minor", spec)
+	}
+
+	majorStr, minorStr, ok := strings.Cut(fields[1], ":")
+	if !ok {
+		return DeviceRule{}, fmt.Errorf("invalid device rule %q: expected major:minor", spec)
+	}
+	for _, p := range []struct {
+		s   string
+		dst *int64
+	}{{majorStr, &r.Major}, {minorStr, &r.Minor}} {
+		if p.s == "*" {
+			continue
+		}
+		n, err := strconv.ParseUint(p.s, 10, 32)
+		if err != nil {
+			return DeviceRule{}, fmt.Errorf("invalid device rule %q: bad device number %q", spec, p.s)
+		}
+		*p.dst = int64(n)
+	}
+
+	if len(fields) == 3 {
+		r.Access = fields[2]
+		if r.Access == "" || strings.Trim(r.Access, "rwm") != "" {
+			return DeviceRule{}, fmt.Errorf("invalid device rule %q: access must be a combination of r, w and m", spec)
+		}
+	}
+	return r, nil
+}
+
+// DefaultDeviceRules 返回默认的设备白名单，与 DefaultDevConfig() 在 /dev 中创建的设备一致：
+// defaultDevices 中的伪设备，以及私有 devpts 的 /dev/pts/ptmx（5:2）和伪终端从设备（136:*）。
+func DefaultDeviceRules() []DeviceRule {
+	rules := make([]DeviceRule, 0, len(defaultDevices)+2)
+	for _, name := range defaultDevices {
+		d := knownDevices[name]
+		rules = append(rules, DeviceRule{Type: 'c', Major: int64(d.major), Minor: int64(d.minor), Access: "rwm"})
+	}
+	rules = append(rules,
+		DeviceRule{Type: 'c', Major: 5, Minor: 2, Access: "rwm"},
+		DeviceRule{Type: 'c', Major: 136, Minor: deviceWildcard, Access: "rwm"},
+	)
+	return rules
+}
+
+// bpfInsn 是一条 eBPF 指令（struct bpf_insn）。
+type bpfInsn struct {
+	code uint8
+	regs uint8 // 低 4 位 dst，高 4 位 src
+	off  int16
+	imm  int32
+}
+
+// eBPF 寄存器。
+const (
+	bpfR0 = iota
+	bpfR1
+	bpfR2
+	bpfR3
+	bpfR4
+	bpfR5
+)
+
+func bpfLdxW(dst, src uint8, off int16) bpfInsn {
+	return bpfInsn{code: unix.BPF_LDX | unix.BPF_MEM | unix.BPF_W, regs: src<<4 | dst, off: off}
+}
+
+func bpfALU32Imm(op uint8, dst uint8, imm int32) bpfInsn {
+	return bpfInsn{code: unix.BPF_ALU | op | unix.BPF_K, regs: dst, imm: imm}
+}
+
+func bpfMov32Reg(dst, src uint8) bpfInsn {
+	return bpfInsn{code: unix.BPF_ALU | unix.BPF_MOV | unix.BPF_X, regs: src<<4 | dst}
+}
+
+func bpfJmp32Imm(op uint8, dst uint8, imm int32, off int16) bpfInsn {
+	return bpfInsn{code: unix.BPF_JMP32 | op | unix.BPF_K, regs: dst, off: off, imm: imm}
+}
+
+func bpfJmp32Reg(op uint8, dst, src uint8, off int16) bpfInsn {
+	return bpfInsn{code: unix.BPF_JMP32 | op | unix.BPF_X, regs: src<<4 | dst, off: off}
+}
+
+func bpfExit() bpfInsn {
+	return bpfInsn{code: unix.BPF_JMP | unix.BPF_EXIT}
+}
+
+// buildDeviceFilter 将白名单规则编译为 BPF_PROG_TYPE_CGROUP_DEVICE 程序。
+//
+// 程序的输入是 struct bpf_cgroup_dev_ctx { u32 access_type; u32 major; u32 minor; }，
+// 其中 access_type 的低 16 位为设备类型、高 16 位为访问类型。
+// 依次匹配每条规则，命中则返回 1（允许），全部不命中返回 0（内核返回 EPERM）。
+// 遇到无条件规则时在其后结束程序。
+func buildDeviceFilter(rules []DeviceRule) []bpfInsn {
+	prog := []bpfInsn{
+		bpfLdxW(bpfR2, bpfR1, 0), // r2 = type
+		bpfALU32Imm(unix.BPF_AND, bpfR2, 0xffff),
+		bpfLdxW(bpfR3, bpfR1, 0), // r3 = access
+		bpfALU32Imm(unix.BPF_RSH, bpfR3, 16),
+		bpfLdxW(bpfR4, bpfR1, 4), // r4 = major
+		bpfLdxW(bpfR5, bpfR1, 8), // r5 = minor
+	}
+
+	for _, r := range rules {
+		// 每条规则是一个块：任一条件不满足即跳到块末尾（下一条规则）
+		var block []bpfInsn
+		var jumps []int
+		cond := func(insns ...bpfInsn) {
+			block = append(block, insns[:len(insns)-1]...)
+			jumps = append(jumps, len(block))
+			block = append(block, insns[len(insns)-1])
+		}
+
+		switch r.Type {
+		case 'c':
+			cond(bpfJmp32Imm(unix.BPF_JNE, bpfR2, unix.BPF_DEVCG_DEV_CHAR, 0))
+		case 'b':
+			cond(bpfJmp32Imm(unix.BPF_JNE, bpfR2, unix.BPF_DEVCG_DEV_BLOCK, 0))
+		}
+		if access := deviceAccessMask(r.Access); r.Type != 'a' && access != deviceAccessAll {
+			// 请求的访问类型必须是规则允许的子集：(access & allowed) == access
+			cond(
+				bpfMov32Reg(bpfR1, bpfR3),
+				bpfALU32Imm(unix.BPF_AND, bpfR1, access),
+				bpfJmp32Reg(unix.BPF_JNE, bpfR1, bpfR3, 0),
+			)
+		}
+		if r.Type != 'a' && r.Major != deviceWildcard {
+			cond(bpfJmp32Imm(unix.BPF_JNE, bpfR4, int32(r.Major), 0))
+		}
+		if r.Type != 'a' && r.Minor != deviceWildcard {
+			cond(bpfJmp32Imm(unix.BPF_JNE, bpfR5, int32(r.Minor), 0))
+		}
+		block = append(block, bpfALU32Imm(unix.BPF_MOV, bpfR0, 1), bpfExit())
+
+		for _, i := range jumps {
+			block[i].off = int16(len(block) - i - 1)
+		}
+		prog = append(prog, block...)
+
+		// 无条件规则（例如 "a"）之后的指令不可达，verifier 会拒绝含不可达指令的程序
+		if len(jumps) == 0 {
+			return prog
+		}
+	}
+
+	return append(prog, bpfALU32Imm(unix.BPF_MOV, bpfR0, 0), bpfExit())
+}
+
+// deviceAccessAll 是 r、w、m 全部允许时的访问掩码。
+const deviceAccessAll = unix.BPF_DEVCG_ACC_MKNOD | unix.BPF_DEVCG_ACC_READ | unix.BPF_DEVCG_ACC_WRITE
+
+// deviceAccessMask 将 "rwm" 形式的访问类型转换为 BPF_DEVCG_ACC_* 掩码。
+func deviceAccessMask(access string) int32 {
+	var mask int32
+	for _, c := range access {
+		switch c {
+		case 'r':
+			mask |= unix.BPF_DEVCG_ACC_READ
+		case 'w':
+			mask |= unix.BPF_DEVCG_ACC_WRITE
+		case 'm':
+			mask |= unix.BPF_DEVCG_ACC_MKNOD
+		}
+	}
+	return mask
+}
+
+// bpfProgLoadAttr 是 bpf(BPF_PROG_LOAD) 使用的 union bpf_attr 前缀。
+type bpfProgLoadAttr struct {
+	progType    uint32
+	insnCnt     uint32
+	insns       uint64
+	license     uint64
+	logLevel    uint32
+	logSize     uint32
+	logBuf      uint64
+	kernVersion uint32
+	progFlags   uint32
+}
+
+// bpfProgAttachAttr 是 bpf(BPF_PROG_ATTACH) 使用的 union bpf_attr 前缀。
+type bpfProgAttachAttr struct {
+	targetFd    uint32
+	attachBpfFd uint32
+	attachType  uint32
+	attachFlags uint32
+}
+
+// attachDeviceFilter 编译规则、加载 BPF 程序并挂载到 cgroupDir。
+//
+// 使用 BPF_F_ALLOW_MULTI 挂载：祖先 cgroup 上已有的设备程序（例如 systemd 设置的）
+// 仍然生效，访问需要同时被所有程序允许。cgroup 目录删除时程序自动卸载。
+func attachDeviceFilter(cgroupDir string, rules []DeviceRule) error {
+	progFd, err := loadDeviceFilter(buildDeviceFilter(rules))
+	if err != nil {
+		return err
+	}
+	defer unix.Close(progFd)
+
+	dir, err := os.Open(cgroupDir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	attr := bpfProgAttachAttr{
+		targetFd:    uint32(dir.Fd()),
+		attachBpfFd: uint32(progFd),
+		attachType:  unix.BPF_CGROUP_DEVICE,
+		attachFlags: unix.BPF_F_ALLOW_MULTI,
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_BPF, unix.BPF_PROG_ATTACH,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr)); errno != 0 {
+		return fmt.Errorf("attach to %s: %w", cgroupDir, errno)
+	}
+	return nil
+}
+
+var (
+	deviceFilterProbe sync.Once
+	deviceFilterErr   error
+)
+
+// deviceFilterSupport 探测内核能否加载默认白名单编译出的设备过滤程序，结果在进程内缓存。
+// 旧内核缺少 JMP32 指令，或 lockdown/LSM 策略限制 bpf() 时返回加载错误。
+func deviceFilterSupport() error {
+	deviceFilterProbe.Do(func() {
+		fd, err := loadDeviceFilter(buildDeviceFilter(DefaultDeviceRules()))
+		if err != nil {
+			deviceFilterErr = err
+			return
+		}
+		unix.Close(fd)
+	})
+	return deviceFilterErr
+}
+
+// DeviceFilterSupported 报告内核是否支持 cgroup v2 设备过滤程序（BPF_PROG_TYPE_CGROUP_DEVICE）。
+// 不支持时 CgroupsV2.Setup 跳过 DeviceFilter 并记录警告。
+func DeviceFilterSupported() bool {
+	return deviceFilterSupport() == nil
+}
+
+// loadDeviceFilter 将程序加载到内核并返回程序 fd。
+// 校验失败时带上 verifier 日志重试一次，便于定位问题。
+func loadDeviceFilter(prog []bpfInsn) (int, error) {
+	code := make([]byte, 0, len(prog)*8)
+	for _, insn := range prog {
+		code = append(code, insn.code, insn.regs)
+		code = binary.NativeEndian.AppendUint16(code, uint16(insn.off))
+		code = binary.NativeEndian.AppendUint32(code, uint32(insn.imm))
+	}
+	license := []byte("GPL\x00")
+
+	load := func(logBuf []byte) (int, error) {
+		attr := bpfProgLoadAttr{
+			progType: unix.BPF_PROG_TYPE_CGROUP_DEVICE,
+			insnCnt:  uint32(len(prog)),
+			insns:    uint64(uintptr(unsafe.Pointer(&code[0]))),
+			license:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+		}
+		if logBuf != nil {
+			attr.logLevel = 1
+			attr.logSize = uint32(len(logBuf))
+			attr.logBuf = uint64(uintptr(unsafe.Pointer(&logBuf[0])))
+		}
+		fd, _, errno := unix.Syscall(unix.SYS_BPF, unix.BPF_PROG_LOAD,
+			uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr))
+		runtime.KeepAlive(code)
+		runtime.KeepAlive(license)
+		runtime.KeepAlive(logBuf)
+		if errno != 0 {
+			return -1, errno
+		}
+		return int(fd), nil
+	}
+
+	fd, err := load(nil)
+	if err == nil {
+		return fd, nil
+	}
+	// 带日志缓冲区重试以获取校验器输出；重试成功（如首次遇到瞬时错误）则直接使用
+	logBuf := make([]byte, 64*1024)
+	fd, err2 := load(logBuf)
+	if err2 == nil {
+		return fd, nil
+	}
+	if log := strings.TrimRight(string(logBuf), "\x00\n"); log != "" {
+		return -1, fmt.Errorf("load program: %w: %s", err, log)
+	}
+	return -1, fmt.Errorf("load program: %w", err)
+}
@@ -0,0 +1,333 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// EtcFileInherit 作为 EtcFilesConfig 中某个文件的覆盖值时，表示不生成该文件，
+// 保留沙箱根目录中原有的版本。
+const EtcFileInherit = "-"
+
+// EtcFilesConfig 定义沙箱内生成的 /etc/hosts、/etc/resolv.conf、/etc/passwd、/etc/group（父进程侧）。
+//
+// 继承自宿主机（或 lower 层）的这些文件会泄漏宿主机主机名、DNS 服务器和用户账户，
+// 且与沙箱内的 hostname、运行用户不一致。启用后子进程在 pivot_root 前按配置生成
+// 每个沙箱独立的版本，并 bind mount 到沙箱根目录的 /etc 下：
+//   - hosts：loopback 条目、沙箱 hostname 和 ExtraHosts
+//   - resolv.conf：DNS、DNSSearch、DNSOptions（未配置 DNS 时不含 nameserver）
+//   - passwd / group：root、nobody 以及沙箱运行用户（Namespace.User）和它的组，
+//     名称按沙箱根目录中原有的 /etc/passwd、/etc/group 解析
+//
+// Hosts、ResolvConf、Passwd、Group 为空时生成对应文件；设为宿主机文件路径时
+// 使用该文件的副本；设为 EtcFileInherit 时保留原有文件。
+type EtcFilesConfig struct {
+	Enabled    bool     // 是否启用
+	ExtraHosts []string // 额外的 hosts 条目，格式 "name:ip"
+	DNS        []string // nameserver 地址
+	DNSSearch  []string // search 域
+	DNSOptions []string // options，例如 "ndots:1"
+
+	// 覆盖
+	Hosts      string
+	ResolvConf string
+	Passwd     string
+	Group      string
+}
+
+// DefaultEtcFilesConfig 返回默认配置：启用、生成全部四个文件、不配置 DNS。
+func DefaultEtcFilesConfig() EtcFilesConfig {
+	return EtcFilesConfig{
+		Enabled: true,
+	}
+}
+
+// etcFilesInitConfig 通过管道传递给子进程的 /etc 文件生成配置。
+type etcFilesInitConfig struct {
+	Dir        string            `json:"dir"` // 宿主机上存放生成文件的目录
+	ExtraHosts []etcHostEntry    `json:"extra_hosts,omitempty"`
+	DNS        []string          `json:"dns,omitempty"`
+	DNSSearch  []string          `json:"dns_search,omitempty"`
+	DNSOptions []string          `json:"dns_options,omitempty"`
+	Overrides  map[string]string `json:"overrides,omitempty"` // 文件名 → 宿主机路径或 EtcFileInherit
+}
+
+// etcHostEntry 是一条额外的 hosts 条目。
+type etcHostEntry struct {
+	Name string `json:"name"`
+	IP   string `json:"ip"`
+}
+
+// etcFileNames 是生成的文件（相对 /etc），按写入顺序排列。
+var etcFileNames = []string{"hosts", "resolv.conf", "passwd", "group"}
+
+// 沙箱用户在原有 passwd/group 中不存在时使用的名称。
+const etcSandboxName = "sandbox"
+
+// initConfig 校验配置并转换为传递给子进程的配置，dir 为存放生成文件的宿主机目录。
+func (c *EtcFilesConfig) initConfig(dir string) (*etcFilesInitConfig, error) {
+	cfg := &etcFilesInitConfig{
+		Dir:        dir,
+		DNS:        c.DNS,
+		DNSSearch:  c.DNSSearch,
+		DNSOptions: c.DNSOptions,
+	}
+
+	for _, spec := range c.ExtraHosts {
+		name, ip, ok := strings.Cut(spec, ":")
+		if !ok || name == "" || net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("etc files: invalid host entry %q: expected name:ip", spec)
+		}
+		cfg.ExtraHosts = append(cfg.ExtraHosts, etcHostEntry{Name: name, IP: ip})
+	}
+	for _, ns := range c.DNS {
+		if net.ParseIP(ns) == nil {
+			return nil, fmt.Errorf("etc files: invalid DNS server %q", ns)
+		}
+	}
+
+	overrides := map[string]string{
+		"hosts":       c.Hosts,
+		"resolv.conf": c.ResolvConf,
+		"passwd":      c.Passwd,
+		"group":       c.Group,
+	}
+	for name, src := range overrides {
+		switch src {
+		case "":
+			continue
+		case EtcFileInherit:
+		default:
+			abs, err := filepath.Abs(src)
+			if err != nil {
+				return nil, fmt.Errorf("etc files: resolve %s override %q: %w", name, src, err)
+			}
+			fi, err := os.Stat(abs)
+			if err != nil {
+				return nil, fmt.Errorf("etc files: %s override: %w", name, err)
+			}
+			if !fi.Mode().IsRegular() {
+				return nil, fmt.Errorf("etc files: %s override %q is not a regular file", name, src)
+			}
+			src = abs
+		}
+		if cfg.Overrides == nil {
+			cfg.Overrides = make(map[string]string)
+		}
+		cfg.Overrides[name] = src
+	}
+	return cfg, nil
+}
+
+// setupEtcFiles 在子进程中生成 /etc 文件并 bind mount 到 rootDir/etc 下。
+// 此函数在新 Mount Namespace 内运行，由 nsInit() 在 bind mount 之后、pivot_root 之前调用，
+// 因此 passwd/group 中的用户名按沙箱根目录（而非生成后）的原有文件解析。
+//
+// rootDir 为空（未启用 OverlayFS 和 pivot_root）时目标是宿主机的 /etc，
+// 此时不存在的目标文件会被跳过，不在宿主机上创建。
+func setupEtcFiles(rootDir string, cfg *etcFilesInitConfig, hostname, user string, logWriter io.Writer) error {
+	onHost := rootDir == ""
+	if onHost {
+		rootDir = "/"
+	}
+
+	passwdPath, err := securejoin(rootDir, "/etc/passwd")
+	if err != nil {
+		return fmt.Errorf("resolve /etc/passwd: %w", err)
+	}
+	groupPath, err := securejoin(rootDir, "/etc/group")
+	if err != nil {
+		return fmt.Errorf("resolve /etc/group: %w", err)
+	}
+
+	// passwd 和 group 一起生成：组条目依赖用户解析结果
+	var passwd, group []byte
+	if cfg.Overrides["passwd"] == "" || cfg.Overrides["group"] == "" {
+		passwd, group, err = generatePasswdGroup(passwdPath, groupPath, user)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range etcFileNames {
+		var data []byte
+		switch src := cfg.Overrides[name]; src {
+		case EtcFileInherit:
+			continue
+		case "":
+			switch name {
+			case "hosts":
+				data = generateHosts(hostname, cfg.ExtraHosts)
+			case "resolv.conf":
+				data = generateResolvConf(cfg.DNS, cfg.DNSSearch, cfg.DNSOptions)
+			case "passwd":
+				data = passwd
+			case "group":
+				data = group
+			}
+		default:
+			if data, err = os.ReadFile(src); err != nil {
+				return fmt.Errorf("read %s override: %w", name, err)
+			}
+		}
+
+		target, err := securejoin(rootDir, filepath.Join("/etc", name))
+		if err != nil {
+			return fmt.Errorf("resolve /etc/%s: %w", name, err)
+		}
+		if _, err := os.Stat(target); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("stat %s: %w", target, err)
+			}
+			if onHost {
+				writeInitLog(logWriter, "warn", fmt.Sprintf("/etc/%s does not exist on host, skipped", name))
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("mkdir %s: %w", filepath.Dir(target), err)
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return fmt.Errorf("create %s: %w", target, err)
+			}
+			f.Close()
+		}
+
+		src := filepath.Join(cfg.Dir, name)
+		if err := os.WriteFile(src, data, 0644); err != nil {
+			return fmt.Errorf("write %s: %w", src, err)
+		}
+		if err := syscall.Mount(src, target, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("bind mount %s -> %s: %w", src, target, err)
+		}
+		if err := syscall.Mount("", target, "", syscall.MS_PRIVATE, ""); err != nil {
+			return fmt.Errorf("set propagation on %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// generateHosts 生成 /etc/hosts：loopback 条目（附带沙箱 hostname）和额外条目。
+func generateHosts(hostname string, extra []etcHostEntry) []byte {
+	var b strings.Builder
+	v4, v6 := "localhost", "localhost ip6-localhost ip6-loopback"
+	if hostname != "" && hostname != "localhost" {
+		v4 += " " + hostname
+		v6 += " " + hostname
+	}
+	fmt.Fprintf(&b, "127.0.0.1\t%s\n", v4)
+	fmt.Fprintf(&b, "::1\t%s\n", v6)
+	for _, e := range extra {
+		fmt.Fprintf(&b, "%s\t%s\n", e.IP, e.Name)
+	}
+	return []byte(b.String())
+}
+
+// generateResolvConf 生成 /etc/resolv.conf。
+func generateResolvConf(dns, search, options []string) []byte {
+	var b strings.Builder
+	for _, ns := range dns {
+		fmt.Fprintf(&b, "nameserver %s\n", ns)
+	}
+	if len(search) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(search, " "))
+	}
+	if len(options) > 0 {
+		fmt.Fprintf(&b, "options %s\n", strings.Join(options, " "))
+	}
+	return []byte(b.String())
+}
+
+// generatePasswdGroup 生成只包含 root、nobody 和沙箱运行用户的 passwd/group。
+//
+// 条目优先取自原有文件（保留镜像中的名称、家目录和 shell），不存在时合成默认条目；
+// 运行用户为不在原有文件中的数字 ID 时以 etcSandboxName 命名，
+// 使 whoami、id 等工具可以解析。
+func generatePasswdGroup(passwdPath, groupPath, user string) ([]byte, []byte, error) {
+	users, err := readPasswd(passwdPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("read %s: %w", passwdPath, err)
+	}
+	groups, err := readGroup(groupPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("read %s: %w", groupPath, err)
+	}
+
+	var pw []passwdEntry
+	var gr []groupEntry
+	addUser := func(e passwdEntry) {
+		for _, x := range pw {
+			if x.uid == e.uid || x.name == e.name {
+				return
+			}
+		}
+		pw = append(pw, e)
+	}
+	addGroup := func(g groupEntry) {
+		for _, x := range gr {
+			if x.gid == g.gid || x.name == g.name {
+				return
+			}
+		}
+		gr = append(gr, g)
+	}
+	userByID := func(uid int, def passwdEntry) passwdEntry {
+		for _, e := range users {
+			if e.uid == uid {
+				return e
+			}
+		}
+		return def
+	}
+	groupByID := func(gid int, def groupEntry) groupEntry {
+		for _, g := range groups {
+			if g.gid == gid {
+				return groupEntry{name: g.name, gid: g.gid}
+			}
+		}
+		return def
+	}
+
+	addUser(userByID(0, passwdEntry{name: "root", home: "/root", shell: "/bin/sh"}))
+	addGroup(groupByID(0, groupEntry{name: "root"}))
+
+	if user != "" {
+		u, err := resolveUser(user, passwdPath, groupPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resolve user: %w", err)
+		}
+		name := u.Name
+		if name == "" {
+			name = etcSandboxName
+		}
+		e := userByID(u.UID, passwdEntry{name: name, uid: u.UID, home: u.Home, shell: "/bin/sh"})
+		e.gid = u.GID
+		addUser(e)
+		addGroup(groupByID(u.GID, groupEntry{name: name, gid: u.GID}))
+		for _, gid := range u.Groups {
+			g := groupByID(gid, groupEntry{gid: gid})
+			g.members = []string{name}
+			addGroup(g)
+		}
+	}
+
+	addUser(userByID(65534, passwdEntry{name: "nobody", uid: 65534, gid: 65534, home: "/nonexistent", shell: "/usr/sbin/nologin"}))
+	addGroup(groupByID(65534, groupEntry{name: "nogroup", gid: 65534}))
+
+	var pb, gb strings.Builder
+	for _, e := range pw {
+		fmt.Fprintf(&pb, "%s:x:%d:%d:%s:%s:%s\n", e.name, e.uid, e.gid, e.name, e.home, e.shell)
+	}
+	for _, g := range gr {
+		fmt.Fprintf(&gb, "%s:x:%d:%s\n", g.name, g.gid, strings.Join(g.members, ","))
+	}
+	return []byte(pb.String()), []byte(gb.String()), nil
+}
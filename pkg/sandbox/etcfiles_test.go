@@ -0,0 +1,199 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ===================================================================
+// /etc 文件生成单元测试（不需要 root）
+// ===================================================================
+
+func TestGenerateHosts(t *testing.T) {
+	got := string(generateHosts("agent-1", []etcHostEntry{{Name: "db", IP: "10.0.0.5"}}))
+	want := "127.0.0.1\tlocalhost agent-1\n" +
+		"::1\tlocalhost ip6-localhost ip6-loopback agent-1\n" +
+		"10.0.0.5\tdb\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := string(generateHosts("", nil)); strings.Contains(got, "  ") || !strings.HasPrefix(got, "127.0.0.1\tlocalhost\n") {
+		t.Errorf("unexpected hosts without hostname: %q", got)
+	}
+}
+
+func TestGenerateResolvConf(t *testing.T) {
+	got := string(generateResolvConf([]string{"1.1.1.1", "8.8.8.8"}, []string{"corp.example"}, []string{"ndots:1"}))
+	want := "nameserver 1.1.1.1\nnameserver 8.8.8.8\nsearch corp.example\noptions ndots:1\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := generateResolvConf(nil, nil, nil); len(got) != 0 {
+		t.Errorf("expected empty resolv.conf, got %q", got)
+	}
+}
+
+func TestGeneratePasswdGroup(t *testing.T) {
+	passwd, group := writeUserFiles(t)
+
+	tests := []struct {
+		user       string
+		wantPasswd string
+		wantGroup  string
+	}{
+		{
+			"",
+			"root:x:0:0:root:/root:/bin/sh\nnobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin\n",
+			"root:x:0:\nnogroup:x:65534:\n",
+		},
+		{
+			// 宿主机上的其他账户不出现在结果中，附加组只列出运行用户
+			"agent",
+			"root:x:0:0:root:/root:/bin/sh\nagent:x:1000:1000:agent:/home/agent:/bin/sh\nnobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin\n",
+			"root:x:0:\nagent:x:1000:\ndocker:x:999:agent\nvideo:x:44:agent\nnogroup:x:65534:\n",
+		},
+		{
+			// 不在 passwd 中的数字 ID 以 sandbox 命名
+			"2000:3000",
+			"root:x:0:0:root:/root:/bin/sh\nsandbox:x:2000:3000:sandbox:/:/bin/sh\nnobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin\n",
+			"root:x:0:\nsandbox:x:3000:\nnogroup:x:65534:\n",
+		},
+	}
+	for _, tt := range tests {
+		pw, gr, err := generatePasswdGroup(passwd, group, tt.user)
+		if err != nil {
+			t.Errorf("user %q: %v", tt.user, err)
+			continue
+		}
+		if string(pw) != tt.wantPasswd {
+			t.Errorf("user %q passwd:\n%s\nwant:\n%s", tt.user, pw, tt.wantPasswd)
+		}
+		if string(gr) != tt.wantGroup {
+			t.Errorf("user %q group:\n%s\nwant:\n%s", tt.user, gr, tt.wantGroup)
+		}
+	}
+
+	if _, _, err := generatePasswdGroup(passwd, group, "missing"); err == nil {
+		t.Error("expected error for unknown user")
+	}
+	// 根目录中没有 passwd/group 时合成默认条目
+	dir := t.TempDir()
+	pw, _, err := generatePasswdGroup(filepath.Join(dir, "passwd"), filepath.Join(dir, "group"), "")
+	if err != nil || !strings.HasPrefix(string(pw), "root:x:0:0:root:/root:/bin/sh\n") {
+		t.Errorf("unexpected default passwd %q, %v", pw, err)
+	}
+}
+
+func TestEtcFilesInitConfig(t *testing.T) {
+	override := filepath.Join(t.TempDir(), "hosts")
+	os.WriteFile(override, []byte("127.0.0.1 custom\n"), 0644)
+
+	cfg := DefaultEtcFilesConfig()
+	cfg.ExtraHosts = []string{"db:10.0.0.5", "v6:fd00::1"}
+	cfg.DNS = []string{"1.1.1.1"}
+	cfg.Hosts = override
+	cfg.Passwd = EtcFileInherit
+	icfg, err := cfg.initConfig("/tmp/x")
+	if err != nil {
+		t.Fatalf("initConfig failed: %v", err)
+	}
+	if len(icfg.ExtraHosts) != 2 || icfg.ExtraHosts[1].IP != "fd00::1" {
+		t.Errorf("unexpected extra hosts: %+v", icfg.ExtraHosts)
+	}
+	if icfg.Overrides["hosts"] != override || icfg.Overrides["passwd"] != EtcFileInherit || len(icfg.Overrides) != 2 {
+		t.Errorf("unexpected overrides: %v", icfg.Overrides)
+	}
+
+	invalid := []EtcFilesConfig{
+		{ExtraHosts: []string{"db"}},
+		{ExtraHosts: []string{"db:not-an-ip"}},
+		{DNS: []string{"dns.example"}},
+		{ResolvConf: "/nonexistent/resolv.conf"},
+		{Group: t.TempDir()},
+	}
+	for _, c := range invalid {
+		if _, err := c.initConfig("/tmp/x"); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestEtcFilesRequirePivotRoot(t *testing.T) {
+	// 使用 overlay 但不 pivot_root 时，生成的文件挂载在进程看不到的 merged 目录中
+	ns := NewNamespace(NamespaceConfig{Mount: true})
+	ns.SetOverlayFS(NewOverlayFS(DefaultOverlayConfig("/")))
+	ecfg := DefaultEtcFilesConfig()
+	ns.SetEtcFiles(&ecfg)
+	err := ns.Start("true")
+	if err == nil {
+		ns.Cleanup()
+		t.Fatal("expected error for etc files without pivot_root")
+	}
+	if !strings.Contains(err.Error(), "require pivot_root") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// ===================================================================
+// /etc 文件生成集成测试（需要 root）
+// ===================================================================
+
+func TestNamespaceEtcFiles(t *testing.T) {
+	skipIfNotRoot(t)
+
+	ov := NewOverlayFS(DefaultOverlayConfig("/"))
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("overlay setup failed: %v", err)
+	}
+	config := DefaultNamespaceConfig()
+	config.Hostname = "etc-test"
+	ns := NewNamespace(config)
+	defer ns.Cleanup()
+	ns.SetOverlayFS(ov)
+	pcfg := DefaultPivotRootConfig()
+	ns.SetPivotRoot(&pcfg)
+	ns.User = "4321"
+
+	ecfg := DefaultEtcFilesConfig()
+	ecfg.DNS = []string{"192.0.2.53"}
+	ecfg.Group = EtcFileInherit
+	ns.SetEtcFiles(&ecfg)
+
+	var buf bytes.Buffer
+	r, w, _ := os.Pipe()
+	ns.Stdout = w
+
+	if err := ns.Start("sh", "-c", "cat /etc/hosts /etc/resolv.conf /etc/passwd; echo ---; cat /etc/group"); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	result, err := ns.Wait()
+	w.Close()
+	buf.ReadFrom(r)
+	r.Close()
+
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code: %d", result.ExitCode)
+	}
+
+	generated, inherited, _ := strings.Cut(buf.String(), "---\n")
+	for _, want := range []string{"localhost etc-test", "nameserver 192.0.2.53", "sandbox:x:4321:0:"} {
+		if !strings.Contains(generated, want) {
+			t.Errorf("expected %q in generated files:\n%s", want, generated)
+		}
+	}
+	if strings.Count(generated, ":x:") != 3 {
+		t.Errorf("passwd should only contain root, the sandbox user and nobody:\n%s", generated)
+	}
+	hostGroup, _ := os.ReadFile("/etc/group")
+	if inherited != string(hostGroup) {
+		t.Errorf("inherited /etc/group should match the lower layer:\n%s", inherited)
+	}
+}
@@ -0,0 +1,122 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// ===================================================================
+// 冻结单元测试（不需要 root）
+// ===================================================================
+
+func TestReadCgroupEvents(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "cgroup.events"), []byte("populated 1\nfrozen 0\n"), 0644)
+	events, err := readCgroupEvents(dir)
+	if err != nil {
+		t.Fatalf("readCgroupEvents failed: %v", err)
+	}
+	if events["populated"] != "1" || events["frozen"] != "0" {
+		t.Errorf("unexpected events: %v", events)
+	}
+	if _, err := readCgroupEvents(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for a missing cgroup")
+	}
+}
+
+func TestSetCgroupFrozenFixture(t *testing.T) {
+	// 模拟内核已完成冻结：写入 cgroup.freeze 后立即看到 frozen 1
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "cgroup.events"), []byte("populated 1\nfrozen 1\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "cgroup.freeze"), nil, 0644)
+	if err := SetCgroupFrozen(dir, true); err != nil {
+		t.Fatalf("SetCgroupFrozen failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "cgroup.freeze"))
+	if string(data) != "1" {
+		t.Errorf("cgroup.freeze = %q, want 1", data)
+	}
+}
+
+func TestPauseValidation(t *testing.T) {
+	ns := NewNamespace(DefaultNamespaceConfig())
+	if ns.State() != StateCreated {
+		t.Errorf("expected %s before Start, got %s", StateCreated, ns.State())
+	}
+	if err := ns.Pause(); err == nil {
+		t.Error("expected error when pausing a sandbox that is not running")
+	}
+
+	cg := NewCgroupsV2(DefaultCgroupsConfig())
+	if err := cg.Freeze(); err == nil {
+		t.Error("expected error when freezing before Setup")
+	}
+}
+
+// ===================================================================
+// 冻结集成测试（需要 root + cgroups v2）
+// ===================================================================
+
+func TestNamespacePauseResume(t *testing.T) {
+	base := cgroup2Mount(t)
+
+	cg := NewCgroupsV2(CgroupsConfig{Enabled: true, BaseDir: base})
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetCgroupsV2(cg)
+	if err := ns.Start("sh", "-c", "while :; do :; done"); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+
+	if err := ns.Pause(); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if ns.State() != StatePaused {
+		t.Errorf("expected %s, got %s", StatePaused, ns.State())
+	}
+
+	// 冻结期间 CPU 时间不再增长
+	before, err := cg.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	after, err := cg.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.Frozen {
+		t.Error("stats should report the cgroup as frozen")
+	}
+	if after.CPU.UsageUsec-before.CPU.UsageUsec > 10000 {
+		t.Errorf("frozen sandbox used %d usec of CPU", after.CPU.UsageUsec-before.CPU.UsageUsec)
+	}
+
+	if err := ns.Resume(); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if ns.State() != StateRunning {
+		t.Errorf("expected %s, got %s", StateRunning, ns.State())
+	}
+
+	// 冻结的沙箱也能被 SIGKILL 终止
+	if err := ns.Pause(); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	ns.Signal(syscall.SIGKILL)
+	if _, err := ns.Wait(); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if ns.State() != StateStopped {
+		t.Errorf("expected %s, got %s", StateStopped, ns.State())
+	}
+}
@@ -55,10 +55,11 @@ func MustReexecInit() {
 //  1. 从管道读取父进程传递的 initConfig
 //  2. 设置mount propagation为private（防止挂载事件泄漏到宿主机）
 //  3. 挂载OverlayFS（文件系统隔离，致命错误）
-//  4. 重新挂载/proc（使PID Namespace生效）
+//     随后 bind mount 宿主机路径、生成 /etc 文件、执行 pivot_root（均为致命错误）
+//  4. 重新挂载/proc（使PID Namespace生效），随后屏蔽敏感路径
 //  5. 设置hostname
 //  6. 启动loopback网卡
-//  7. syscall.Exec 替换为用户命令
+//  7. 切换运行用户和工作目录，syscall.Exec 替换为用户命令
 func nsInit() error {
 	// 1. 从管道读取配置
 	pipeFdStr := os.Getenv(initPipeEnv)
@@ -109,19 +110,48 @@ func nsInit() error {
 		}
 	}
 
+	// 沙箱的根目录：overlay 的 merged 目录优先，其次是 PivotRoot.RootDir
+	var newRoot string
+	if cfg.PivotRoot != nil {
+		newRoot = cfg.PivotRoot.RootDir
+	}
+	if cfg.Overlay != nil {
+		newRoot = cfg.Overlay.MergeDir
+	}
+
+	// 3.1. 构建 /dev（设备白名单、devpts、/dev/shm、/dev/mqueue）
+	// 仅在 pivot_root 时执行；在用户 bind mount 之前执行，否则新的 tmpfs 会遮住目标位于 /dev 下的 bind mount
+	// 失败是致命错误：缺失的 /dev/null、/dev/shm 会让用户命令以难以诊断的方式失败
+	if cfg.PivotRoot != nil && newRoot != "" && cfg.Dev != nil {
+		if err := setupDev(newRoot, cfg.Dev); err != nil {
+			return fmt.Errorf("setup /dev: %w", err)
+		}
+	}
+
+	// 3.2. bind mount 宿主机路径
+	// 在 OverlayFS 之后、pivot_root 之前执行，挂载到新 root 内使其在 pivot_root 后可见
+	// 失败是致命错误：Agent 依赖的目录缺失或只读约束失效都不可接受
+	if len(cfg.Mounts) > 0 {
+		if err := mountBindMounts(newRoot, cfg.Mounts); err != nil {
+			return fmt.Errorf("bind mounts: %w", err)
+		}
+	}
+
+	// 3.3. 生成 /etc/hosts、resolv.conf、passwd、group
+	// 在 bind mount 之后执行（用户可能 bind mount 了自己的 /etc），
+	// 在 pivot_root 之前执行（生成文件位于宿主机临时目录）
+	// 失败是致命错误：继承的文件会泄漏宿主机主机名、DNS 和用户账户
+	if cfg.EtcFiles != nil {
+		if err := setupEtcFiles(newRoot, cfg.EtcFiles, cfg.Hostname, cfg.User, logWriter); err != nil {
+			return fmt.Errorf("etc files: %w", err)
+		}
+	}
+
 	// 3.5. pivot_root（目录禁锢）
 	// 在 OverlayFS 之后、/proc 之前执行
 	// pivot_root 后子进程完全无法访问宿主机文件系统
 	if cfg.PivotRoot != nil {
-		newRoot := cfg.PivotRoot.RootDir
-		if cfg.Overlay != nil {
-			newRoot = cfg.Overlay.MergeDir // overlay 的 merged 目录作为新 root
-		}
 		if newRoot != "" {
-			// 在 pivot_root 前创建最小 /dev 设备
-			if err := setupMinimalDev(newRoot); err != nil {
-				writeInitLog(logWriter, "warn", fmt.Sprintf("setup /dev: %v (non-fatal)", err))
-			}
 			if err := doPivotRoot(newRoot); err != nil {
 				return fmt.Errorf("pivot_root: %w", err)
 			}
@@ -137,6 +167,15 @@ func nsInit() error {
 		}
 	}
 
+	// 4.5. 屏蔽 /proc、/sys 敏感路径并只读化内核接口
+	// 必须在 /proc 重新挂载之后执行，否则新的 procfs 会覆盖屏蔽
+	// 失败是致命错误：敏感内核接口暴露意味着安全边界被削弱
+	if cfg.MaskPaths != nil {
+		if err := applyMaskPaths(cfg.MaskPaths); err != nil {
+			return fmt.Errorf("mask paths: %w", err)
+		}
+	}
+
 	// 5. 设置hostname
 	if cfg.Hostname != "" {
 		if err := syscall.Sethostname([]byte(cfg.Hostname)); err != nil {
@@ -156,6 +195,20 @@ func nsInit() error {
 		logPipeFile.Close()
 	}
 
+	// 7.5. 切换运行用户
+	// 在 chdir 之前执行，使工作目录的权限检查以目标用户身份进行
+	var home string
+	if cfg.User != "" {
+		u, err := resolveUser(cfg.User, "/etc/passwd", "/etc/group")
+		if err != nil {
+			return fmt.Errorf("resolve user: %w", err)
+		}
+		if err := switchUser(u); err != nil {
+			return fmt.Errorf("switch user: %w", err)
+		}
+		home = u.Home
+	}
+
 	// 8. 切换工作目录
 	if cfg.WorkDir != "" {
 		if err := syscall.Chdir(cfg.WorkDir); err != nil {
@@ -165,6 +218,13 @@ func nsInit() error {
 
 	// 9. 准备环境变量
 	env := buildCleanEnv(cfg.Env)
+	if home != "" && envValue(env, "HOME") == "" {
+		env = append(env, "HOME="+home)
+	}
+	// 命令按沙箱内的 PATH 查找（镜像可能设置了不同于宿主机的 PATH）
+	if path := envValue(env, "PATH"); path != "" {
+		os.Setenv("PATH", path)
+	}
 
 	// 9.5. 加载 Seccomp-BPF 过滤器
 	// 必须在所有特权操作（mount、pivot_root、sethostname）完成后、exec 前加载
@@ -228,3 +288,13 @@ func buildCleanEnv(userEnv []string) []string {
 	}
 	return clean
 }
+
+// envValue 返回 KEY=VALUE 列表中 key 的值，不存在时返回空。
+func envValue(env []string, key string) string {
+	for i := len(env) - 1; i >= 0; i-- {
+		if v, ok := strings.CutPrefix(env[i], key+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
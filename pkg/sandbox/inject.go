@@ -0,0 +1,389 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// InjectFile 描述注入到沙箱中的单个文件。
+type InjectFile struct {
+	Path string      // 沙箱内的绝对路径
+	Data []byte      // 文件内容
+	Mode os.FileMode // 权限位（0 表示 0644）
+	UID  int         // 属主 UID
+	GID  int         // 属组 GID
+}
+
+// injectEntry 是各种注入来源（内存数据、tar 流、宿主机路径）统一后的条目。
+type injectEntry struct {
+	path     string      // 沙箱内路径
+	typ      byte        // tar.TypeReg / TypeDir / TypeSymlink / TypeLink
+	mode     os.FileMode // 权限位（含 setuid/setgid/sticky）
+	uid      int
+	gid      int
+	linkname string    // 符号链接目标或硬链接源（沙箱内路径）
+	modTime  time.Time // 零值表示不设置
+	body     io.Reader // 普通文件内容
+}
+
+// InjectFiles 在沙箱启动前向 OverlayFS 上层写入文件，文件会出现在合并视图的指定路径。
+//
+// 必须在 Setup() 之后、Namespace.Start() 之前调用：overlay 挂载后直接修改上层目录
+// 属于未定义行为。路径中已存在于下层的符号链接（如 /bin -> usr/bin）会在沙箱视图内解析，
+// 缺失的父目录按下层对应目录的权限和属主在上层创建。
+func (ov *OverlayFS) InjectFiles(files []InjectFile) error {
+	ov.mu.Lock()
+	defer ov.mu.Unlock()
+
+	for _, f := range files {
+		mode := f.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		e := injectEntry{
+			path: f.Path,
+			typ:  tar.TypeReg,
+			mode: mode,
+			uid:  f.UID,
+			gid:  f.GID,
+			body: bytes.NewReader(f.Data),
+		}
+		if err := ov.writeEntry(&e); err != nil {
+			return err
+		}
+	}
+
+	if ov.logger != nil {
+		ov.logger.Info("overlay inject files", zap.String("overlay_id", ov.id), zap.Int("count", len(files)))
+	}
+	return nil
+}
+
+// InjectData 是 InjectFiles 的简化形式：以 root:root 0644 写入 path→内容 映射。
+func (ov *OverlayFS) InjectData(files map[string][]byte) error {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	list := make([]InjectFile, 0, len(paths))
+	for _, p := range paths {
+		list = append(list, InjectFile{Path: p, Data: files[p]})
+	}
+	return ov.InjectFiles(list)
+}
+
+// InjectTar 将 tar 流解包到沙箱的根目录下，保留权限位、属主和修改时间。
+// 支持普通文件、目录、符号链接和硬链接，其余类型（设备、FIFO）被忽略。
+// 条目路径视为相对沙箱根目录，".." 不会越过根目录。
+func (ov *OverlayFS) InjectTar(r io.Reader) error {
+	ov.mu.Lock()
+	defer ov.mu.Unlock()
+
+	tr := tar.NewReader(r)
+	count := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("overlayfs: inject tar: %w", err)
+		}
+
+		e := injectEntry{
+			path:     "/" + hdr.Name,
+			typ:      hdr.Typeflag,
+			mode:     tarHeaderMode(hdr),
+			uid:      hdr.Uid,
+			gid:      hdr.Gid,
+			linkname: hdr.Linkname,
+			modTime:  hdr.ModTime,
+			body:     tr,
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeDir, tar.TypeSymlink:
+		case tar.TypeLink:
+			e.linkname = "/" + hdr.Linkname
+		default:
+			continue
+		}
+		if err := ov.writeEntry(&e); err != nil {
+			return err
+		}
+		count++
+	}
+
+	if ov.logger != nil {
+		ov.logger.Info("overlay inject tar", zap.String("overlay_id", ov.id), zap.Int("count", count))
+	}
+	return nil
+}
+
+// InjectHostPath 将宿主机上的文件或目录（递归）复制到沙箱内的 dst 路径，
+// 保留权限位、属主和修改时间。符号链接按原样复制，不跟随。
+func (ov *OverlayFS) InjectHostPath(src, dst string) error {
+	ov.mu.Lock()
+	defer ov.mu.Unlock()
+
+	src = filepath.Clean(src)
+	count := 0
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		e := injectEntry{
+			path:    filepath.Join(dst, rel),
+			mode:    fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky),
+			modTime: fi.ModTime(),
+		}
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			e.uid, e.gid = int(st.Uid), int(st.Gid)
+		}
+
+		switch {
+		case fi.IsDir():
+			e.typ = tar.TypeDir
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			e.typ = tar.TypeSymlink
+			e.linkname = target
+		case fi.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			e.typ = tar.TypeReg
+			e.body = f
+		default:
+			return nil
+		}
+
+		count++
+		return ov.writeEntry(&e)
+	})
+	if err != nil {
+		return fmt.Errorf("overlayfs: inject %s: %w", src, err)
+	}
+
+	if ov.logger != nil {
+		ov.logger.Info("overlay inject host path",
+			zap.String("overlay_id", ov.id),
+			zap.String("src", src),
+			zap.String("dst", dst),
+			zap.Int("count", count),
+		)
+	}
+	return nil
+}
+
+// writeEntry 将单个条目写入上层目录。调用方必须持有 ov.mu。
+func (ov *OverlayFS) writeEntry(e *injectEntry) error {
+	if !ov.setupDone {
+		return fmt.Errorf("overlayfs: not set up")
+	}
+	if ov.config.ReadOnly {
+		return fmt.Errorf("overlayfs: cannot inject into read-only overlay")
+	}
+
+	clean := filepath.Clean("/" + e.path)
+	if clean == "/" {
+		// 根目录本身：只有目录条目有意义，且根目录属性由 Setup() 决定
+		if e.typ == tar.TypeDir {
+			return nil
+		}
+		return fmt.Errorf("overlayfs: inject: invalid path %q", e.path)
+	}
+
+	// 父目录在合并视图中解析符号链接，最后一个分量保持字面值
+	// （符号链接条目需要创建链接本身，而不是跟随它）
+	parent, err := ov.resolveMerged(filepath.Dir(clean))
+	if err != nil {
+		return fmt.Errorf("overlayfs: inject %s: %w", e.path, err)
+	}
+	if err := ov.ensureUpperDirs(parent); err != nil {
+		return fmt.Errorf("overlayfs: inject %s: %w", e.path, err)
+	}
+	target := filepath.Join(ov.upperDir, parent, filepath.Base(clean))
+
+	switch e.typ {
+	case tar.TypeDir:
+		if err := os.Mkdir(target, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("overlayfs: inject mkdir %s: %w", e.path, err)
+		}
+	case tar.TypeReg:
+		if err := removeNonDir(target); err != nil {
+			return fmt.Errorf("overlayfs: inject %s: %w", e.path, err)
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|syscall.O_NOFOLLOW, 0600)
+		if err != nil {
+			return fmt.Errorf("overlayfs: inject create %s: %w", e.path, err)
+		}
+		if e.body != nil {
+			if _, err := io.Copy(f, e.body); err != nil {
+				f.Close()
+				return fmt.Errorf("overlayfs: inject write %s: %w", e.path, err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("overlayfs: inject write %s: %w", e.path, err)
+		}
+	case tar.TypeSymlink:
+		if err := removeNonDir(target); err != nil {
+			return fmt.Errorf("overlayfs: inject %s: %w", e.path, err)
+		}
+		if err := os.Symlink(e.linkname, target); err != nil {
+			return fmt.Errorf("overlayfs: inject symlink %s: %w", e.path, err)
+		}
+	case tar.TypeLink:
+		src, err := ov.resolveMerged(e.linkname)
+		if err != nil {
+			return fmt.Errorf("overlayfs: inject link %s: %w", e.path, err)
+		}
+		if err := removeNonDir(target); err != nil {
+			return fmt.Errorf("overlayfs: inject %s: %w", e.path, err)
+		}
+		// 硬链接只能指向同一上层中已注入的文件
+		if err := os.Link(filepath.Join(ov.upperDir, src), target); err != nil {
+			return fmt.Errorf("overlayfs: inject link %s -> %s: %w", e.path, e.linkname, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("overlayfs: inject %s: unsupported type %q", e.path, e.typ)
+	}
+
+	return applyInjectAttrs(target, e)
+}
+
+// resolveMerged 在 OverlayFS 合并视图中解析路径（跟随符号链接），返回以 "/" 开头的路径。
+// 每个路径分量依次在上层和各下层中查找，与内核 overlay 的查找顺序一致。
+func (ov *OverlayFS) resolveMerged(p string) (string, error) {
+	layers := append([]string{ov.upperDir}, ov.config.LowerDirs...)
+	return walkSymlinks(p, func(rel string) (os.FileInfo, string, error) {
+		for _, layer := range layers {
+			fi, target, err := lstatLink(filepath.Join(layer, rel))
+			if err == nil {
+				return fi, target, nil
+			}
+			if !os.IsNotExist(err) {
+				return nil, "", err
+			}
+		}
+		return nil, "", os.ErrNotExist
+	})
+}
+
+// ensureUpperDirs 在上层目录中创建 dir 的各级目录。
+// 下层已存在的目录复制其权限和属主（例如 /tmp 的 1777），避免上层目录改变合并视图中的属性。
+func (ov *OverlayFS) ensureUpperDirs(dir string) error {
+	if dir == "/" {
+		return nil
+	}
+	if err := ov.ensureUpperDirs(filepath.Dir(dir)); err != nil {
+		return err
+	}
+
+	upper := filepath.Join(ov.upperDir, dir)
+	if fi, err := os.Lstat(upper); err == nil {
+		if !fi.IsDir() {
+			return fmt.Errorf("%s: not a directory", dir)
+		}
+		return nil
+	}
+
+	mode, uid, gid := os.FileMode(0755), 0, 0
+	for _, lower := range ov.config.LowerDirs {
+		fi, err := os.Stat(filepath.Join(lower, dir))
+		if err != nil {
+			continue
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("%s: not a directory", dir)
+		}
+		mode = fi.Mode() & (os.ModePerm | os.ModeSetgid | os.ModeSticky)
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(st.Uid), int(st.Gid)
+		}
+		break
+	}
+
+	if err := os.Mkdir(upper, 0700); err != nil {
+		return err
+	}
+	if err := os.Lchown(upper, uid, gid); err != nil {
+		return err
+	}
+	return os.Chmod(upper, mode)
+}
+
+// applyInjectAttrs 设置注入条目的属主、权限和修改时间。
+// chown 必须在 chmod 之前：chown 会清除 setuid/setgid 位。
+func applyInjectAttrs(path string, e *injectEntry) error {
+	if err := os.Lchown(path, e.uid, e.gid); err != nil {
+		return fmt.Errorf("overlayfs: inject chown %s: %w", e.path, err)
+	}
+	if e.typ == tar.TypeSymlink {
+		return nil
+	}
+	if err := os.Chmod(path, e.mode); err != nil {
+		return fmt.Errorf("overlayfs: inject chmod %s: %w", e.path, err)
+	}
+	if !e.modTime.IsZero() {
+		if err := os.Chtimes(path, e.modTime, e.modTime); err != nil {
+			return fmt.Errorf("overlayfs: inject chtimes %s: %w", e.path, err)
+		}
+	}
+	return nil
+}
+
+// removeNonDir 删除已存在的非目录文件，便于覆盖写入。
+func removeNonDir(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s: is a directory", path)
+	}
+	return os.Remove(path)
+}
+
+// tarHeaderMode 从 tar 头部提取权限位（含 setuid/setgid/sticky）。
+func tarHeaderMode(hdr *tar.Header) os.FileMode {
+	mode := os.FileMode(hdr.Mode) & os.ModePerm
+	if hdr.Mode&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if hdr.Mode&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if hdr.Mode&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
@@ -0,0 +1,219 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// ===================================================================
+// 文件注入单元测试（不需要 root）
+// ===================================================================
+
+func TestTarHeaderMode(t *testing.T) {
+	hdr := &tar.Header{Mode: 04755}
+	mode := tarHeaderMode(hdr)
+	if mode&os.ModePerm != 0755 {
+		t.Errorf("expected perm 0755, got %o", mode&os.ModePerm)
+	}
+	if mode&os.ModeSetuid == 0 {
+		t.Error("expected setuid bit")
+	}
+}
+
+func TestInjectBeforeSetup(t *testing.T) {
+	ov := NewOverlayFS(DefaultOverlayConfig("/"))
+	if err := ov.InjectData(map[string][]byte{"/a": []byte("x")}); err == nil {
+		t.Error("expected error when injecting before Setup")
+	}
+}
+
+// ===================================================================
+// 文件注入集成测试（需要 root）
+// ===================================================================
+
+// setupInjectOverlay 创建以临时目录为下层的 OverlayFS。
+func setupInjectOverlay(t *testing.T) (*OverlayFS, string) {
+	t.Helper()
+	skipIfNotRoot(t)
+
+	lower := t.TempDir()
+	os.MkdirAll(filepath.Join(lower, "usr", "bin"), 0755)
+	os.Symlink("usr/bin", filepath.Join(lower, "bin"))
+	os.MkdirAll(filepath.Join(lower, "tmp"), 0755)
+	os.Chmod(filepath.Join(lower, "tmp"), os.ModePerm|os.ModeSticky)
+
+	ov := NewOverlayFS(DefaultOverlayConfig(lower))
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	t.Cleanup(func() { ov.Cleanup() })
+	return ov, lower
+}
+
+func TestInjectData(t *testing.T) {
+	ov, lower := setupInjectOverlay(t)
+
+	err := ov.InjectData(map[string][]byte{
+		"/work/main.py":       []byte("print('hi')"),
+		"/tmp/input/data.csv": []byte("a,b"),
+	})
+	if err != nil {
+		t.Fatalf("InjectData failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(ov.UpperDir(), "work", "main.py"))
+	if err != nil || string(data) != "print('hi')" {
+		t.Errorf("unexpected upper content: %q, %v", data, err)
+	}
+
+	// 上层新建的父目录应继承下层 /tmp 的 sticky 权限
+	fi, err := os.Stat(filepath.Join(ov.UpperDir(), "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSticky == 0 || fi.Mode()&os.ModePerm != 0777 {
+		t.Errorf("expected /tmp mode 1777, got %v", fi.Mode())
+	}
+
+	// 下层不应被修改
+	if _, err := os.Stat(filepath.Join(lower, "work")); !os.IsNotExist(err) {
+		t.Error("lower dir should not be modified")
+	}
+}
+
+func TestInjectFollowsLowerSymlinks(t *testing.T) {
+	ov, _ := setupInjectOverlay(t)
+
+	if err := ov.InjectFiles([]InjectFile{{Path: "/bin/tool", Data: []byte("#!/bin/sh"), Mode: 0755}}); err != nil {
+		t.Fatalf("InjectFiles failed: %v", err)
+	}
+
+	// /bin -> usr/bin 在下层，文件应写入上层的 usr/bin，而不是创建遮蔽符号链接的 bin 目录
+	if _, err := os.Lstat(filepath.Join(ov.UpperDir(), "bin")); !os.IsNotExist(err) {
+		t.Error("upper should not contain a bin directory shadowing the lower symlink")
+	}
+	fi, err := os.Stat(filepath.Join(ov.UpperDir(), "usr", "bin", "tool"))
+	if err != nil {
+		t.Fatalf("injected file not found: %v", err)
+	}
+	if fi.Mode()&os.ModePerm != 0755 {
+		t.Errorf("expected mode 0755, got %v", fi.Mode())
+	}
+}
+
+func TestInjectPathEscape(t *testing.T) {
+	ov, _ := setupInjectOverlay(t)
+
+	if err := ov.InjectData(map[string][]byte{"/../../escape.txt": []byte("x")}); err != nil {
+		t.Fatalf("InjectData failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ov.UpperDir(), "escape.txt")); err != nil {
+		t.Errorf("'..' should be clamped to the sandbox root: %v", err)
+	}
+}
+
+func TestInjectTar(t *testing.T) {
+	ov, _ := setupInjectOverlay(t)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "data/", Typeflag: tar.TypeDir, Mode: 0750, Uid: 1000, Gid: 1000})
+	content := []byte("hello")
+	tw.WriteHeader(&tar.Header{Name: "data/a.txt", Typeflag: tar.TypeReg, Mode: 0600, Size: int64(len(content)), Uid: 1000, Gid: 1000})
+	tw.Write(content)
+	tw.WriteHeader(&tar.Header{Name: "data/link", Typeflag: tar.TypeSymlink, Linkname: "a.txt"})
+	tw.WriteHeader(&tar.Header{Name: "data/hard", Typeflag: tar.TypeLink, Linkname: "data/a.txt"})
+	tw.Close()
+
+	if err := ov.InjectTar(&buf); err != nil {
+		t.Fatalf("InjectTar failed: %v", err)
+	}
+
+	upper := ov.UpperDir()
+	fi, err := os.Stat(filepath.Join(upper, "data", "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := fi.Sys().(*syscall.Stat_t)
+	if st.Uid != 1000 || st.Gid != 1000 {
+		t.Errorf("expected owner 1000:1000, got %d:%d", st.Uid, st.Gid)
+	}
+	if fi.Mode()&os.ModePerm != 0600 {
+		t.Errorf("expected mode 0600, got %v", fi.Mode())
+	}
+	if target, _ := os.Readlink(filepath.Join(upper, "data", "link")); target != "a.txt" {
+		t.Errorf("expected symlink to a.txt, got %q", target)
+	}
+	if st.Nlink != 2 {
+		t.Errorf("expected hard link count 2, got %d", st.Nlink)
+	}
+}
+
+func TestInjectHostPath(t *testing.T) {
+	ov, _ := setupInjectOverlay(t)
+
+	src := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	os.WriteFile(filepath.Join(src, "sub", "f.txt"), []byte("host"), 0640)
+
+	if err := ov.InjectHostPath(src, "/input"); err != nil {
+		t.Fatalf("InjectHostPath failed: %v", err)
+	}
+
+	p := filepath.Join(ov.UpperDir(), "input", "sub", "f.txt")
+	data, err := os.ReadFile(p)
+	if err != nil || string(data) != "host" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+	fi, _ := os.Stat(p)
+	if fi.Mode()&os.ModePerm != 0640 {
+		t.Errorf("expected mode 0640, got %v", fi.Mode())
+	}
+}
+
+func TestInjectVisibleInSandbox(t *testing.T) {
+	skipIfNotRoot(t)
+
+	ov := NewOverlayFS(DefaultOverlayConfig("/"))
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := ov.InjectData(map[string][]byte{"/sandbox-input/in.txt": []byte("injected")}); err != nil {
+		t.Fatalf("InjectData failed: %v", err)
+	}
+
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetOverlayFS(ov)
+	pcfg := DefaultPivotRootConfig()
+	ns.SetPivotRoot(&pcfg)
+
+	var buf bytes.Buffer
+	r, w, _ := os.Pipe()
+	ns.Stdout = w
+
+	if err := ns.Start("cat", "/sandbox-input/in.txt"); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	result, err := ns.Wait()
+	w.Close()
+	buf.ReadFrom(r)
+	r.Close()
+
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code: %d", result.ExitCode)
+	}
+	if strings.TrimSpace(buf.String()) != "injected" {
+		t.Errorf("expected 'injected', got %q", buf.String())
+	}
+}
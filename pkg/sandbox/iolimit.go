@@ -0,0 +1,93 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// IOLimit 是单个块设备的 io.max 限制，0 表示不限制对应项。
+type IOLimit struct {
+	// Device 为 "major:minor"、块设备节点（如 /dev/vda）或任意文件/目录路径。
+	// 文件/目录解析为其所在文件系统的块设备；分区解析为所属的整块磁盘
+	// （io.max 只接受整块磁盘）。
+	Device string
+	RBps   uint64 // 读带宽（字节/秒）
+	WBps   uint64 // 写带宽（字节/秒）
+	RIOPS  uint64 // 读 IOPS
+	WIOPS  uint64 // 写 IOPS
+}
+
+// ioMaxLine 生成写入 io.max 的一行，例如 "254:0 rbps=1048576 wiops=100"。
+// all 为 true 时未设置的项也写为 "max"（用于清除旧限制）。
+func (l IOLimit) ioMaxLine(major, minor uint32, all bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d:%d", major, minor)
+	for _, kv := range []struct {
+		key string
+		v   uint64
+	}{{"rbps", l.RBps}, {"wbps", l.WBps}, {"riops", l.RIOPS}, {"wiops", l.WIOPS}} {
+		if kv.v > 0 {
+			fmt.Fprintf(&b, " %s=%d", kv.key, kv.v)
+		} else if all {
+			fmt.Fprintf(&b, " %s=max", kv.key)
+		}
+	}
+	return b.String()
+}
+
+// resolveIODevice 将 IOLimit.Device 解析为整块磁盘的 major:minor。
+func resolveIODevice(device string) (uint32, uint32, error) {
+	if majStr, minStr, ok := strings.Cut(device, ":"); ok && !strings.HasPrefix(device, "/") {
+		major, err1 := strconv.ParseUint(majStr, 10, 32)
+		minor, err2 := strconv.ParseUint(minStr, 10, 32)
+		if err1 != nil || err2 != nil {
+			return 0, 0, fmt.Errorf("invalid device %q (expected major:minor or a path)", device)
+		}
+		return uint32(major), uint32(minor), nil
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(device, &st); err != nil {
+		return 0, 0, fmt.Errorf("stat %s: %w", device, err)
+	}
+	dev := st.Dev
+	if st.Mode&unix.S_IFMT == unix.S_IFBLK {
+		dev = st.Rdev
+	}
+	major, minor := unix.Major(dev), unix.Minor(dev)
+	// tmpfs、overlay、proc 等虚拟文件系统使用匿名设备（major 0），不能限速
+	if major == 0 {
+		return 0, 0, fmt.Errorf("%s is not backed by a block device", device)
+	}
+	return wholeDisk(major, minor)
+}
+
+// wholeDisk 通过 /sys/dev/block 将分区的 major:minor 转换为所属磁盘的 major:minor。
+// 非分区设备（包括 device-mapper、loop）原样返回。
+func wholeDisk(major, minor uint32) (uint32, uint32, error) {
+	sysDir := fmt.Sprintf("/sys/dev/block/%d:%d", major, minor)
+	if _, err := os.Stat(filepath.Join(sysDir, "partition")); err != nil {
+		return major, minor, nil
+	}
+	// /sys/dev/block/M:m 是指向 .../<disk>/<partition> 的符号链接，上一级即为磁盘
+	real, err := filepath.EvalSymlinks(sysDir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("resolve disk of partition %d:%d: %w", major, minor, err)
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(real), "dev"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("resolve disk of partition %d:%d: %w", major, minor, err)
+	}
+	var dmaj, dmin uint32
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%d:%d", &dmaj, &dmin); err != nil {
+		return 0, 0, fmt.Errorf("resolve disk of partition %d:%d: %w", major, minor, err)
+	}
+	return dmaj, dmin, nil
+}
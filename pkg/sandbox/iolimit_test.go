@@ -0,0 +1,116 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// ===================================================================
+// I/O 限制单元测试（不需要 root）
+// ===================================================================
+
+func TestIOMaxLine(t *testing.T) {
+	tests := []struct {
+		limit IOLimit
+		want  string
+	}{
+		{IOLimit{RBps: 1048576}, "8:0 rbps=1048576"},
+		{IOLimit{WBps: 2097152, WIOPS: 100}, "8:0 wbps=2097152 wiops=100"},
+		{IOLimit{RBps: 1, WBps: 2, RIOPS: 3, WIOPS: 4}, "8:0 rbps=1 wbps=2 riops=3 wiops=4"},
+	}
+	for _, tt := range tests {
+		if got := tt.limit.ioMaxLine(8, 0, false); got != tt.want {
+			t.Errorf("ioMaxLine(%+v) = %q, want %q", tt.limit, got, tt.want)
+		}
+	}
+	if got := (IOLimit{WBps: 10}).ioMaxLine(8, 0, true); got != "8:0 rbps=max wbps=10 riops=max wiops=max" {
+		t.Errorf("ioMaxLine(all) = %q", got)
+	}
+}
+
+func TestResolveIODevice(t *testing.T) {
+	major, minor, err := resolveIODevice("254:16")
+	if err != nil || major != 254 || minor != 16 {
+		t.Errorf("resolveIODevice(254:16) = %d:%d, %v", major, minor, err)
+	}
+
+	for _, dev := range []string{"8:x", "a:0", "/nonexistent/path", "/proc"} {
+		if _, _, err := resolveIODevice(dev); err == nil {
+			t.Errorf("expected error for %q", dev)
+		}
+	}
+}
+
+func TestResolveIODevicePath(t *testing.T) {
+	// 在测试目录所在的块设备上解析，结果应为一个整块磁盘
+	dir := t.TempDir()
+	var st unix.Stat_t
+	if err := unix.Stat(dir, &st); err != nil {
+		t.Fatal(err)
+	}
+	if unix.Major(st.Dev) == 0 {
+		t.Skip("skipping: temp dir is not on a block device")
+	}
+
+	major, minor, err := resolveIODevice(dir)
+	if err != nil {
+		t.Fatalf("resolveIODevice failed: %v", err)
+	}
+	sysDir := fmt.Sprintf("/sys/dev/block/%d:%d", major, minor)
+	if _, err := os.Stat(sysDir); err != nil {
+		t.Fatalf("resolved device %d:%d does not exist: %v", major, minor, err)
+	}
+	if _, err := os.Stat(filepath.Join(sysDir, "partition")); err == nil {
+		t.Errorf("resolved device %d:%d is a partition, expected the whole disk", major, minor)
+	}
+}
+
+// ===================================================================
+// I/O 限制集成测试（需要 root + cgroups v2 io 控制器）
+// ===================================================================
+
+func TestCgroupsIOLimits(t *testing.T) {
+	skipIfNoCgroupsV2(t)
+	data, err := os.ReadFile("/sys/fs/cgroup/cgroup.controllers")
+	if err != nil || !strings.Contains(string(data), "io") {
+		t.Skip("skipping: io controller not available")
+	}
+
+	dir := t.TempDir()
+	cg := NewCgroupsV2(CgroupsConfig{
+		Enabled:  true,
+		IOLimits: []IOLimit{{Device: dir, WBps: 1048576, RIOPS: 1000}},
+		IOWeight: 50,
+	})
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer cg.Cleanup()
+
+	major, minor, err := resolveIODevice(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioMax, err := os.ReadFile(filepath.Join(cg.CgroupDir(), "io.max"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("%d:%d rbps=max wbps=1048576 riops=1000 wiops=max", major, minor)
+	if !strings.Contains(string(ioMax), want) {
+		t.Errorf("io.max = %q, want %q", ioMax, want)
+	}
+	weight, err := os.ReadFile(filepath.Join(cg.CgroupDir(), "io.weight"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(weight), "default 50") {
+		t.Errorf("io.weight = %q, want default 50", weight)
+	}
+}
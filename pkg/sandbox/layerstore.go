@@ -0,0 +1,713 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultLayerStoreDir 是本地层存储的默认根目录。
+const DefaultLayerStoreDir = "/var/lib/ai-sandbox"
+
+// LayerInfo 描述层存储中的一个只读层。
+type LayerInfo struct {
+	Digest  string    `json:"digest"`  // 未压缩 tar 流的内容摘要，格式 "sha256:<hex>"
+	Size    int64     `json:"size"`    // 解包后普通文件的总字节数
+	Created time.Time `json:"created"` // 导入时间
+}
+
+// ImageManifest 描述一个镜像：按从底到顶顺序排列的层，解析为 OverlayConfig.LowerDirs。
+type ImageManifest struct {
+	Name    string       `json:"name"`
+	Layers  []string     `json:"layers"`           // 层摘要，从底层到顶层
+	Config  *ImageConfig `json:"config,omitempty"` // 运行时默认配置（导入的 OCI/Docker 镜像）
+	Created time.Time    `json:"created"`
+}
+
+// LayerStore 管理本地按内容寻址的只读层和镜像清单。
+//
+// 目录结构：
+//
+//	<root>/layers/<hex>/diff        解包后的层内容（作为 overlay lowerdir）
+//	<root>/layers/<hex>/layer.json  层元数据
+//	<root>/layers/<hex>/refs/<id>   运行中沙箱的引用（内容为持有者 PID）
+//	<root>/images/<name>.json       镜像清单
+//	<root>/cache/<key>              构建缓存：步骤键 → 层摘要（见 Builder）
+//	<root>/tmp/<name>               导入和构建过程中的临时目录
+//	<root>/tmp/<name>.lock          临时目录的锁（flock），使用期间一直持有
+//	<root>/store.lock               跨进程互斥锁（flock）
+//
+// 使用方式：
+//
+//	store, err := sandbox.NewLayerStore(sandbox.DefaultLayerStoreDir)
+//	lowerDirs, release, err := store.Acquire("python-3.12-ds")
+//	defer release()
+//	ovConfig.LowerDirs = lowerDirs
+type LayerStore struct {
+	root   string
+	logger *zap.Logger
+}
+
+var (
+	digestPattern    = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	imageNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*(:[A-Za-z0-9._-]+)?$`)
+)
+
+// NewLayerStore 打开（必要时创建）位于 root 的层存储。
+func NewLayerStore(root string) (*LayerStore, error) {
+	if root == "" {
+		root = DefaultLayerStoreDir
+	}
+	if strings.ContainsAny(root, ",:") {
+		// overlay 的 lowerdir 选项以 ':' 分隔层、以 ',' 分隔选项
+		return nil, fmt.Errorf("layerstore: root %q must not contain ',' or ':'", root)
+	}
+	for _, dir := range []string{"layers", "images", "cache", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0700); err != nil {
+			return nil, fmt.Errorf("layerstore: mkdir %s: %w", dir, err)
+		}
+	}
+	return &LayerStore{root: root}, nil
+}
+
+// SetLogger 设置此LayerStore的日志记录器。
+func (s *LayerStore) SetLogger(l *zap.Logger) {
+	s.logger = l
+}
+
+// Root 返回层存储的根目录。
+func (s *LayerStore) Root() string {
+	return s.root
+}
+
+// lock 获取跨进程的排他锁，返回解锁函数。
+func (s *LayerStore) lock() (func(), error) {
+	f, err := os.OpenFile(filepath.Join(s.root, "store.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("layerstore: open lock: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("layerstore: flock: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// tempDir 在 tmp/ 下创建以 prefix 开头的临时目录，返回目录和清理函数。
+//
+// 目录旁的锁文件 <dir>.lock 在目录的整个使用期间持有 flock，Prune 只清理锁未被持有的
+// 临时目录（中断的导入或构建的残留）。锁文件在存储锁内创建并加锁，Prune 不会看到未加锁的新目录，
+// 因此调用方不能持有存储锁。清理函数删除目录和锁文件后释放锁。
+func (s *LayerStore) tempDir(prefix string) (string, func(), error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return "", nil, err
+	}
+	defer unlock()
+
+	f, err := os.CreateTemp(filepath.Join(s.root, "tmp"), prefix+"*.lock")
+	if err != nil {
+		return "", nil, fmt.Errorf("layerstore: tmp: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		os.Remove(f.Name())
+		f.Close()
+		return "", nil, fmt.Errorf("layerstore: tmp: flock %s: %w", f.Name(), err)
+	}
+	dir := strings.TrimSuffix(f.Name(), ".lock")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		os.Remove(f.Name())
+		f.Close()
+		return "", nil, fmt.Errorf("layerstore: tmp: %w", err)
+	}
+	return dir, func() {
+		os.RemoveAll(dir)
+		os.Remove(f.Name())
+		f.Close()
+	}, nil
+}
+
+// removeStaleTemp 删除锁未被持有的临时目录 path 及其锁文件。没有锁文件的条目也视为残留。
+// 调用方持有存储锁，tempDir 不会在此期间创建新的临时目录。
+func removeStaleTemp(path string) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR, 0)
+	switch {
+	case err == nil:
+		defer f.Close()
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			return
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return
+	}
+	os.RemoveAll(path)
+	os.Remove(path + ".lock")
+}
+
+// layerPath 返回层的根目录（layers/<hex>），摘要格式非法时返回错误。
+func (s *LayerStore) layerPath(digest string) (string, error) {
+	if !digestPattern.MatchString(digest) {
+		return "", fmt.Errorf("layerstore: invalid digest %q", digest)
+	}
+	return filepath.Join(s.root, "layers", strings.TrimPrefix(digest, "sha256:")), nil
+}
+
+// imagePath 返回镜像清单路径，名称格式非法时返回错误。
+func (s *LayerStore) imagePath(name string) (string, error) {
+	if !imageNamePattern.MatchString(name) {
+		return "", fmt.Errorf("layerstore: invalid image name %q", name)
+	}
+	return filepath.Join(s.root, "images", name+".json"), nil
+}
+
+// ImportLayer 从 tar 流（支持 gzip 压缩）导入一个层，返回层信息。
+//
+// 摘要基于未压缩的 tar 流计算（与 OCI diffID 一致），相同内容只存储一份。
+// 解包在临时目录中进行，完成后原子地重命名到 layers/<hex>。
+func (s *LayerStore) ImportLayer(r io.Reader) (LayerInfo, error) {
+	stream, err := maybeGunzip(r)
+	if err != nil {
+		return LayerInfo{}, fmt.Errorf("layerstore: import: %w", err)
+	}
+
+	tmp, removeTmp, err := s.tempDir("layer-")
+	if err != nil {
+		return LayerInfo{}, err
+	}
+	defer removeTmp()
+
+	hasher := sha256.New()
+	tee := io.TeeReader(stream, hasher)
+	size, err := unpackLayerTar(tee, filepath.Join(tmp, "diff"))
+	if err != nil {
+		return LayerInfo{}, fmt.Errorf("layerstore: import: %w", err)
+	}
+	// tar 结束标记之后可能还有填充块，需计入摘要
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return LayerInfo{}, fmt.Errorf("layerstore: import: %w", err)
+	}
+
+	info := LayerInfo{
+		Digest:  "sha256:" + hex.EncodeToString(hasher.Sum(nil)),
+		Size:    size,
+		Created: time.Now().UTC(),
+	}
+	if err := writeJSONFile(filepath.Join(tmp, "layer.json"), &info); err != nil {
+		return LayerInfo{}, fmt.Errorf("layerstore: import: %w", err)
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return LayerInfo{}, err
+	}
+	defer unlock()
+
+	dst, _ := s.layerPath(info.Digest)
+	if _, err := os.Stat(dst); err == nil {
+		// 内容已存在，返回已有层的信息
+		existing, err := s.layerInfo(info.Digest)
+		if err != nil {
+			return LayerInfo{}, err
+		}
+		return existing, nil
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return LayerInfo{}, fmt.Errorf("layerstore: import: %w", err)
+	}
+
+	if s.logger != nil {
+		s.logger.Info("layer imported", zap.String("digest", info.Digest), zap.Int64("size", info.Size))
+	}
+	return info, nil
+}
+
+// layerInfo 读取层元数据。
+func (s *LayerStore) layerInfo(digest string) (LayerInfo, error) {
+	p, err := s.layerPath(digest)
+	if err != nil {
+		return LayerInfo{}, err
+	}
+	var info LayerInfo
+	if err := readJSONFile(filepath.Join(p, "layer.json"), &info); err != nil {
+		return LayerInfo{}, fmt.Errorf("layerstore: layer %s: %w", digest, err)
+	}
+	return info, nil
+}
+
+// LayerDir 返回层内容目录（可直接用作 overlay lowerdir）。
+func (s *LayerStore) LayerDir(digest string) (string, error) {
+	p, err := s.layerPath(digest)
+	if err != nil {
+		return "", err
+	}
+	diff := filepath.Join(p, "diff")
+	if _, err := os.Stat(diff); err != nil {
+		return "", fmt.Errorf("layerstore: layer %s: %w", digest, err)
+	}
+	return diff, nil
+}
+
+// Layers 返回存储中的所有层（按摘要排序）。
+func (s *LayerStore) Layers() ([]LayerInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(s.root, "layers"))
+	if err != nil {
+		return nil, fmt.Errorf("layerstore: list layers: %w", err)
+	}
+	var layers []LayerInfo
+	for _, e := range entries {
+		info, err := s.layerInfo("sha256:" + e.Name())
+		if err != nil {
+			continue
+		}
+		layers = append(layers, info)
+	}
+	sort.Slice(layers, func(i, j int) bool { return layers[i].Digest < layers[j].Digest })
+	return layers, nil
+}
+
+// CreateImage 写入镜像清单。清单引用的层必须已存在；同名镜像会被覆盖。
+func (s *LayerStore) CreateImage(m ImageManifest) error {
+	p, err := s.imagePath(m.Name)
+	if err != nil {
+		return err
+	}
+	if len(m.Layers) == 0 {
+		return fmt.Errorf("layerstore: image %s: no layers", m.Name)
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, d := range m.Layers {
+		if _, err := s.LayerDir(d); err != nil {
+			return err
+		}
+	}
+	if m.Created.IsZero() {
+		m.Created = time.Now().UTC()
+	}
+	if err := writeJSONFile(p, &m); err != nil {
+		return fmt.Errorf("layerstore: write image %s: %w", m.Name, err)
+	}
+
+	if s.logger != nil {
+		s.logger.Info("image created", zap.String("image", m.Name), zap.Strings("layers", m.Layers))
+	}
+	return nil
+}
+
+// Image 读取镜像清单。
+func (s *LayerStore) Image(name string) (*ImageManifest, error) {
+	p, err := s.imagePath(name)
+	if err != nil {
+		return nil, err
+	}
+	var m ImageManifest
+	if err := readJSONFile(p, &m); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("layerstore: image %s not found", name)
+		}
+		return nil, fmt.Errorf("layerstore: read image %s: %w", name, err)
+	}
+	return &m, nil
+}
+
+// Images 返回所有镜像清单（按名称排序）。
+func (s *LayerStore) Images() ([]ImageManifest, error) {
+	entries, err := os.ReadDir(filepath.Join(s.root, "images"))
+	if err != nil {
+		return nil, fmt.Errorf("layerstore: list images: %w", err)
+	}
+	var images []ImageManifest
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		m, err := s.Image(name)
+		if err != nil {
+			continue
+		}
+		images = append(images, *m)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Name < images[j].Name })
+	return images, nil
+}
+
+// RemoveImage 删除镜像清单。层本身由 Prune() 回收。
+func (s *LayerStore) RemoveImage(name string) error {
+	p, err := s.imagePath(name)
+	if err != nil {
+		return err
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(p); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("layerstore: image %s not found", name)
+		}
+		return fmt.Errorf("layerstore: remove image %s: %w", name, err)
+	}
+	return nil
+}
+
+// ResolveLowerDirs 将镜像解析为 OverlayConfig.LowerDirs（顶层在前，与 overlay 优先级一致）。
+func (s *LayerStore) ResolveLowerDirs(name string) ([]string, error) {
+	m, err := s.Image(name)
+	if err != nil {
+		return nil, err
+	}
+	dirs := make([]string, 0, len(m.Layers))
+	for i := len(m.Layers) - 1; i >= 0; i-- {
+		d, err := s.LayerDir(m.Layers[i])
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, nil
+}
+
+// Acquire 解析镜像并为其所有层登记引用，防止沙箱运行期间被 Prune() 回收。
+// 返回 LowerDirs 和释放函数；释放函数应在沙箱 Cleanup 之后调用。
+//
+// 引用以 refs/<id> 文件记录持有者 PID，持有进程崩溃后残留的引用会在 Prune 时被忽略。
+func (s *LayerStore) Acquire(name string) ([]string, func() error, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	m, err := s.Image(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.acquireLayers(m.Layers)
+}
+
+// acquireLayers 为给定层（从底到顶）登记引用，返回 LowerDirs（顶层在前）和释放函数。
+// 调用方必须持有存储锁。
+func (s *LayerStore) acquireLayers(layers []string) ([]string, func() error, error) {
+	dirs := make([]string, 0, len(layers))
+	for i := len(layers) - 1; i >= 0; i-- {
+		d, err := s.LayerDir(layers[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		dirs = append(dirs, d)
+	}
+
+	refID := generateID()
+	var refs []string
+	release := func() error {
+		var errs []error
+		for _, r := range refs {
+			if err := os.Remove(r); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("layerstore: release: %v", errs)
+		}
+		return nil
+	}
+
+	for _, d := range layers {
+		p, _ := s.layerPath(d)
+		refDir := filepath.Join(p, "refs")
+		if err := os.MkdirAll(refDir, 0700); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("layerstore: acquire %s: %w", d, err)
+		}
+		ref := filepath.Join(refDir, refID)
+		if err := os.WriteFile(ref, []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("layerstore: acquire %s: %w", d, err)
+		}
+		refs = append(refs, ref)
+	}
+	return dirs, release, nil
+}
+
+// RefCount 返回层当前的有效引用数：引用它的镜像数 + 持有者仍存活的运行时引用数。
+func (s *LayerStore) RefCount(digest string) (int, error) {
+	images, err := s.Images()
+	if err != nil {
+		return 0, err
+	}
+	return s.refCount(digest, images)
+}
+
+func (s *LayerStore) refCount(digest string, images []ImageManifest) (int, error) {
+	p, err := s.layerPath(digest)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range images {
+		for _, d := range m.Layers {
+			if d == digest {
+				count++
+				break
+			}
+		}
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(p, "refs"))
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(p, "refs", e.Name()))
+		if err != nil {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || !processAlive(pid) {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Prune 删除没有被任何镜像引用、也没有运行中沙箱引用的层，返回被删除的层摘要。
+// 同时清理指向已删除层的构建缓存，以及导入中断残留的临时目录。
+func (s *LayerStore) Prune() ([]string, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	images, err := s.Images()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := s.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, l := range layers {
+		n, err := s.refCount(l.Digest, images)
+		if err != nil {
+			return removed, err
+		}
+		if n > 0 {
+			continue
+		}
+		p, _ := s.layerPath(l.Digest)
+		if err := os.RemoveAll(p); err != nil {
+			return removed, fmt.Errorf("layerstore: prune %s: %w", l.Digest, err)
+		}
+		removed = append(removed, l.Digest)
+	}
+
+	// 构建缓存只记录层摘要，不阻止回收；删除失效的缓存项
+	if entries, err := os.ReadDir(filepath.Join(s.root, "cache")); err == nil {
+		for _, e := range entries {
+			if _, ok := s.cachedLayer(e.Name()); !ok {
+				os.Remove(filepath.Join(s.root, "cache", e.Name()))
+			}
+		}
+	}
+
+	// 导入和构建的解包阶段不持存储锁，跳过锁仍被持有的临时目录
+	if entries, err := os.ReadDir(filepath.Join(s.root, "tmp")); err == nil {
+		for _, e := range entries {
+			removeStaleTemp(filepath.Join(s.root, "tmp", strings.TrimSuffix(e.Name(), ".lock")))
+		}
+	}
+
+	if s.logger != nil {
+		s.logger.Info("layer store pruned", zap.Strings("removed", removed))
+	}
+	return removed, nil
+}
+
+// processAlive 判断 PID 对应的进程是否存在。
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// maybeGunzip 检测 gzip 魔数，必要时返回解压后的流。
+func maybeGunzip(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// unpackLayerTar 将 tar 流解包到 dir，返回普通文件的总字节数。
+//
+// 条目路径在 dir 内解析（securejoin），符号链接不会把写入引导到 dir 之外。
+// 保留权限位、属主和修改时间，支持目录、普通文件、符号链接、硬链接和设备/FIFO 节点。
+// OCI whiteout（.wh.<name>、.wh..wh..opq）被转换为 overlay 的字符设备和 opaque 标记。
+func unpackLayerTar(r io.Reader, dir string) (int64, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	var size int64
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return size, fmt.Errorf("read tar: %w", err)
+		}
+
+		name := filepath.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		parent, err := securejoin(dir, filepath.Dir(name))
+		if err != nil {
+			return size, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return size, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		base := filepath.Base(name)
+
+		// OCI whiteout 转换为 overlay 原生格式，使层可以直接叠加为 lowerdir
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			if err := applyWhiteout(parent, base); err != nil {
+				return size, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			continue
+		}
+		target := filepath.Join(parent, base)
+
+		// 不信任镜像中的设备节点：除 0:0 字符设备（overlay whiteout）外一律跳过，
+		// 沙箱需要的设备由 DevConfig 提供
+		if hdr.Typeflag == tar.TypeBlock ||
+			(hdr.Typeflag == tar.TypeChar && (hdr.Devmajor != 0 || hdr.Devminor != 0)) {
+			continue
+		}
+
+		if hdr.Typeflag != tar.TypeDir {
+			if err := removeNonDir(target); err != nil {
+				return size, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.Mkdir(target, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+				return size, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY|syscall.O_NOFOLLOW, 0600)
+			if err != nil {
+				return size, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			n, err := io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return size, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			size += n
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return size, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		case tar.TypeLink:
+			src, err := securejoin(dir, hdr.Linkname)
+			if err != nil {
+				return size, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			if err := os.Link(src, target); err != nil {
+				return size, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			continue
+		case tar.TypeChar, tar.TypeFifo:
+			mode := uint32(hdr.Mode & 07777)
+			if hdr.Typeflag == tar.TypeChar {
+				mode |= unix.S_IFCHR
+			} else {
+				mode |= unix.S_IFIFO
+			}
+			dev := int(unix.Mkdev(uint32(hdr.Devmajor), uint32(hdr.Devminor)))
+			if err := unix.Mknod(target, mode, dev); err != nil {
+				return size, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		default:
+			// 其他类型（如 PAX 全局头）由 tar.Reader 处理或无需落盘
+			continue
+		}
+
+		if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+			return size, fmt.Errorf("%s: chown: %w", hdr.Name, err)
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			continue
+		}
+		if err := os.Chmod(target, tarHeaderMode(hdr)); err != nil {
+			return size, fmt.Errorf("%s: chmod: %w", hdr.Name, err)
+		}
+		if !hdr.ModTime.IsZero() {
+			os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+		}
+	}
+}
+
+// writeJSONFile 原子地写入 JSON 文件（先写临时文件再重命名）。
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readJSONFile 读取并解析 JSON 文件。
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
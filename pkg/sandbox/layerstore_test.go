@@ -0,0 +1,328 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ===================================================================
+// LayerStore 单元测试（不需要 root）
+// ===================================================================
+
+// buildLayerTar 构造包含给定文件的 tar 流，属主为当前用户以便非 root 也能解包。
+func buildLayerTar(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	uid, gid := os.Getuid(), os.Getgid()
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content)), Uid: uid, Gid: gid}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+func TestLayerStoreInvalidRoot(t *testing.T) {
+	if _, err := NewLayerStore("/tmp/a:b"); err == nil {
+		t.Error("expected error for root containing ':'")
+	}
+}
+
+func TestImportLayerDigest(t *testing.T) {
+	store, err := NewLayerStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buildLayerTar(t, map[string]string{"etc/os-release": "ID=test\n"})
+	info, err := store.ImportLayer(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ImportLayer failed: %v", err)
+	}
+	if !digestPattern.MatchString(info.Digest) {
+		t.Errorf("unexpected digest %q", info.Digest)
+	}
+	if info.Size != int64(len("ID=test\n")) {
+		t.Errorf("expected size %d, got %d", len("ID=test\n"), info.Size)
+	}
+
+	dir, err := store.LayerDir(info.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "etc", "os-release"))
+	if err != nil || string(content) != "ID=test\n" {
+		t.Errorf("unexpected layer content: %q, %v", content, err)
+	}
+
+	// gzip 压缩的相同内容摘要一致，只存储一份
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(data)
+	zw.Close()
+	info2, err := store.ImportLayer(&gz)
+	if err != nil {
+		t.Fatalf("ImportLayer(gzip) failed: %v", err)
+	}
+	if info2.Digest != info.Digest {
+		t.Errorf("gzip import digest %s != %s", info2.Digest, info.Digest)
+	}
+	layers, _ := store.Layers()
+	if len(layers) != 1 {
+		t.Errorf("expected 1 layer after dedup, got %d", len(layers))
+	}
+}
+
+func TestImportLayerPathEscape(t *testing.T) {
+	root := t.TempDir()
+	store, _ := NewLayerStore(root)
+
+	info, err := store.ImportLayer(bytes.NewReader(buildLayerTar(t, map[string]string{"../../escape": "x"})))
+	if err != nil {
+		t.Fatalf("ImportLayer failed: %v", err)
+	}
+	dir, _ := store.LayerDir(info.Digest)
+	if _, err := os.Stat(filepath.Join(dir, "escape")); err != nil {
+		t.Errorf("'..' should be clamped to the layer root: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escape")); !os.IsNotExist(err) {
+		t.Error("entry escaped the layer directory")
+	}
+}
+
+func TestImageResolveLowerDirs(t *testing.T) {
+	store, _ := NewLayerStore(t.TempDir())
+
+	base, _ := store.ImportLayer(bytes.NewReader(buildLayerTar(t, map[string]string{"base": "1"})))
+	top, _ := store.ImportLayer(bytes.NewReader(buildLayerTar(t, map[string]string{"top": "2"})))
+
+	if err := store.CreateImage(ImageManifest{Name: "py:3.12", Layers: []string{base.Digest, top.Digest}}); err != nil {
+		t.Fatalf("CreateImage failed: %v", err)
+	}
+
+	dirs, err := store.ResolveLowerDirs("py:3.12")
+	if err != nil {
+		t.Fatalf("ResolveLowerDirs failed: %v", err)
+	}
+	baseDir, _ := store.LayerDir(base.Digest)
+	topDir, _ := store.LayerDir(top.Digest)
+	if len(dirs) != 2 || dirs[0] != topDir || dirs[1] != baseDir {
+		t.Errorf("expected [top base], got %v", dirs)
+	}
+
+	images, _ := store.Images()
+	if len(images) != 1 || images[0].Name != "py:3.12" {
+		t.Errorf("unexpected images: %+v", images)
+	}
+}
+
+func TestCreateImageValidation(t *testing.T) {
+	store, _ := NewLayerStore(t.TempDir())
+	missing := "sha256:" + strings.Repeat("0", 64)
+
+	tests := []struct {
+		name string
+		m    ImageManifest
+	}{
+		{"bad name", ImageManifest{Name: "../evil", Layers: []string{missing}}},
+		{"no layers", ImageManifest{Name: "empty"}},
+		{"bad digest", ImageManifest{Name: "x", Layers: []string{"sha256:zz"}}},
+		{"missing layer", ImageManifest{Name: "x", Layers: []string{missing}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.CreateImage(tt.m); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	if _, err := store.Image("nope"); err == nil {
+		t.Error("expected error for missing image")
+	}
+}
+
+func TestAcquireAndPrune(t *testing.T) {
+	store, _ := NewLayerStore(t.TempDir())
+
+	used, _ := store.ImportLayer(bytes.NewReader(buildLayerTar(t, map[string]string{"a": "1"})))
+	orphan, _ := store.ImportLayer(bytes.NewReader(buildLayerTar(t, map[string]string{"b": "2"})))
+	if err := store.CreateImage(ImageManifest{Name: "img", Layers: []string{used.Digest}}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, release, err := store.Acquire("img")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if n, _ := store.RefCount(used.Digest); n != 2 {
+		t.Errorf("expected refcount 2 (image + sandbox), got %d", n)
+	}
+
+	// 删除镜像后，运行中沙箱的引用仍保护层不被回收
+	if err := store.RemoveImage("img"); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := store.Prune()
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != orphan.Digest {
+		t.Errorf("expected only orphan layer pruned, got %v", removed)
+	}
+
+	if err := release(); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	removed, _ = store.Prune()
+	if len(removed) != 1 || removed[0] != used.Digest {
+		t.Errorf("expected released layer pruned, got %v", removed)
+	}
+}
+
+func TestPruneIgnoresStaleRefs(t *testing.T) {
+	store, _ := NewLayerStore(t.TempDir())
+
+	info, _ := store.ImportLayer(bytes.NewReader(buildLayerTar(t, map[string]string{"a": "1"})))
+	p, _ := store.layerPath(info.Digest)
+	os.MkdirAll(filepath.Join(p, "refs"), 0700)
+	// 不存在的 PID 模拟崩溃进程残留的引用
+	os.WriteFile(filepath.Join(p, "refs", "stale"), []byte(strconv.Itoa(1<<30)), 0600)
+
+	removed, err := store.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 {
+		t.Errorf("stale ref should not keep layer alive, removed %v", removed)
+	}
+}
+
+func TestPruneSkipsLockedTempDirs(t *testing.T) {
+	store, _ := NewLayerStore(t.TempDir())
+	tmpRoot := filepath.Join(store.Root(), "tmp")
+
+	// 进行中的导入：锁被持有，与修改时间无关
+	active, removeActive, err := store.tempDir("layer-")
+	if err != nil {
+		t.Fatalf("tempDir failed: %v", err)
+	}
+	old := time.Now().Add(-24 * time.Hour)
+	os.Chtimes(active, old, old)
+
+	// 中断的导入：锁文件还在但锁已随进程退出释放；没有锁文件的旧目录
+	crashed := filepath.Join(tmpRoot, "layer-crashed")
+	os.Mkdir(crashed, 0700)
+	os.WriteFile(crashed+".lock", nil, 0600)
+	legacy := filepath.Join(tmpRoot, "layer-legacy")
+	os.Mkdir(legacy, 0700)
+
+	if _, err := store.Prune(); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if _, err := os.Stat(active); err != nil {
+		t.Errorf("locked temp dir removed: %v", err)
+	}
+	for _, p := range []string{crashed, crashed + ".lock", legacy} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("stale temp entry %s not removed", p)
+		}
+	}
+
+	removeActive()
+	if entries, _ := os.ReadDir(tmpRoot); len(entries) != 0 {
+		t.Errorf("temp entries left after cleanup: %v", entries)
+	}
+}
+
+// ===================================================================
+// LayerStore 集成测试（需要 root）
+// ===================================================================
+
+func TestImportLayerDeviceNodes(t *testing.T) {
+	skipIfNotRoot(t)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "dev/sda", Typeflag: tar.TypeBlock, Mode: 0660, Devmajor: 8},
+		{Name: "dev/mem", Typeflag: tar.TypeChar, Mode: 0640, Devmajor: 1, Devminor: 1},
+		{Name: "etc/removed", Typeflag: tar.TypeChar, Mode: 0},
+		{Name: "run/fifo", Typeflag: tar.TypeFifo, Mode: 0600},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+
+	store, _ := NewLayerStore(t.TempDir())
+	info, err := store.ImportLayer(&buf)
+	if err != nil {
+		t.Fatalf("ImportLayer failed: %v", err)
+	}
+	dir, _ := store.LayerDir(info.Digest)
+
+	for _, name := range []string{"dev/sda", "dev/mem"} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("device node %s should not be created", name)
+		}
+	}
+	fi, err := os.Lstat(filepath.Join(dir, "etc", "removed"))
+	if err != nil || !isWhiteout(fi) {
+		t.Errorf("expected 0:0 whiteout for etc/removed, got %v, %v", fi, err)
+	}
+	if fi, err := os.Lstat(filepath.Join(dir, "run", "fifo")); err != nil || fi.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("expected fifo for run/fifo, got %v, %v", fi, err)
+	}
+}
+
+func TestImageAsOverlayLower(t *testing.T) {
+	skipIfNotRoot(t)
+
+	store, _ := NewLayerStore(t.TempDir())
+	base, _ := store.ImportLayer(bytes.NewReader(buildLayerTar(t, map[string]string{"data/a.txt": "base", "data/b.txt": "base"})))
+	top, _ := store.ImportLayer(bytes.NewReader(buildLayerTar(t, map[string]string{"data/b.txt": "top"})))
+	store.CreateImage(ImageManifest{Name: "img", Layers: []string{base.Digest, top.Digest}})
+
+	lowerDirs, release, err := store.Acquire("img")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ovCfg := DefaultOverlayConfig("")
+	ovCfg.LowerDirs = lowerDirs
+	ov := NewOverlayFS(ovCfg)
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("overlay setup: %v", err)
+	}
+	defer ov.Cleanup()
+
+	// 按 overlay 语义读取各层，验证顶层遮蔽底层
+	acfg := DefaultArtifactConfig(t.TempDir(), "/data/*.txt")
+	res, err := ov.CollectArtifacts(acfg)
+	if err != nil {
+		t.Fatalf("CollectArtifacts failed: %v", err)
+	}
+	if len(res.Files) != 2 {
+		t.Fatalf("expected 2 files, got %+v", res.Files)
+	}
+	data, _ := os.ReadFile(filepath.Join(acfg.DestDir, "data", "b.txt"))
+	if string(data) != "top" {
+		t.Errorf("top layer should shadow base, got %q", data)
+	}
+}
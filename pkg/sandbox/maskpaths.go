@@ -0,0 +1,164 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// MaskPathsConfig 定义 /proc 与 /sys 加固配置（父进程侧）。
+//
+// pivot_root 后 mountProc 挂载的是完整的 procfs，/proc/kcore、/proc/sysrq-trigger、
+// /proc/sys 等敏感接口仍可访问。此配置在 /proc 挂载后：
+//   - 将 MaskedPaths 覆盖为空（文件 bind /dev/null，目录挂载只读空 tmpfs）
+//   - 将 ReadonlyPaths 重新挂载为只读
+//   - 可选地挂载一个全新的只读 sysfs，替代继承自宿主机（或 lower 层）的 /sys
+type MaskPathsConfig struct {
+	Enabled       bool     // 是否启用
+	MaskedPaths   []string // 需要屏蔽的路径（nil 则使用 defaultMaskedPaths，空切片表示不屏蔽）
+	ReadonlyPaths []string // 需要只读的路径（nil 则使用 defaultReadonlyPaths，空切片表示不只读化）
+	MountSysfs    bool     // 在沙箱内挂载只读 sysfs
+}
+
+// DefaultMaskPathsConfig 返回默认配置：启用、使用默认屏蔽/只读列表、不挂载 sysfs。
+func DefaultMaskPathsConfig() MaskPathsConfig {
+	return MaskPathsConfig{
+		Enabled:       true,
+		MaskedPaths:   nil, // nil 表示使用 defaultMaskedPaths
+		ReadonlyPaths: nil, // nil 表示使用 defaultReadonlyPaths
+		MountSysfs:    false,
+	}
+}
+
+// maskPathsInitConfig 通过管道传递给子进程的路径加固配置。
+type maskPathsInitConfig struct {
+	MaskedPaths   []string `json:"masked_paths,omitempty"`
+	ReadonlyPaths []string `json:"readonly_paths,omitempty"`
+	MountSysfs    bool     `json:"mount_sysfs,omitempty"`
+}
+
+// defaultMaskedPaths 定义默认屏蔽的路径。
+// 这些接口会泄漏宿主机内核内存、密钥、硬件或调度信息。
+var defaultMaskedPaths = []string{
+	"/proc/acpi",
+	"/proc/asound",
+	"/proc/kcore",
+	"/proc/keys",
+	"/proc/latency_stats",
+	"/proc/timer_list",
+	"/proc/timer_stats",
+	"/proc/sched_debug",
+	"/proc/scsi",
+	"/sys/firmware",
+	"/sys/devices/virtual/powercap",
+}
+
+// defaultReadonlyPaths 定义默认只读的路径。
+// 这些接口可读但写入会影响整个宿主机内核（sysctl、SysRq、中断亲和性等）。
+var defaultReadonlyPaths = []string{
+	"/proc/bus",
+	"/proc/fs",
+	"/proc/irq",
+	"/proc/sys",
+	"/proc/sysrq-trigger",
+}
+
+// initConfig 将 MaskPathsConfig 转换为传递给子进程的配置，nil 列表替换为默认值。
+func (c *MaskPathsConfig) initConfig() *maskPathsInitConfig {
+	masked := c.MaskedPaths
+	if masked == nil {
+		masked = defaultMaskedPaths
+	}
+	readonly := c.ReadonlyPaths
+	if readonly == nil {
+		readonly = defaultReadonlyPaths
+	}
+	return &maskPathsInitConfig{
+		MaskedPaths:   masked,
+		ReadonlyPaths: readonly,
+		MountSysfs:    c.MountSysfs,
+	}
+}
+
+// applyMaskPaths 在子进程中执行 /proc 与 /sys 加固。
+// 此函数在 pivot_root 与 mountProc 之后运行，由 nsInit() 调用。
+//
+// 步骤：
+//  1. 挂载只读 sysfs（可选，必须先于屏蔽，否则 /sys 下的屏蔽会被新挂载覆盖）
+//  2. 屏蔽 MaskedPaths
+//  3. 只读化 ReadonlyPaths
+//
+// 不存在的路径会被跳过（不同内核暴露的 procfs 条目不同）。
+func applyMaskPaths(cfg *maskPathsInitConfig) error {
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.MountSysfs {
+		if err := mountSysfs(); err != nil {
+			return fmt.Errorf("mount sysfs: %w", err)
+		}
+	}
+
+	for _, p := range cfg.MaskedPaths {
+		if err := maskPath(p); err != nil {
+			return fmt.Errorf("mask %s: %w", p, err)
+		}
+	}
+
+	for _, p := range cfg.ReadonlyPaths {
+		if err := readonlyPath(p); err != nil {
+			return fmt.Errorf("readonly %s: %w", p, err)
+		}
+	}
+
+	return nil
+}
+
+// mountSysfs 以只读方式挂载新的 sysfs 到 /sys。
+func mountSysfs() error {
+	if err := os.MkdirAll("/sys", 0555); err != nil {
+		return err
+	}
+	_ = syscall.Unmount("/sys", syscall.MNT_DETACH)
+	flags := uintptr(syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC)
+	return syscall.Mount("sysfs", "/sys", "sysfs", flags, "")
+}
+
+// maskPath 屏蔽单个路径：文件以 /dev/null 覆盖，目录以只读空 tmpfs 覆盖。
+func maskPath(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if fi.IsDir() {
+		return syscall.Mount("tmpfs", path, "tmpfs", syscall.MS_RDONLY, "size=0,mode=0555")
+	}
+	return syscall.Mount("/dev/null", path, "", syscall.MS_BIND, "")
+}
+
+// readonlyPath 将单个路径 bind mount 到自身后重新挂载为只读。
+func readonlyPath(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if err := syscall.Mount(path, path, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind: %w", err)
+	}
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC)
+	if err := syscall.Mount("", path, "", flags, ""); err != nil {
+		return fmt.Errorf("remount ro: %w", err)
+	}
+	return nil
+}
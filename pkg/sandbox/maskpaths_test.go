@@ -0,0 +1,138 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// ===================================================================
+// MaskPaths 单元测试（不需要 root）
+// ===================================================================
+
+func TestDefaultMaskPathsConfig(t *testing.T) {
+	cfg := DefaultMaskPathsConfig()
+	if !cfg.Enabled {
+		t.Error("default config should be enabled")
+	}
+	if cfg.MountSysfs {
+		t.Error("default config should not mount sysfs")
+	}
+
+	icfg := cfg.initConfig()
+	for _, want := range []string{"/proc/kcore", "/sys/firmware"} {
+		if !containsString(icfg.MaskedPaths, want) {
+			t.Errorf("default masked paths should contain %s", want)
+		}
+	}
+	for _, want := range []string{"/proc/sys", "/proc/sysrq-trigger"} {
+		if !containsString(icfg.ReadonlyPaths, want) {
+			t.Errorf("default readonly paths should contain %s", want)
+		}
+	}
+}
+
+func TestMaskPathsEmptyListsOverrideDefaults(t *testing.T) {
+	cfg := MaskPathsConfig{Enabled: true, MaskedPaths: []string{}, ReadonlyPaths: []string{}}
+	icfg := cfg.initConfig()
+	if len(icfg.MaskedPaths) != 0 || len(icfg.ReadonlyPaths) != 0 {
+		t.Errorf("explicit empty lists should not fall back to defaults: %+v", icfg)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ===================================================================
+// MaskPaths 集成测试（需要 root）
+// ===================================================================
+
+// runMaskedSandbox 在启用 overlay + pivot_root + 路径加固的沙箱中执行脚本并返回输出。
+func runMaskedSandbox(t *testing.T, mcfg MaskPathsConfig, script string) string {
+	t.Helper()
+
+	ov := NewOverlayFS(DefaultOverlayConfig("/"))
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("overlay setup: %v", err)
+	}
+
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetOverlayFS(ov)
+	pcfg := DefaultPivotRootConfig()
+	ns.SetPivotRoot(&pcfg)
+	ns.SetMaskPaths(&mcfg)
+
+	var buf bytes.Buffer
+	r, w, _ := os.Pipe()
+	ns.Stdout = w
+
+	if err := ns.Start("sh", "-c", script); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	result, err := ns.Wait()
+	w.Close()
+	buf.ReadFrom(r)
+	r.Close()
+
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code: %d, output: %s", result.ExitCode, buf.String())
+	}
+	return buf.String()
+}
+
+func TestMaskPathsKcoreMasked(t *testing.T) {
+	skipIfNotRoot(t)
+	if _, err := os.Stat("/proc/kcore"); err != nil {
+		t.Skip("skipping: /proc/kcore not available")
+	}
+
+	output := runMaskedSandbox(t, DefaultMaskPathsConfig(), "wc -c < /proc/kcore")
+	if strings.TrimSpace(output) != "0" {
+		t.Errorf("/proc/kcore should be masked (0 bytes), got %q", output)
+	}
+}
+
+func TestMaskPathsProcSysReadonly(t *testing.T) {
+	skipIfNotRoot(t)
+
+	script := "echo masked > /proc/sys/kernel/hostname 2>/dev/null && echo writable || echo readonly"
+	output := runMaskedSandbox(t, DefaultMaskPathsConfig(), script)
+	if !strings.Contains(output, "readonly") {
+		t.Errorf("/proc/sys should be read-only, got %q", output)
+	}
+}
+
+func TestMaskPathsMountSysfs(t *testing.T) {
+	skipIfNotRoot(t)
+
+	mcfg := DefaultMaskPathsConfig()
+	mcfg.MountSysfs = true
+	script := "ls /sys/class > /dev/null && " +
+		"(touch /sys/sandbox-test 2>/dev/null && echo writable || echo readonly) && " +
+		"ls /sys/firmware | wc -l"
+	output := runMaskedSandbox(t, mcfg, script)
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("unexpected output: %q", output)
+	}
+	if lines[0] != "readonly" {
+		t.Errorf("sysfs should be read-only, got %q", lines[0])
+	}
+	if strings.TrimSpace(lines[1]) != "0" {
+		t.Errorf("/sys/firmware should be masked (empty), got %q", lines[1])
+	}
+}
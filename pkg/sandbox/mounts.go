@@ -0,0 +1,417 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// BindMount 定义一个从宿主机 bind mount 到沙箱内的目录或文件（父进程侧）。
+//
+// 典型用途：为 Agent 提供一个可读写的项目目录和一个只读的数据集，
+// 其余文件系统仍由 OverlayFS + pivot_root 隔离。
+type BindMount struct {
+	Source      string // 宿主机上的源路径（必须存在）
+	Destination string // 沙箱内的目标路径（绝对路径，相对于新 root）
+	ReadOnly    bool   // 只读挂载
+	NoSuid      bool   // 忽略 setuid/setgid 位
+	NoDev       bool   // 禁止访问设备文件
+	NoExec      bool   // 禁止执行
+	Recursive   bool   // 递归 bind（MS_REC），同时挂载源路径下的子挂载点
+	Propagation string // 挂载传播类型：private/rprivate/slave/rslave/shared/rshared（默认 rprivate）
+}
+
+// bindMountInitConfig 通过管道传递给子进程的 bind mount 配置。
+type bindMountInitConfig struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	ReadOnly    bool   `json:"read_only,omitempty"`
+	NoSuid      bool   `json:"nosuid,omitempty"`
+	NoDev       bool   `json:"nodev,omitempty"`
+	NoExec      bool   `json:"noexec,omitempty"`
+	Recursive   bool   `json:"recursive,omitempty"`
+	Propagation string `json:"propagation,omitempty"`
+}
+
+// propagationFlags 定义挂载传播类型名称到 mount flags 的映射。
+var propagationFlags = map[string]uintptr{
+	"private":  syscall.MS_PRIVATE,
+	"rprivate": syscall.MS_PRIVATE | syscall.MS_REC,
+	"slave":    syscall.MS_SLAVE,
+	"rslave":   syscall.MS_SLAVE | syscall.MS_REC,
+	"shared":   syscall.MS_SHARED,
+	"rshared":  syscall.MS_SHARED | syscall.MS_REC,
+}
+
+// ParseBindMount 解析命令行格式的 bind mount 描述。
+//
+// 格式："src:dst[:opt1,opt2,...]"，支持的选项：
+//   - ro / rw：只读 / 读写（默认 rw）
+//   - nosuid / nodev / noexec：对应的挂载标志
+//   - rbind / bind：递归 / 非递归 bind（默认 rbind）
+//   - private / rprivate / slave / rslave / shared / rshared：挂载传播类型
+//
+// 例如："/data/project:/workspace"、"/data/ds:/data:ro,nosuid,nodev"
+func ParseBindMount(spec string) (BindMount, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return BindMount{}, fmt.Errorf("invalid mount spec %q: expected src:dst[:opts]", spec)
+	}
+
+	m := BindMount{
+		Source:      parts[0],
+		Destination: parts[1],
+		Recursive:   true,
+	}
+	if m.Source == "" || m.Destination == "" {
+		return BindMount{}, fmt.Errorf("invalid mount spec %q: empty source or destination", spec)
+	}
+
+	if len(parts) == 3 {
+		for _, opt := range strings.Split(parts[2], ",") {
+			switch opt {
+			case "ro":
+				m.ReadOnly = true
+			case "rw":
+				m.ReadOnly = false
+			case "nosuid":
+				m.NoSuid = true
+			case "nodev":
+				m.NoDev = true
+			case "noexec":
+				m.NoExec = true
+			case "rbind":
+				m.Recursive = true
+			case "bind":
+				m.Recursive = false
+			default:
+				if _, ok := propagationFlags[opt]; !ok {
+					return BindMount{}, fmt.Errorf("invalid mount spec %q: unknown option %q", spec, opt)
+				}
+				m.Propagation = opt
+			}
+		}
+	}
+
+	if err := m.validate(); err != nil {
+		return BindMount{}, err
+	}
+	return m, nil
+}
+
+// validate 检查 bind mount 配置的合法性。
+func (m *BindMount) validate() error {
+	if m.Source == "" {
+		return fmt.Errorf("bind mount: empty source")
+	}
+	if !filepath.IsAbs(m.Destination) {
+		return fmt.Errorf("bind mount: destination %q must be absolute", m.Destination)
+	}
+	if m.Propagation != "" {
+		if _, ok := propagationFlags[m.Propagation]; !ok {
+			return fmt.Errorf("bind mount: unknown propagation %q", m.Propagation)
+		}
+	}
+	return nil
+}
+
+// initConfig 将 BindMount 转换为传递给子进程的配置。
+// 源路径在父进程侧转换为绝对路径，避免子进程工作目录不同导致解析错误。
+func (m *BindMount) initConfig() (bindMountInitConfig, error) {
+	if err := m.validate(); err != nil {
+		return bindMountInitConfig{}, err
+	}
+	src, err := filepath.Abs(m.Source)
+	if err != nil {
+		return bindMountInitConfig{}, fmt.Errorf("bind mount: resolve source %q: %w", m.Source, err)
+	}
+	if _, err := os.Stat(src); err != nil {
+		return bindMountInitConfig{}, fmt.Errorf("bind mount: source %q: %w", src, err)
+	}
+	return bindMountInitConfig{
+		Source:      src,
+		Destination: filepath.Clean(m.Destination),
+		ReadOnly:    m.ReadOnly,
+		NoSuid:      m.NoSuid,
+		NoDev:       m.NoDev,
+		NoExec:      m.NoExec,
+		Recursive:   m.Recursive,
+		Propagation: m.Propagation,
+	}, nil
+}
+
+// securejoin 将 unsafePath 解析为 root 内的路径，逐级跟随符号链接，
+// 并保证结果（包括符号链接的目标）不会逃逸出 root。
+//
+// 与 filepath.Join 不同，沙箱内形如 /work -> /etc 或 ../../etc 的符号链接
+// 会被限制在 root 内解析，而不是指向宿主机的 /etc。
+// 不存在的路径分量按字面拼接。
+func securejoin(root, unsafePath string) (string, error) {
+	root = filepath.Clean(root)
+	rel, err := walkSymlinks(unsafePath, func(rel string) (os.FileInfo, string, error) {
+		return lstatLink(filepath.Join(root, rel))
+	})
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, rel), nil
+}
+
+// lstatLink 返回 path 的 Lstat 结果，若为符号链接则同时返回其目标。
+func lstatLink(path string) (os.FileInfo, string, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return fi, "", nil
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return fi, target, nil
+}
+
+// walkSymlinks 在以 "/" 为根的虚拟文件系统中解析 unsafePath，返回以 "/" 开头的结果路径。
+// lookup 根据相对虚拟根的路径返回文件信息与符号链接目标，由调用方决定实际查找的目录
+// （单个 root，或 OverlayFS 的多层目录）。".." 与绝对符号链接都不会越过虚拟根。
+func walkSymlinks(unsafePath string, lookup func(rel string) (os.FileInfo, string, error)) (string, error) {
+	const maxSymlinks = 255
+
+	resolved := "/"
+	remaining := filepath.Clean("/" + unsafePath)
+	links := 0
+
+	for remaining != "" && remaining != "/" {
+		remaining = strings.TrimPrefix(remaining, "/")
+		var part string
+		if i := strings.IndexByte(remaining, '/'); i >= 0 {
+			part, remaining = remaining[:i], remaining[i:]
+		} else {
+			part, remaining = remaining, ""
+		}
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		fi, target, err := lookup(next)
+		if err != nil {
+			if os.IsNotExist(err) {
+				resolved = next
+				continue
+			}
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("resolve %s: too many symlinks", unsafePath)
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = target + remaining
+	}
+
+	return resolved, nil
+}
+
+// mountBindMounts 在子进程中将配置的宿主机路径 bind mount 到 rootDir 下。
+// 此函数在新 Mount Namespace 内运行，由 nsInit() 在 OverlayFS 之后、pivot_root 之前调用。
+//
+// 每个挂载的步骤：
+//  1. 在 rootDir 内安全解析目标路径（防止符号链接逃逸）
+//  2. 创建挂载点（目录或空文件，与源类型一致）
+//  3. bind mount（可选 MS_REC）
+//  4. 以 MS_REMOUNT 应用 ro/nosuid/nodev/noexec（bind mount 首次挂载时会忽略这些标志）
+//  5. 设置挂载传播类型
+func mountBindMounts(rootDir string, mounts []bindMountInitConfig) error {
+	if rootDir == "" {
+		rootDir = "/"
+	}
+
+	for _, m := range mounts {
+		target, err := securejoin(rootDir, m.Destination)
+		if err != nil {
+			return fmt.Errorf("resolve %s: %w", m.Destination, err)
+		}
+
+		fi, err := os.Stat(m.Source)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", m.Source, err)
+		}
+		if fi.IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("mkdir %s: %w", target, err)
+			}
+		} else {
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("mkdir %s: %w", filepath.Dir(target), err)
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return fmt.Errorf("create %s: %w", target, err)
+			}
+			f.Close()
+		}
+
+		flags := uintptr(syscall.MS_BIND)
+		if m.Recursive {
+			flags |= syscall.MS_REC
+		}
+		if err := syscall.Mount(m.Source, target, "", flags, ""); err != nil {
+			return fmt.Errorf("bind mount %s -> %s: %w", m.Source, target, err)
+		}
+
+		if err := restrictBindMount(target, m); err != nil {
+			return err
+		}
+
+		propagation := m.Propagation
+		if propagation == "" {
+			propagation = "rprivate"
+		}
+		if err := syscall.Mount("", target, "", propagationFlags[propagation], ""); err != nil {
+			return fmt.Errorf("set propagation %s on %s: %w", propagation, target, err)
+		}
+	}
+	return nil
+}
+
+// restrictBindMount 将只读、nosuid、nodev、noexec 应用到 target 及其下的所有子挂载点。
+//
+// 递归 bind 带入的子挂载点各自保留原来的标志，只 remount 顶层挂载点会让 :ro 源下的
+// 子挂载点仍然可写。优先使用 mount_setattr(AT_RECURSIVE)（内核 5.12+），
+// 旧内核上逐个 remount /proc/self/mountinfo 中位于 target 下的挂载点。
+func restrictBindMount(target string, m bindMountInitConfig) error {
+	var attr uint64
+	var flags uintptr
+	for _, f := range []struct {
+		set   bool
+		attr  uint64
+		flags uintptr
+	}{
+		{m.ReadOnly, unix.MOUNT_ATTR_RDONLY, syscall.MS_RDONLY},
+		{m.NoSuid, unix.MOUNT_ATTR_NOSUID, syscall.MS_NOSUID},
+		{m.NoDev, unix.MOUNT_ATTR_NODEV, syscall.MS_NODEV},
+		{m.NoExec, unix.MOUNT_ATTR_NOEXEC, syscall.MS_NOEXEC},
+	} {
+		if f.set {
+			attr |= f.attr
+			flags |= f.flags
+		}
+	}
+	if attr == 0 {
+		return nil
+	}
+
+	err := unix.MountSetattr(unix.AT_FDCWD, target, unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: attr})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, unix.ENOSYS) {
+		return fmt.Errorf("mount_setattr %s: %w", target, err)
+	}
+
+	mounts, err := submounts(target)
+	if err != nil {
+		return fmt.Errorf("remount %s: %w", target, err)
+	}
+	for _, sm := range mounts {
+		// bind remount 会替换挂载点的标志，需要保留子挂载点原有的限制
+		remount := syscall.MS_BIND | syscall.MS_REMOUNT | flags | sm.flags
+		if err := syscall.Mount("", sm.path, "", remount, ""); err != nil {
+			return fmt.Errorf("remount %s: %w", sm.path, err)
+		}
+	}
+	return nil
+}
+
+// mountPoint 是 mountinfo 中的一个挂载点及其每挂载点标志。
+type mountPoint struct {
+	path  string
+	flags uintptr
+}
+
+// submounts 返回 /proc/self/mountinfo 中 target 本身及其下的挂载点，按挂载顺序排列。
+func submounts(target string) ([]mountPoint, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []mountPoint
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式：id parent major:minor root mountpoint options ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		path := unescapeMountPath(fields[4])
+		if path != target && !strings.HasPrefix(path, strings.TrimSuffix(target, "/")+"/") {
+			continue
+		}
+		mp := mountPoint{path: path}
+		for _, opt := range strings.Split(fields[5], ",") {
+			switch opt {
+			case "ro":
+				mp.flags |= syscall.MS_RDONLY
+			case "nosuid":
+				mp.flags |= syscall.MS_NOSUID
+			case "nodev":
+				mp.flags |= syscall.MS_NODEV
+			case "noexec":
+				mp.flags |= syscall.MS_NOEXEC
+			case "noatime":
+				mp.flags |= syscall.MS_NOATIME
+			case "nodiratime":
+				mp.flags |= syscall.MS_NODIRATIME
+			case "relatime":
+				mp.flags |= syscall.MS_RELATIME
+			}
+		}
+		mounts = append(mounts, mp)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath 还原 mountinfo 中以八进制转义的空白和反斜杠（例如 "\040"）。
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
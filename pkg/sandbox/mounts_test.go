@@ -0,0 +1,280 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// ===================================================================
+// BindMount 单元测试（不需要 root）
+// ===================================================================
+
+func TestParseBindMount(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    BindMount
+		wantErr bool
+	}{
+		{
+			spec: "/src:/dst",
+			want: BindMount{Source: "/src", Destination: "/dst", Recursive: true},
+		},
+		{
+			spec: "/src:/dst:ro",
+			want: BindMount{Source: "/src", Destination: "/dst", ReadOnly: true, Recursive: true},
+		},
+		{
+			spec: "/src:/dst:ro,nosuid,nodev,noexec,bind,rslave",
+			want: BindMount{
+				Source: "/src", Destination: "/dst",
+				ReadOnly: true, NoSuid: true, NoDev: true, NoExec: true,
+				Recursive: false, Propagation: "rslave",
+			},
+		},
+		{spec: "/src", wantErr: true},
+		{spec: "/src:relative", wantErr: true},
+		{spec: ":/dst", wantErr: true},
+		{spec: "/src:/dst:bogus", wantErr: true},
+		{spec: "/a:/b:ro:extra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseBindMount(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error for %q, got %+v", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSecurejoin(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "a", "b"), 0755)
+	os.Symlink("/etc", filepath.Join(root, "abs"))
+	os.Symlink("../../..", filepath.Join(root, "a", "b", "up"))
+	os.Symlink("b", filepath.Join(root, "a", "rel"))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/a/b", "/a/b"},
+		{"/a/../../../etc", "/etc"},
+		{"/abs/passwd", "/etc/passwd"},
+		{"/a/b/up/etc", "/etc"},
+		{"/a/rel/c", "/a/b/c"},
+		{"/missing/x", "/missing/x"},
+	}
+
+	for _, tt := range tests {
+		got, err := securejoin(root, tt.path)
+		if err != nil {
+			t.Fatalf("securejoin(%q): %v", tt.path, err)
+		}
+		if want := filepath.Join(root, tt.want); got != want {
+			t.Errorf("securejoin(%q) = %q, want %q", tt.path, got, want)
+		}
+	}
+}
+
+func TestSecurejoinSymlinkLoop(t *testing.T) {
+	root := t.TempDir()
+	os.Symlink("loop", filepath.Join(root, "loop"))
+	if _, err := securejoin(root, "/loop/x"); err == nil {
+		t.Error("expected error for symlink loop")
+	}
+}
+
+func TestBindMountRequiresPivotRoot(t *testing.T) {
+	for _, pcfg := range []*PivotRootConfig{
+		nil,
+		{Enabled: false, RootDir: "/tmp"},
+		{Enabled: true}, // 没有 overlay 也没有 RootDir，不会 pivot_root
+	} {
+		ns := NewNamespace(NamespaceConfig{Mount: true})
+		if pcfg != nil {
+			ns.SetPivotRoot(pcfg)
+		}
+		ns.SetBindMounts([]BindMount{{Source: "/tmp", Destination: "/data"}})
+		err := ns.Start("true")
+		if err == nil {
+			ns.Cleanup()
+			t.Fatalf("expected error for bind mounts without pivot_root (%+v)", pcfg)
+		}
+		if !strings.Contains(err.Error(), "require pivot_root") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	for in, want := range map[string]string{
+		"/mnt/data":        "/mnt/data",
+		`/mnt/my\040data`:  "/mnt/my data",
+		`/mnt/a\011b\134c`: "/mnt/a\tb\\c",
+		`/mnt/trailing\04`: `/mnt/trailing\04`,
+	} {
+		if got := unescapeMountPath(in); got != want {
+			t.Errorf("unescapeMountPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// ===================================================================
+// BindMount 集成测试（需要 root）
+// ===================================================================
+
+func TestBindMountReadWriteAndReadOnly(t *testing.T) {
+	skipIfNotRoot(t)
+
+	rwDir := t.TempDir()
+	roDir := t.TempDir()
+	os.WriteFile(filepath.Join(roDir, "data.txt"), []byte("dataset"), 0644)
+
+	ov := NewOverlayFS(DefaultOverlayConfig("/"))
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("overlay setup: %v", err)
+	}
+
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetOverlayFS(ov)
+	pcfg := DefaultPivotRootConfig()
+	ns.SetPivotRoot(&pcfg)
+	ns.SetBindMounts([]BindMount{
+		{Source: rwDir, Destination: "/workspace", Recursive: true},
+		{Source: roDir, Destination: "/data", ReadOnly: true, Recursive: true},
+	})
+
+	var buf bytes.Buffer
+	r, w, _ := os.Pipe()
+	ns.Stdout = w
+	ns.Stderr = w
+
+	script := "cat /data/data.txt && echo && echo out > /workspace/out.txt && " +
+		"(echo x > /data/new.txt 2>/dev/null && echo ro-writable || echo ro-ok)"
+	if err := ns.Start("sh", "-c", script); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	result, err := ns.Wait()
+	w.Close()
+	buf.ReadFrom(r)
+	r.Close()
+
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code: %d, output: %s", result.ExitCode, buf.String())
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "dataset") {
+		t.Errorf("read-only mount content not visible, output: %q", output)
+	}
+	if !strings.Contains(output, "ro-ok") {
+		t.Errorf("read-only mount should reject writes, output: %q", output)
+	}
+
+	// 读写挂载的写入应直接落到宿主机目录
+	data, err := os.ReadFile(filepath.Join(rwDir, "out.txt"))
+	if err != nil {
+		t.Fatalf("read-write mount output not on host: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "out" {
+		t.Errorf("expected 'out', got %q", string(data))
+	}
+}
+
+func TestBindMountReadOnlySubmount(t *testing.T) {
+	skipIfNotRoot(t)
+
+	// 只读源目录下有一个可写的子挂载点，递归 bind 后也必须只读
+	roDir := t.TempDir()
+	sub := filepath.Join(roDir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mount("tmpfs", sub, "tmpfs", 0, "size=1m"); err != nil {
+		t.Fatalf("mount tmpfs: %v", err)
+	}
+	defer syscall.Unmount(sub, syscall.MNT_DETACH)
+
+	ov := NewOverlayFS(DefaultOverlayConfig("/"))
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("overlay setup: %v", err)
+	}
+
+	dst := "/mnt/ro-test"
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetOverlayFS(ov)
+	pcfg := DefaultPivotRootConfig()
+	ns.SetPivotRoot(&pcfg)
+	ns.SetBindMounts([]BindMount{
+		{Source: roDir, Destination: dst, ReadOnly: true, NoSuid: true, Recursive: true},
+	})
+
+	var buf bytes.Buffer
+	r, w, _ := os.Pipe()
+	ns.Stdout = w
+	ns.Stderr = w
+
+	script := "(echo x > " + dst + "/sub/new.txt 2>/dev/null && echo sub-writable || echo sub-ok) && " +
+		"grep ' " + dst + "/sub ' /proc/self/mountinfo"
+	if err := ns.Start("sh", "-c", script); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	result, err := ns.Wait()
+	w.Close()
+	buf.ReadFrom(r)
+	r.Close()
+
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code: %d, output: %s", result.ExitCode, buf.String())
+	}
+	output := buf.String()
+	if !strings.Contains(output, "sub-ok") {
+		t.Errorf("submount of a read-only bind should reject writes, output: %q", output)
+	}
+	if !strings.Contains(output, "nosuid") {
+		t.Errorf("submount should be nosuid, output: %q", output)
+	}
+	if _, err := os.Stat(filepath.Join(sub, "new.txt")); err == nil {
+		t.Error("write through the read-only bind reached the host submount")
+	}
+}
+
+func TestBindMountMissingSource(t *testing.T) {
+	skipIfNotRoot(t)
+
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetPivotRoot(&PivotRootConfig{Enabled: true, RootDir: t.TempDir()})
+	ns.SetBindMounts([]BindMount{
+		{Source: "/nonexistent-bind-source", Destination: "/x"},
+	})
+
+	if err := ns.Start("true"); err == nil {
+		t.Error("expected error for missing bind mount source")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"sync"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -68,23 +69,36 @@ func MinimalNamespaceConfig() NamespaceConfig {
 // initConfig 通过管道传递给子进程的初始化配置。
 // 子进程（reexec init）从管道读取此配置后执行初始化，然后exec用户命令。
 type initConfig struct {
-	Hostname      string             `json:"hostname,omitempty"`
-	MountProc     bool               `json:"mount_proc,omitempty"`
-	SetupLoopback bool               `json:"setup_loopback,omitempty"`
-	Overlay       *overlayInitConfig `json:"overlay,omitempty"`
-	PivotRoot     *pivotRootConfig   `json:"pivot_root,omitempty"`
-	Seccomp       *seccompInitConfig `json:"seccomp,omitempty"`
-	Command       string             `json:"command"`
-	Args          []string           `json:"args,omitempty"`
-	Env           []string           `json:"env,omitempty"`
-	WorkDir       string             `json:"work_dir,omitempty"`
+	Hostname      string                `json:"hostname,omitempty"`
+	MountProc     bool                  `json:"mount_proc,omitempty"`
+	SetupLoopback bool                  `json:"setup_loopback,omitempty"`
+	Overlay       *overlayInitConfig    `json:"overlay,omitempty"`
+	Mounts        []bindMountInitConfig `json:"mounts,omitempty"`
+	PivotRoot     *pivotRootConfig      `json:"pivot_root,omitempty"`
+	Dev           *devInitConfig        `json:"dev,omitempty"`
+	MaskPaths     *maskPathsInitConfig  `json:"mask_paths,omitempty"`
+	EtcFiles      *etcFilesInitConfig   `json:"etc_files,omitempty"`
+	Seccomp       *seccompInitConfig    `json:"seccomp,omitempty"`
+	Command       string                `json:"command"`
+	Args          []string              `json:"args,omitempty"`
+	Env           []string              `json:"env,omitempty"`
+	WorkDir       string                `json:"work_dir,omitempty"`
+	User          string                `json:"user,omitempty"`
 }
 
 // ExecResult 记录隔离进程的执行结果。
 type ExecResult struct {
-	ExitCode int
+	ExitCode     int
+	Artifacts    *ArtifactResult   // 收集到的输出文件（仅在 SetArtifacts 后填充）
+	OverlayUsage *OverlayUsage     // OverlayFS 上层的峰值用量（仅在绑定 OverlayFS 时填充）
+	CgroupEvents []CgroupEvent     // 运行期间的 OOM、pids.max 等 cgroup 事件（仅在绑定 CgroupsV2 时填充）
+	Usage        *UsageRecord      // 资源记账记录（仅在 SetAccounting 后填充）
+	Termination  TerminationReason // 进程结束的原因（自行退出或 CPU 时间预算耗尽）
 }
 
+// overlayUsageInterval 是运行期间采样 OverlayFS 用量的间隔。
+const overlayUsageInterval = 100 * time.Millisecond
+
 // Namespace 管理单个沙箱的Namespace生命周期。
 //
 // 使用方式:
@@ -93,17 +107,27 @@ type ExecResult struct {
 //	defer ns.Cleanup()
 //	result, err := ns.Execute("python", "agent.py")
 type Namespace struct {
-	config          NamespaceConfig
-	overlayFS       *OverlayFS
-	cgroupsV2       *CgroupsV2
-	seccompConfig   *SeccompConfig
-	pivotRootConfig *PivotRootConfig
-	logger          *zap.Logger
-	cmd             *exec.Cmd
-	pid             int
-	running         bool
-	done            chan struct{}
-	mu              sync.Mutex
+	config           NamespaceConfig
+	overlayFS        *OverlayFS
+	cgroups          Cgroups
+	seccompConfig    *SeccompConfig
+	pivotRootConfig  *PivotRootConfig
+	devConfig        *DevConfig
+	bindMounts       []BindMount
+	maskPathsConfig  *MaskPathsConfig
+	etcFilesConfig   *EtcFilesConfig
+	artifactConfig   *ArtifactConfig
+	accountingConfig *AccountingConfig
+	meter            *usageMeter
+	cpuBudgetConfig  *CPUBudgetConfig
+	budgetExhausted  chan struct{}
+	budgetReason     TerminationReason // watchCPUBudget 在关闭 budgetExhausted 之前写入
+	logger           *zap.Logger
+	cmd              *exec.Cmd
+	pid              int
+	running          bool
+	done             chan struct{}
+	mu               sync.Mutex
 
 	// 外部可配置的IO（默认继承父进程）
 	Stdin  *os.File
@@ -111,6 +135,7 @@ type Namespace struct {
 	Stderr *os.File
 	Env    []string // 传递给Agent的环境变量（空则继承父进程）
 	Dir    string   // Agent的工作目录
+	User   string   // 运行Agent的用户："user[:group]"，名称在沙箱内的/etc/passwd、/etc/group中解析（空则为root）
 
 	cleanups []func() error
 }
@@ -134,13 +159,38 @@ func (ns *Namespace) SetOverlayFS(ov *OverlayFS) {
 	ns.overlayFS = ov
 }
 
-// SetCgroupsV2 绑定CgroupsV2到此Namespace。
+// SetCgroups 绑定 cgroup 后端（CgroupsV2 或 CgroupsV1，见 NewCgroups）到此Namespace。
 // 必须在Start()之前调用。子进程fork后会被自动加入cgroup，
 // 清理函数也会自动注册到Namespace的清理钩子中。
-func (ns *Namespace) SetCgroupsV2(cg *CgroupsV2) {
+func (ns *Namespace) SetCgroups(cg Cgroups) {
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
-	ns.cgroupsV2 = cg
+	ns.cgroups = cg
+}
+
+// SetCgroupsV2 绑定CgroupsV2到此Namespace，等同于 SetCgroups(cg)。
+// cg 为 nil 时解除绑定（不能把 nil 指针存为非 nil 的 Cgroups 接口）。
+func (ns *Namespace) SetCgroupsV2(cg *CgroupsV2) {
+	if cg == nil {
+		ns.SetCgroups(nil)
+		return
+	}
+	ns.SetCgroups(cg)
+}
+
+// cgroupsV2 返回绑定的 CgroupsV2，未绑定或使用 v1 后端时返回 nil。调用方持有 ns.mu。
+func (ns *Namespace) cgroupsV2() *CgroupsV2 {
+	cg, _ := ns.cgroups.(*CgroupsV2)
+	return cg
+}
+
+// pivotsRoot 判断子进程是否会 pivot_root 进入新 root（overlay 的 merged 目录或 PivotRoot.RootDir）。
+// bind mount 和生成的 /etc 文件挂载在新 root 内，不 pivot_root 时进程看不到它们。调用方持有 ns.mu。
+func (ns *Namespace) pivotsRoot() bool {
+	if ns.pivotRootConfig == nil || !ns.pivotRootConfig.Enabled {
+		return false
+	}
+	return ns.overlayFS != nil || ns.pivotRootConfig.RootDir != ""
 }
 
 // SetLogger 设置此Namespace的日志记录器。
@@ -167,6 +217,51 @@ func (ns *Namespace) SetPivotRoot(cfg *PivotRootConfig) {
 	ns.pivotRootConfig = cfg
 }
 
+// SetDev 设置沙箱内 /dev 的构建配置。
+// 必须在Start()之前调用，仅在启用 pivot_root 时生效；未调用时使用 DefaultDevConfig()。
+func (ns *Namespace) SetDev(cfg *DevConfig) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.devConfig = cfg
+}
+
+// SetBindMounts 设置从宿主机 bind mount 到沙箱内的路径列表。
+// 必须在Start()之前调用。子进程将在 OverlayFS 挂载后、pivot_root 之前执行挂载，
+// 目标路径相对于沙箱的根目录（overlay merged 目录或 PivotRoot.RootDir），
+// 因此需要启用 pivot_root，否则 Start() 返回错误。
+func (ns *Namespace) SetBindMounts(mounts []BindMount) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.bindMounts = mounts
+}
+
+// SetMaskPaths 设置 /proc 与 /sys 加固配置。
+// 必须在Start()之前调用。子进程将在挂载 /proc 后屏蔽敏感路径并将内核接口只读化。
+func (ns *Namespace) SetMaskPaths(cfg *MaskPathsConfig) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.maskPathsConfig = cfg
+}
+
+// SetEtcFiles 设置沙箱内 /etc/hosts、/etc/resolv.conf、/etc/passwd、/etc/group 的生成配置。
+// 必须在Start()之前调用。子进程将在 bind mount 之后、pivot_root 之前生成这些文件，
+// 生成文件所在的临时目录会自动注册到Namespace的清理钩子中。
+// 生成的文件挂载在新 root 内，因此需要启用 pivot_root，否则 Start() 返回错误。
+func (ns *Namespace) SetEtcFiles(cfg *EtcFilesConfig) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.etcFilesConfig = cfg
+}
+
+// SetArtifacts 设置进程退出后需要收集的输出文件。
+// 必须在Start()之前调用，且需要绑定 OverlayFS。Wait() 在进程退出后、Cleanup() 之前
+// 按配置从沙箱文件系统中复制匹配的文件，结果记录在 ExecResult.Artifacts 中。
+func (ns *Namespace) SetArtifacts(cfg *ArtifactConfig) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.artifactConfig = cfg
+}
+
 // cloneFlags 根据配置组合syscall clone flags。
 func (ns *Namespace) cloneFlags() uintptr {
 	var flags uintptr
@@ -211,6 +306,20 @@ func (ns *Namespace) Start(command string, args ...string) error {
 	if ns.running {
 		return fmt.Errorf("namespace: process already running (pid=%d)", ns.pid)
 	}
+	if cfg := ns.cpuBudgetConfig; cfg != nil && cfg.Enabled {
+		if err := cfg.validate(); err != nil {
+			return fmt.Errorf("namespace: %w", err)
+		}
+		if ns.cgroupsV2() == nil {
+			return fmt.Errorf("namespace: cpu budget requires cgroups v2")
+		}
+	}
+	if len(ns.bindMounts) > 0 && !ns.pivotsRoot() {
+		return fmt.Errorf("namespace: bind mounts require pivot_root into an overlay or PivotRoot.RootDir")
+	}
+	if cfg := ns.etcFilesConfig; cfg != nil && cfg.Enabled && !ns.pivotsRoot() {
+		return fmt.Errorf("namespace: etc files require pivot_root into an overlay or PivotRoot.RootDir")
+	}
 
 	// 创建管道：父进程写入配置，子进程读取
 	pipeR, pipeW, err := os.Pipe()
@@ -271,15 +380,24 @@ func (ns *Namespace) Start(command string, args ...string) error {
 		go readLogPipe(logPipeR, ns.logger)
 	}
 
+	// abort 在发送配置前失败时杀死子进程并释放 Start 中分配的资源
+	var etcDir string
+	abort := func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		pipeW.Close()
+		if logPipeR != nil {
+			logPipeR.Close()
+		}
+		if etcDir != "" {
+			os.RemoveAll(etcDir)
+		}
+	}
+
 	// 添加子进程到 cgroup（必须在发送配置前，此时子进程阻塞在管道读取）
-	if ns.cgroupsV2 != nil {
-		if err := ns.cgroupsV2.AddProcess(cmd.Process.Pid); err != nil {
-			cmd.Process.Kill()
-			cmd.Wait()
-			pipeW.Close()
-			if logPipeR != nil {
-				logPipeR.Close()
-			}
+	if ns.cgroups != nil {
+		if err := ns.cgroups.AddProcess(cmd.Process.Pid); err != nil {
+			abort()
 			return fmt.Errorf("namespace: add process to cgroup: %w", err)
 		}
 	}
@@ -293,6 +411,7 @@ func (ns *Namespace) Start(command string, args ...string) error {
 		Args:          args,
 		Env:           ns.Env,
 		WorkDir:       ns.Dir,
+		User:          ns.User,
 	}
 
 	// 注入OverlayFS配置（如果已绑定）
@@ -300,11 +419,50 @@ func (ns *Namespace) Start(command string, args ...string) error {
 		cfg.Overlay = ns.overlayFS.InitConfig()
 	}
 
+	// 注入 bind mount 配置（父进程负责校验并解析源路径）
+	for i := range ns.bindMounts {
+		mcfg, err := ns.bindMounts[i].initConfig()
+		if err != nil {
+			abort()
+			return fmt.Errorf("namespace: %w", err)
+		}
+		cfg.Mounts = append(cfg.Mounts, mcfg)
+	}
+
+	// 注入 /etc 文件生成配置（生成文件存放在每个沙箱独立的临时目录中）
+	if ns.etcFilesConfig != nil && ns.etcFilesConfig.Enabled {
+		etcDir, err = os.MkdirTemp("", "ai-sandbox-etc-")
+		if err == nil {
+			cfg.EtcFiles, err = ns.etcFilesConfig.initConfig(etcDir)
+		}
+		if err != nil {
+			abort()
+			return fmt.Errorf("namespace: %w", err)
+		}
+	}
+
 	// 注入 PivotRoot 配置
 	if ns.pivotRootConfig != nil && ns.pivotRootConfig.Enabled {
 		cfg.PivotRoot = &pivotRootConfig{
 			RootDir: ns.pivotRootConfig.RootDir,
 		}
+
+		devConfig := DefaultDevConfig()
+		if ns.devConfig != nil {
+			devConfig = *ns.devConfig
+		}
+		if devConfig.Enabled {
+			cfg.Dev, err = devConfig.initConfig(ns.config.IPC)
+			if err != nil {
+				abort()
+				return fmt.Errorf("namespace: %w", err)
+			}
+		}
+	}
+
+	// 注入 /proc 与 /sys 加固配置
+	if ns.maskPathsConfig != nil && ns.maskPathsConfig.Enabled {
+		cfg.MaskPaths = ns.maskPathsConfig.initConfig()
 	}
 
 	// 注入 Seccomp 配置（父进程负责解析 syscall 名称为号码）
@@ -315,9 +473,7 @@ func (ns *Namespace) Start(command string, args ...string) error {
 		}
 		nrs, err := resolveBlocklist(blocklist)
 		if err != nil {
-			cmd.Process.Kill()
-			cmd.Wait()
-			pipeW.Close()
+			abort()
 			return fmt.Errorf("namespace: resolve seccomp blocklist: %w", err)
 		}
 		families := ns.seccompConfig.BlockedSocketFamilies
@@ -332,9 +488,7 @@ func (ns *Namespace) Start(command string, args ...string) error {
 	}
 
 	if err := json.NewEncoder(pipeW).Encode(&cfg); err != nil {
-		cmd.Process.Kill()
-		cmd.Wait()
-		pipeW.Close()
+		abort()
 		return fmt.Errorf("namespace: send init config: %w", err)
 	}
 	pipeW.Close()
@@ -353,14 +507,45 @@ func (ns *Namespace) Start(command string, args ...string) error {
 		)
 	}
 
-	// 自动注册OverlayFS清理钩子
+	// 自动注册OverlayFS清理钩子，并在运行期间采样上层用量
 	if ns.overlayFS != nil {
 		ns.cleanups = append(ns.cleanups, ns.overlayFS.Cleanup)
+		go monitorOverlayUsage(ns.overlayFS, ns.done)
+	}
+
+	// 运行期间采样内存和网络用量用于记账
+	if cfg := ns.accountingConfig; cfg != nil && cfg.Enabled {
+		var cgroupDir string
+		var v1Dirs map[string]string
+		switch cg := ns.cgroups.(type) {
+		case *CgroupsV2:
+			cgroupDir = cg.CgroupDir()
+		case *CgroupsV1:
+			v1Dirs = cg.CgroupDirs()
+		}
+		interval := cfg.Interval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		ns.meter = newUsageMeter(cgroupDir, v1Dirs, ns.pid, ns.config.Network)
+		go ns.meter.run(interval, ns.done)
 	}
 
-	// 自动注册CgroupsV2清理钩子
-	if ns.cgroupsV2 != nil {
-		ns.cleanups = append(ns.cleanups, ns.cgroupsV2.Cleanup)
+	// 累计 CPU 时间达到预算时杀死或冻结沙箱
+	if cfg := ns.cpuBudgetConfig; cfg != nil && cfg.Enabled {
+		ns.budgetExhausted = make(chan struct{})
+		ns.budgetReason = ""
+		go watchCPUBudget(ns.cgroupsV2(), *cfg, ns.done, ns.budgetExhausted, &ns.budgetReason, ns.logger)
+	}
+
+	// 自动注册 /etc 文件临时目录的清理钩子
+	if etcDir != "" {
+		ns.cleanups = append(ns.cleanups, func() error { return os.RemoveAll(etcDir) })
+	}
+
+	// 自动注册 cgroup 清理钩子
+	if ns.cgroups != nil {
+		ns.cleanups = append(ns.cleanups, ns.cgroups.Cleanup)
 	}
 
 	return nil
@@ -385,7 +570,7 @@ func (ns *Namespace) Wait() (*ExecResult, error) {
 	}
 	ns.mu.Unlock()
 
-	result := &ExecResult{}
+	result := &ExecResult{Termination: TerminationExit}
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			result.ExitCode = exitErr.ExitCode()
@@ -393,9 +578,94 @@ func (ns *Namespace) Wait() (*ExecResult, error) {
 			return nil, fmt.Errorf("namespace: wait: %w", err)
 		}
 	}
+
+	// 记录 overlay 峰值用量（进程退出后补采一次，覆盖最后一个采样间隔）
+	ns.recordOverlayUsage(result)
+
+	ns.mu.Lock()
+	cg, exhausted := ns.cgroupsV2(), ns.budgetExhausted
+	ns.mu.Unlock()
+	if exhausted != nil {
+		select {
+		case <-exhausted:
+			result.Termination = ns.budgetReason
+		default:
+		}
+	}
+	if cg != nil {
+		result.CgroupEvents = cg.Events()
+	}
+	ns.recordUsage(result, cmd.ProcessState)
+
+	// 收集输出文件（必须在 Cleanup 销毁 overlay tmpfs 之前）
+	if err := ns.collectArtifacts(result); err != nil {
+		return result, err
+	}
 	return result, nil
 }
 
+// monitorOverlayUsage 周期性采样 OverlayFS 用量以记录峰值，直到 done 被关闭。
+func monitorOverlayUsage(ov *OverlayFS, done <-chan struct{}) {
+	ticker := time.NewTicker(overlayUsageInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			// 采样失败（例如已被清理）不影响沙箱运行
+			_, _ = ov.Usage()
+		}
+	}
+}
+
+// recordOverlayUsage 将 OverlayFS 峰值用量写入 result，空间或 inode 耗尽时记录警告。
+func (ns *Namespace) recordOverlayUsage(result *ExecResult) {
+	ns.mu.Lock()
+	ov, logger := ns.overlayFS, ns.logger
+	ns.mu.Unlock()
+
+	if ov == nil {
+		return
+	}
+	if _, err := ov.Usage(); err != nil {
+		return
+	}
+	peak := ov.PeakUsage()
+	result.OverlayUsage = &peak
+
+	if logger != nil && peak.Exhausted() {
+		logger.Warn("overlay exhausted",
+			zap.String("overlay_id", ov.ID()),
+			zap.Uint64("bytes_used", peak.BytesUsed),
+			zap.Uint64("bytes_total", peak.BytesTotal),
+			zap.Uint64("inodes_used", peak.InodesUsed),
+			zap.Uint64("inodes_total", peak.InodesTotal),
+		)
+	}
+}
+
+// collectArtifacts 按 SetArtifacts 的配置收集输出文件并写入 result。
+func (ns *Namespace) collectArtifacts(result *ExecResult) error {
+	ns.mu.Lock()
+	cfg, ov := ns.artifactConfig, ns.overlayFS
+	ns.mu.Unlock()
+
+	if cfg == nil {
+		return nil
+	}
+	if ov == nil {
+		return fmt.Errorf("namespace: collect artifacts: no OverlayFS bound")
+	}
+
+	artifacts, err := ov.CollectArtifacts(*cfg)
+	result.Artifacts = artifacts
+	if err != nil {
+		return fmt.Errorf("namespace: collect artifacts: %w", err)
+	}
+	return nil
+}
+
 // Signal 向隔离进程发送信号。
 func (ns *Namespace) Signal(sig syscall.Signal) error {
 	ns.mu.Lock()
@@ -458,6 +728,73 @@ func (ns *Namespace) Running() bool {
 	return ns.running
 }
 
+// State 是沙箱的运行状态。
+type State string
+
+const (
+	StateCreated State = "created" // 尚未启动
+	StateRunning State = "running"
+	StatePaused  State = "paused" // cgroup 已冻结（Pause）
+	StateStopped State = "stopped"
+)
+
+// State 返回沙箱当前的运行状态。
+func (ns *Namespace) State() State {
+	ns.mu.Lock()
+	started, running, cg := ns.pid != 0, ns.running, ns.cgroupsV2()
+	ns.mu.Unlock()
+
+	switch {
+	case !started:
+		return StateCreated
+	case !running:
+		return StateStopped
+	case cg != nil:
+		if frozen, err := cg.Frozen(); err == nil && frozen {
+			return StatePaused
+		}
+	}
+	return StateRunning
+}
+
+// Pause 冻结沙箱内的所有进程（需要 CgroupsV2）。
+// 冻结期间进程不被调度，可以安全地检查其文件系统和内存状态。
+func (ns *Namespace) Pause() error {
+	return ns.setPaused(true)
+}
+
+// Resume 恢复由 Pause 冻结的沙箱。
+func (ns *Namespace) Resume() error {
+	return ns.setPaused(false)
+}
+
+func (ns *Namespace) setPaused(paused bool) error {
+	ns.mu.Lock()
+	running, cg, logger := ns.running, ns.cgroupsV2(), ns.logger
+	ns.mu.Unlock()
+
+	if !running {
+		return fmt.Errorf("namespace: no running process")
+	}
+	if cg == nil {
+		return fmt.Errorf("namespace: pause requires cgroups v2")
+	}
+
+	var err error
+	if paused {
+		err = cg.Freeze()
+	} else {
+		err = cg.Thaw()
+	}
+	if err != nil {
+		return fmt.Errorf("namespace: %w", err)
+	}
+	if logger != nil {
+		logger.Info("namespace state changed", zap.Int("pid", ns.pid), zap.String("state", string(ns.State())))
+	}
+	return nil
+}
+
 // Done 返回一个channel，在进程退出时被关闭。
 // 可用于 select 等待。
 func (ns *Namespace) Done() <-chan struct{} {
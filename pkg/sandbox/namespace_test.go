@@ -7,6 +7,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"go.uber.org/zap"
 )
 
 // skipIfNotRoot 在非root环境中跳过测试。
@@ -295,6 +297,23 @@ func TestCleanupHooks(t *testing.T) {
 	}
 }
 
+func TestSetCgroupsV2Nil(t *testing.T) {
+	ns := NewNamespace(MinimalNamespaceConfig())
+	ns.SetCgroupsV2(nil)
+	if ns.cgroups != nil {
+		t.Fatal("SetCgroupsV2(nil) should leave no cgroups bound")
+	}
+
+	skipIfNotRoot(t)
+	defer ns.Cleanup()
+	if _, err := ns.Execute("true"); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if err := ns.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+}
+
 func TestDoubleStart(t *testing.T) {
 	skipIfNotRoot(t)
 
@@ -313,6 +332,35 @@ func TestDoubleStart(t *testing.T) {
 	}
 }
 
+func TestStartFailureClosesPipes(t *testing.T) {
+	skipIfNotRoot(t)
+
+	countFds := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetLogger(zap.NewNop())
+	ns.SetPivotRoot(&PivotRootConfig{Enabled: true, RootDir: t.TempDir()})
+	ns.SetBindMounts([]BindMount{{Source: "/nonexistent-bind-source", Destination: "/x"}})
+
+	before := countFds()
+	for i := 0; i < 5; i++ {
+		if err := ns.Start("true"); err == nil {
+			t.Fatal("expected error for missing bind mount source")
+		}
+	}
+	// 失败路径必须关闭配置管道和日志管道
+	if after := countFds(); after > before {
+		t.Errorf("fd count grew from %d to %d after failed starts", before, after)
+	}
+}
+
 func TestNsPath(t *testing.T) {
 	skipIfNotRoot(t)
 
@@ -0,0 +1,538 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// whiteoutPrefix 是 OCI 层中表示删除的文件名前缀。
+	whiteoutPrefix = ".wh."
+	// whiteoutMetaPrefix 是 AUFS 元数据文件前缀，导入时忽略。
+	whiteoutMetaPrefix = ".wh..wh."
+	// whiteoutOpaque 表示所在目录为 opaque（隐藏更低层的同名目录内容）。
+	whiteoutOpaque = ".wh..wh..opq"
+
+	// defaultPathEnv 是镜像未设置 PATH 时使用的默认值。
+	defaultPathEnv = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+	mediaTypeOCIIndex        = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerManifests = "application/vnd.docker.distribution.manifest.list.v2+json"
+	annotationRefName        = "org.opencontainers.image.ref.name"
+	annotationContainerdName = "io.containerd.image.name"
+)
+
+// ImageConfig 是镜像的运行时默认配置，来自 OCI/Docker 镜像配置。
+type ImageConfig struct {
+	Env        []string `json:"env,omitempty"`
+	WorkingDir string   `json:"working_dir,omitempty"`
+	User       string   `json:"user,omitempty"`
+	Entrypoint []string `json:"entrypoint,omitempty"`
+	Cmd        []string `json:"cmd,omitempty"`
+}
+
+// Command 按 Docker 语义合成最终命令：args 非空时替换 Cmd，Entrypoint 始终在前。
+func (c *ImageConfig) Command(args []string) []string {
+	if len(args) == 0 {
+		args = c.Cmd
+	}
+	return append(append([]string{}, c.Entrypoint...), args...)
+}
+
+// Apply 将镜像默认配置应用到 Namespace。
+// 镜像环境变量作为基础（不再继承宿主机环境），ns.Env 中的同名变量覆盖镜像值；
+// ns.Dir、ns.User 已显式设置时保持不变。必须在 Start() 之前调用。
+func (c *ImageConfig) Apply(ns *Namespace) {
+	ns.Env = mergeEnv(c.Env, ns.Env)
+	if ns.Dir == "" {
+		ns.Dir = c.WorkingDir
+	}
+	if ns.User == "" {
+		ns.User = c.User
+	}
+}
+
+// mergeEnv 合并两组 KEY=VALUE 环境变量，override 中的同名变量覆盖 base，
+// 结果中缺少 PATH 时补充默认值。
+func mergeEnv(base, override []string) []string {
+	merged := make([]string, 0, len(base)+len(override)+1)
+	index := make(map[string]int)
+	for _, list := range [][]string{base, override} {
+		for _, e := range list {
+			key, _, _ := strings.Cut(e, "=")
+			if i, ok := index[key]; ok {
+				merged[i] = e
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, e)
+		}
+	}
+	if _, ok := index["PATH"]; !ok {
+		merged = append(merged, defaultPathEnv)
+	}
+	return merged
+}
+
+// ociDescriptor 是 OCI 内容描述符。
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+// ociIndex 是 OCI image index（index.json）或 Docker manifest list。
+type ociIndex struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// ociManifest 是 OCI/Docker v2 镜像清单。
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// ociImageConfig 是镜像配置中导入所需的字段。
+type ociImageConfig struct {
+	Config struct {
+		Env        []string `json:"Env"`
+		WorkingDir string   `json:"WorkingDir"`
+		User       string   `json:"User"`
+		Entrypoint []string `json:"Entrypoint"`
+		Cmd        []string `json:"Cmd"`
+	} `json:"config"`
+	RootFS struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// dockerManifestEntry 是 docker save 归档中 manifest.json 的一项。
+type dockerManifestEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// imageSource 是从归档中解析出的待导入镜像。
+type imageSource struct {
+	ref    string
+	config ociImageConfig
+	layers []imageLayerBlob
+}
+
+// imageLayerBlob 是待导入的层文件；Digest 非空时导入前校验文件内容。
+type imageLayerBlob struct {
+	path      string
+	digest    string
+	size      int64
+	mediaType string
+}
+
+// blobPathPattern 匹配内容寻址的 blob 路径（OCI layout 及新版 docker save）。
+var blobPathPattern = regexp.MustCompile(`(?:^|/)blobs/sha256/([a-f0-9]{64})$`)
+
+// ImportImage 从本地 docker save 归档、OCI image-layout 目录或其 tar 归档导入镜像。
+//
+// 每一层以未压缩摘要（diffID）存入层存储，whiteout 转换为 overlay 格式，
+// 因此导入后的镜像可直接通过 ResolveLowerDirs/Acquire 用作 OverlayConfig.LowerDirs。
+// 导入时校验 blob 摘要与大小，以及层内容与镜像配置 rootfs.diff_ids 是否一致。
+// 镜像配置中的 Env、WorkingDir、User、Entrypoint、Cmd 保存在清单的 Config 中。
+//
+// name 为空时使用归档中的镜像标签（仅保留最后一段，如 "python:3.12"）。
+// 归档包含多个镜像时导入第一个；多平台 index 优先选择与当前平台匹配的清单。
+func (s *LayerStore) ImportImage(src, name string) (*ImageManifest, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("layerstore: import image: %w", err)
+	}
+
+	dir := src
+	if !fi.IsDir() {
+		tmp, removeTmp, err := s.tempDir("image-")
+		if err != nil {
+			return nil, err
+		}
+		defer removeTmp()
+		if err := extractImageArchive(src, tmp); err != nil {
+			return nil, fmt.Errorf("layerstore: import image %s: %w", src, err)
+		}
+		dir = tmp
+	}
+
+	var img *imageSource
+	switch {
+	case fileExists(filepath.Join(dir, "manifest.json")):
+		img, err = readDockerArchive(dir)
+	case fileExists(filepath.Join(dir, "index.json")):
+		img, err = readOCILayout(dir)
+	default:
+		err = errors.New("neither manifest.json nor index.json found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("layerstore: import image %s: %w", src, err)
+	}
+
+	if name == "" {
+		name = imageNameFromRef(img.ref)
+		if name == "" {
+			return nil, fmt.Errorf("layerstore: import image %s: no usable tag %q, specify a name", src, img.ref)
+		}
+	}
+	if _, err := s.imagePath(name); err != nil {
+		return nil, err
+	}
+
+	diffIDs := img.config.RootFS.DiffIDs
+	if len(diffIDs) != len(img.layers) {
+		return nil, fmt.Errorf("layerstore: import image %s: %d layers but %d diff_ids", src, len(img.layers), len(diffIDs))
+	}
+
+	digests := make([]string, 0, len(img.layers))
+	for i, l := range img.layers {
+		if strings.Contains(l.mediaType, "zstd") {
+			return nil, fmt.Errorf("layerstore: import image %s: layer %d: unsupported media type %s", src, i, l.mediaType)
+		}
+		if l.digest != "" {
+			if err := verifyBlob(l.path, l.digest, l.size); err != nil {
+				return nil, fmt.Errorf("layerstore: import image %s: layer %d: %w", src, i, err)
+			}
+		}
+		f, err := os.Open(l.path)
+		if err != nil {
+			return nil, fmt.Errorf("layerstore: import image %s: %w", src, err)
+		}
+		info, err := s.ImportLayer(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if info.Digest != diffIDs[i] {
+			// 已导入的层没有镜像引用，会在下次 Prune 时回收
+			return nil, fmt.Errorf("layerstore: import image %s: layer %d: diff_id mismatch: config %s, content %s",
+				src, i, diffIDs[i], info.Digest)
+		}
+		digests = append(digests, info.Digest)
+	}
+
+	c := img.config.Config
+	m := ImageManifest{
+		Name:   name,
+		Layers: digests,
+		Config: &ImageConfig{
+			Env:        c.Env,
+			WorkingDir: c.WorkingDir,
+			User:       c.User,
+			Entrypoint: c.Entrypoint,
+			Cmd:        c.Cmd,
+		},
+	}
+	if err := s.CreateImage(m); err != nil {
+		return nil, err
+	}
+
+	if s.logger != nil {
+		s.logger.Info("image imported",
+			zap.String("image", name),
+			zap.String("source", src),
+			zap.Int("layers", len(digests)),
+		)
+	}
+	return s.Image(name)
+}
+
+// readDockerArchive 解析已解包的 docker save 归档（manifest.json + 配置 + 层文件）。
+func readDockerArchive(dir string) (*imageSource, error) {
+	var entries []dockerManifestEntry
+	if err := readJSONFile(filepath.Join(dir, "manifest.json"), &entries); err != nil {
+		return nil, fmt.Errorf("manifest.json: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("manifest.json: no images")
+	}
+	e := entries[0]
+
+	img := &imageSource{}
+	if len(e.RepoTags) > 0 {
+		img.ref = e.RepoTags[0]
+	}
+
+	// 配置文件名即镜像 ID（旧格式 "<hex>.json"，新格式 "blobs/sha256/<hex>"）
+	cfgPath, err := securejoin(dir, e.Config)
+	if err != nil {
+		return nil, err
+	}
+	if hexID, ok := strings.CutSuffix(filepath.Base(e.Config), ".json"); ok && len(hexID) == 64 {
+		if err := verifyBlob(cfgPath, "sha256:"+hexID, -1); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	} else if mt := blobPathPattern.FindStringSubmatch(e.Config); mt != nil {
+		if err := verifyBlob(cfgPath, "sha256:"+mt[1], -1); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	}
+	if err := readJSONFile(cfgPath, &img.config); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	for _, l := range e.Layers {
+		p, err := securejoin(dir, l)
+		if err != nil {
+			return nil, err
+		}
+		blob := imageLayerBlob{path: p, size: -1}
+		if mt := blobPathPattern.FindStringSubmatch(l); mt != nil {
+			blob.digest = "sha256:" + mt[1]
+		}
+		img.layers = append(img.layers, blob)
+	}
+	return img, nil
+}
+
+// readOCILayout 解析 OCI image-layout 目录（oci-layout + index.json + blobs/）。
+func readOCILayout(dir string) (*imageSource, error) {
+	var index ociIndex
+	if err := readJSONFile(filepath.Join(dir, "index.json"), &index); err != nil {
+		return nil, fmt.Errorf("index.json: %w", err)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, errors.New("index.json: no manifests")
+	}
+
+	img := &imageSource{}
+	desc := index.Manifests[0]
+	if ref := desc.Annotations[annotationContainerdName]; ref != "" {
+		img.ref = ref
+	} else {
+		img.ref = desc.Annotations[annotationRefName]
+	}
+
+	// 多平台镜像：逐级解析嵌套的 index，选择与当前平台匹配的清单
+	for desc.MediaType == mediaTypeOCIIndex || desc.MediaType == mediaTypeDockerManifests {
+		var sub ociIndex
+		if err := readBlobJSON(dir, desc, &sub); err != nil {
+			return nil, err
+		}
+		d, err := selectPlatform(sub.Manifests)
+		if err != nil {
+			return nil, err
+		}
+		desc = d
+	}
+
+	var manifest ociManifest
+	if err := readBlobJSON(dir, desc, &manifest); err != nil {
+		return nil, err
+	}
+	if err := readBlobJSON(dir, manifest.Config, &img.config); err != nil {
+		return nil, err
+	}
+
+	for _, l := range manifest.Layers {
+		p, err := blobPath(dir, l.Digest)
+		if err != nil {
+			return nil, err
+		}
+		img.layers = append(img.layers, imageLayerBlob{
+			path:      p,
+			digest:    l.Digest,
+			size:      l.Size,
+			mediaType: l.MediaType,
+		})
+	}
+	return img, nil
+}
+
+// selectPlatform 从多平台清单列表中选择当前平台（linux/GOARCH）的清单。
+func selectPlatform(manifests []ociDescriptor) (ociDescriptor, error) {
+	for _, d := range manifests {
+		if d.Platform != nil && d.Platform.OS == "linux" && d.Platform.Architecture == runtime.GOARCH {
+			return d, nil
+		}
+	}
+	for _, d := range manifests {
+		if d.Platform == nil {
+			return d, nil
+		}
+	}
+	return ociDescriptor{}, fmt.Errorf("no manifest for linux/%s", runtime.GOARCH)
+}
+
+// blobPath 返回 OCI layout 中摘要对应的 blob 路径。
+func blobPath(dir, digest string) (string, error) {
+	if !digestPattern.MatchString(digest) {
+		return "", fmt.Errorf("unsupported digest %q", digest)
+	}
+	return securejoin(dir, filepath.Join("blobs", "sha256", strings.TrimPrefix(digest, "sha256:")))
+}
+
+// readBlobJSON 校验并解析描述符指向的 JSON blob。
+func readBlobJSON(dir string, desc ociDescriptor, v any) error {
+	p, err := blobPath(dir, desc.Digest)
+	if err != nil {
+		return err
+	}
+	if err := verifyBlob(p, desc.Digest, desc.Size); err != nil {
+		return err
+	}
+	if err := readJSONFile(p, v); err != nil {
+		return fmt.Errorf("blob %s: %w", desc.Digest, err)
+	}
+	return nil
+}
+
+// verifyBlob 校验文件的 sha256 摘要；size 非负时同时校验大小。
+func verifyBlob(path, digest string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return fmt.Errorf("blob %s: %w", digest, err)
+	}
+	if size >= 0 && n != size {
+		return fmt.Errorf("blob %s: size mismatch: expected %d, got %d", digest, size, n)
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != digest {
+		return fmt.Errorf("blob %s: digest mismatch: got %s", digest, got)
+	}
+	return nil
+}
+
+// extractImageArchive 将镜像归档（docker save 或 OCI layout 的 tar，可 gzip 压缩）解包到 dir。
+// 只解包目录、普通文件和符号链接（旧版 docker save 用符号链接共享重复层）。
+func extractImageArchive(src, dir string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stream, err := maybeGunzip(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(stream)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+
+		name := filepath.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		parent, err := securejoin(dir, filepath.Dir(name))
+		if err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		if err := os.MkdirAll(parent, 0700); err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		target := filepath.Join(parent, filepath.Base(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		case tar.TypeReg:
+			if err := removeNonDir(target); err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY|unix.O_NOFOLLOW, 0600)
+			if err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		case tar.TypeSymlink:
+			// 读取时通过 securejoin 在 dir 内解析，链接不会指向 dir 之外
+			if err := removeNonDir(target); err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		}
+	}
+}
+
+// applyWhiteout 将 OCI whiteout 条目转换为 overlay 原生格式：
+// ".wh..wh..opq" 给 parent 设置 opaque xattr，".wh.<name>" 创建 0:0 字符设备 <name>。
+func applyWhiteout(parent, base string) error {
+	if base == whiteoutOpaque {
+		if err := unix.Lsetxattr(parent, "trusted.overlay.opaque", []byte("y"), 0); err != nil {
+			return fmt.Errorf("set opaque: %w", err)
+		}
+		return nil
+	}
+	if strings.HasPrefix(base, whiteoutMetaPrefix) {
+		return nil
+	}
+
+	// 空名、"." 和 ".." 会让 Join 指向 parent 本身或其上级，整个目录会被删除
+	name := strings.TrimPrefix(base, whiteoutPrefix)
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid whiteout name %q", base)
+	}
+	target := filepath.Join(parent, name)
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	if err := unix.Mknod(target, unix.S_IFCHR, 0); err != nil {
+		return fmt.Errorf("mknod whiteout: %w", err)
+	}
+	return nil
+}
+
+// imageNameFromRef 将镜像引用转换为本地镜像名：去掉仓库路径和摘要，
+// 如 "docker.io/library/python:3.12" → "python:3.12"。不合法时返回空。
+func imageNameFromRef(ref string) string {
+	ref, _, _ = strings.Cut(ref, "@")
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		ref = ref[i+1:]
+	}
+	if !imageNamePattern.MatchString(ref) {
+		return ""
+	}
+	return ref
+}
+
+// fileExists 判断路径是否存在。
+func fileExists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
@@ -0,0 +1,329 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// ===================================================================
+// OCI/Docker 镜像导入单元测试（不需要 root）
+// ===================================================================
+
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// testImageConfig 构造引用给定层（未压缩 tar）的镜像配置。
+func testImageConfig(t *testing.T, layers [][]byte) []byte {
+	t.Helper()
+	var cfg ociImageConfig
+	cfg.Config.Env = []string{"PATH=/opt/bin:/usr/bin", "LANG=C.UTF-8"}
+	cfg.Config.WorkingDir = "/app"
+	cfg.Config.User = "1000:1000"
+	cfg.Config.Entrypoint = []string{"python"}
+	cfg.Config.Cmd = []string{"main.py"}
+	cfg.RootFS.Type = "layers"
+	for _, l := range layers {
+		cfg.RootFS.DiffIDs = append(cfg.RootFS.DiffIDs, sha256Digest(l))
+	}
+	data, err := json.Marshal(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// writeOCIBlob 将数据写入 OCI layout 的 blobs 目录并返回描述符。
+func writeOCIBlob(t *testing.T, dir, mediaType string, data []byte) ociDescriptor {
+	t.Helper()
+	d := sha256Digest(data)
+	p := filepath.Join(dir, "blobs", "sha256", d[len("sha256:"):])
+	os.MkdirAll(filepath.Dir(p), 0755)
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return ociDescriptor{MediaType: mediaType, Digest: d, Size: int64(len(data))}
+}
+
+// buildOCILayout 构造 OCI image-layout 目录，层以 gzip 压缩存储。
+func buildOCILayout(t *testing.T, ref string, layers [][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644)
+
+	manifest := ociManifest{
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Config:    writeOCIBlob(t, dir, "application/vnd.oci.image.config.v1+json", testImageConfig(t, layers)),
+	}
+	for _, l := range layers {
+		var gz bytes.Buffer
+		zw := gzip.NewWriter(&gz)
+		zw.Write(l)
+		zw.Close()
+		manifest.Layers = append(manifest.Layers,
+			writeOCIBlob(t, dir, "application/vnd.oci.image.layer.v1.tar+gzip", gz.Bytes()))
+	}
+	mdata, _ := json.Marshal(&manifest)
+	mdesc := writeOCIBlob(t, dir, manifest.MediaType, mdata)
+	mdesc.Annotations = map[string]string{annotationRefName: ref}
+
+	idata, _ := json.Marshal(&ociIndex{Manifests: []ociDescriptor{mdesc}})
+	os.WriteFile(filepath.Join(dir, "index.json"), idata, 0644)
+	return dir
+}
+
+// buildDockerArchive 构造旧格式的 docker save 归档（<id>.json + <n>/layer.tar）。
+func buildDockerArchive(t *testing.T, tag string, layers [][]byte) string {
+	t.Helper()
+	cfg := testImageConfig(t, layers)
+	cfgName := sha256Digest(cfg)[len("sha256:"):] + ".json"
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	add := func(name string, data []byte) {
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))})
+		tw.Write(data)
+	}
+	add(cfgName, cfg)
+	entry := dockerManifestEntry{Config: cfgName, RepoTags: []string{tag}}
+	for i, l := range layers {
+		name := filepath.Join(string(rune('a'+i)), "layer.tar")
+		add(name, l)
+		entry.Layers = append(entry.Layers, name)
+	}
+	mdata, _ := json.Marshal([]dockerManifestEntry{entry})
+	add("manifest.json", mdata)
+	tw.Close()
+
+	p := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(p, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestImageConfigCommand(t *testing.T) {
+	c := &ImageConfig{Entrypoint: []string{"python"}, Cmd: []string{"main.py"}}
+	if got := c.Command(nil); !reflect.DeepEqual(got, []string{"python", "main.py"}) {
+		t.Errorf("default command: got %v", got)
+	}
+	if got := c.Command([]string{"other.py"}); !reflect.DeepEqual(got, []string{"python", "other.py"}) {
+		t.Errorf("args should replace Cmd: got %v", got)
+	}
+}
+
+func TestImageConfigApply(t *testing.T) {
+	ns := NewNamespace(DefaultNamespaceConfig())
+	ns.Env = []string{"LANG=en_US.UTF-8", "DEBUG=1"}
+	ns.Dir = "/work"
+
+	c := &ImageConfig{Env: []string{"LANG=C.UTF-8", "HOME=/root"}, WorkingDir: "/app", User: "nobody"}
+	c.Apply(ns)
+
+	want := []string{"LANG=en_US.UTF-8", "HOME=/root", "DEBUG=1", defaultPathEnv}
+	if !reflect.DeepEqual(ns.Env, want) {
+		t.Errorf("env: got %v, want %v", ns.Env, want)
+	}
+	if ns.Dir != "/work" {
+		t.Errorf("explicit Dir should be kept, got %q", ns.Dir)
+	}
+	if ns.User != "nobody" {
+		t.Errorf("expected user from image, got %q", ns.User)
+	}
+}
+
+func TestImageNameFromRef(t *testing.T) {
+	tests := map[string]string{
+		"docker.io/library/python:3.12":      "python:3.12",
+		"registry:5000/team/agent:v1@sha256": "agent:v1",
+		"python":                             "python",
+		"":                                   "",
+	}
+	for ref, want := range tests {
+		if got := imageNameFromRef(ref); got != want {
+			t.Errorf("imageNameFromRef(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestApplyWhiteoutInvalidName(t *testing.T) {
+	parent := filepath.Join(t.TempDir(), "etc")
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		t.Fatal(err)
+	}
+	keep := filepath.Join(parent, "keep")
+	os.WriteFile(keep, []byte("x"), 0644)
+
+	for _, base := range []string{".wh.", ".wh..", ".wh...", ".wh.a/b"} {
+		if err := applyWhiteout(parent, base); err == nil {
+			t.Errorf("applyWhiteout(%q) should fail", base)
+		}
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Errorf("parent directory damaged by invalid whiteout: %v", err)
+	}
+}
+
+func TestImportOCILayout(t *testing.T) {
+	store, _ := NewLayerStore(t.TempDir())
+	base := buildLayerTar(t, map[string]string{"usr/bin/python": "base"})
+	app := buildLayerTar(t, map[string]string{"app/main.py": "print(1)"})
+	dir := buildOCILayout(t, "docker.io/library/python:3.12", [][]byte{base, app})
+
+	m, err := store.ImportImage(dir, "")
+	if err != nil {
+		t.Fatalf("ImportImage failed: %v", err)
+	}
+	if m.Name != "python:3.12" {
+		t.Errorf("expected name from ref annotation, got %q", m.Name)
+	}
+	if len(m.Layers) != 2 || m.Layers[0] != sha256Digest(base) || m.Layers[1] != sha256Digest(app) {
+		t.Errorf("layers should be stored by diffID, got %v", m.Layers)
+	}
+	if m.Config == nil || m.Config.WorkingDir != "/app" || m.Config.User != "1000:1000" ||
+		!reflect.DeepEqual(m.Config.Entrypoint, []string{"python"}) {
+		t.Errorf("unexpected image config: %+v", m.Config)
+	}
+
+	dirs, err := store.ResolveLowerDirs(m.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dirs[0], "app", "main.py")); string(data) != "print(1)" {
+		t.Errorf("top lower dir should be the app layer, got %q", data)
+	}
+}
+
+func TestImportDockerArchive(t *testing.T) {
+	store, _ := NewLayerStore(t.TempDir())
+	layer := buildLayerTar(t, map[string]string{"etc/os-release": "ID=test"})
+	archive := buildDockerArchive(t, "agent:v1", [][]byte{layer})
+
+	m, err := store.ImportImage(archive, "")
+	if err != nil {
+		t.Fatalf("ImportImage failed: %v", err)
+	}
+	if m.Name != "agent:v1" || len(m.Layers) != 1 || m.Layers[0] != sha256Digest(layer) {
+		t.Errorf("unexpected manifest: %+v", m)
+	}
+
+	// 显式名称覆盖标签
+	if m, err := store.ImportImage(archive, "custom"); err != nil || m.Name != "custom" {
+		t.Errorf("expected explicit name, got %+v, %v", m, err)
+	}
+}
+
+func TestImportImageDigestMismatch(t *testing.T) {
+	store, _ := NewLayerStore(t.TempDir())
+	layer := buildLayerTar(t, map[string]string{"a": "1"})
+	dir := buildOCILayout(t, "x:1", [][]byte{layer})
+
+	// 篡改层 blob 内容
+	var index ociIndex
+	readJSONFile(filepath.Join(dir, "index.json"), &index)
+	var manifest ociManifest
+	readBlobJSON(dir, index.Manifests[0], &manifest)
+	p, _ := blobPath(dir, manifest.Layers[0].Digest)
+	data, _ := os.ReadFile(p)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(p, data, 0644)
+
+	if _, err := store.ImportImage(dir, ""); err == nil {
+		t.Fatal("expected digest mismatch error")
+	}
+	if _, err := store.Image("x:1"); err == nil {
+		t.Error("image should not be created when verification fails")
+	}
+}
+
+func TestImportImageDiffIDMismatch(t *testing.T) {
+	store, _ := NewLayerStore(t.TempDir())
+	layer := buildLayerTar(t, map[string]string{"a": "1"})
+	other := buildLayerTar(t, map[string]string{"b": "2"})
+	archive := buildDockerArchive(t, "x:1", [][]byte{layer})
+
+	// 配置中的 diff_ids 与层内容不一致：用另一层的配置替换
+	dir := t.TempDir()
+	if err := extractImageArchive(archive, dir); err != nil {
+		t.Fatal(err)
+	}
+	var entries []dockerManifestEntry
+	readJSONFile(filepath.Join(dir, "manifest.json"), &entries)
+	cfg := testImageConfig(t, [][]byte{other})
+	cfgName := sha256Digest(cfg)[len("sha256:"):] + ".json"
+	os.WriteFile(filepath.Join(dir, cfgName), cfg, 0644)
+	entries[0].Config = cfgName
+	mdata, _ := json.Marshal(entries)
+	os.WriteFile(filepath.Join(dir, "manifest.json"), mdata, 0644)
+
+	if _, err := store.ImportImage(dir, ""); err == nil {
+		t.Fatal("expected diff_id mismatch error")
+	}
+}
+
+// ===================================================================
+// OCI/Docker 镜像导入集成测试（需要 root）
+// ===================================================================
+
+func TestImportImageWhiteouts(t *testing.T) {
+	skipIfNotRoot(t)
+
+	store, _ := NewLayerStore(t.TempDir())
+	base := buildLayerTar(t, map[string]string{"etc/a": "1", "etc/b": "2", "cache/x": "3"})
+	top := buildLayerTar(t, map[string]string{"etc/.wh.a": "", "cache/.wh..wh..opq": "", "cache/y": "4"})
+	dir := buildOCILayout(t, "wh:1", [][]byte{base, top})
+
+	m, err := store.ImportImage(dir, "")
+	if err != nil {
+		t.Fatalf("ImportImage failed: %v", err)
+	}
+	topDir, _ := store.LayerDir(m.Layers[1])
+
+	fi, err := os.Lstat(filepath.Join(topDir, "etc", "a"))
+	if err != nil || !isWhiteout(fi) {
+		t.Errorf("expected overlay whiteout for etc/a, got %v, %v", fi, err)
+	}
+	if _, err := os.Lstat(filepath.Join(topDir, "etc", ".wh.a")); !os.IsNotExist(err) {
+		t.Error("OCI whiteout marker should not be stored")
+	}
+	if !isOpaqueDir(filepath.Join(topDir, "cache")) {
+		t.Error("expected cache to be an opaque directory")
+	}
+
+	// 按 overlay 语义合并后，被删除和被 opaque 隐藏的文件不可见
+	lowerDirs, release, err := store.Acquire("wh:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	ovCfg := DefaultOverlayConfig("")
+	ovCfg.LowerDirs = lowerDirs
+	ov := NewOverlayFS(ovCfg)
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("overlay setup: %v", err)
+	}
+	defer ov.Cleanup()
+
+	res, err := ov.CollectArtifacts(DefaultArtifactConfig(t.TempDir(), "**"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range res.Files {
+		paths = append(paths, f.Path)
+	}
+	if want := []string{"/cache/y", "/etc/b"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("merged view: got %v, want %v", paths, want)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"syscall"
@@ -18,12 +19,13 @@ import (
 
 // OverlayConfig 定义OverlayFS文件系统隔离的配置。
 type OverlayConfig struct {
-	Enabled   bool     // 是否启用OverlayFS隔离
-	LowerDirs []string // 只读底层目录（支持多层，高优先级在前）
-	MergeDir  string   // 合并挂载点（默认自动生成）
-	TmpfsSize string   // tmpfs大小限制，如 "64m"、"256m"（默认 "64m"）
-	BaseDir   string   // 临时目录父路径（默认 "/tmp"）
-	ReadOnly  bool     // true时无UpperDir，完全只读
+	Enabled     bool     // 是否启用OverlayFS隔离
+	LowerDirs   []string // 只读底层目录（支持多层，高优先级在前）
+	MergeDir    string   // 合并挂载点（默认自动生成）
+	TmpfsSize   string   // tmpfs大小限制，如 "64m"、"256m"（默认 "64m"）
+	TmpfsInodes string   // tmpfs inode数量上限，如 "100k"（默认不限制，使用内核默认值）
+	BaseDir     string   // 临时目录父路径（默认 "/tmp"）
+	ReadOnly    bool     // true时无UpperDir，完全只读
 }
 
 // DefaultOverlayConfig 返回默认的OverlayFS配置。
@@ -47,6 +49,9 @@ type overlayInitConfig struct {
 	ReadOnly  bool     `json:"read_only,omitempty"`
 }
 
+// tmpfsInodesPattern 匹配 tmpfs 的 nr_inodes= 选项：数量，可带 k/m/g 后缀（不支持百分比）。
+var tmpfsInodesPattern = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+
 // OverlayFS 管理单个沙箱的OverlayFS生命周期。
 //
 // 使用方式：
@@ -58,11 +63,12 @@ type overlayInitConfig struct {
 type OverlayFS struct {
 	config    OverlayConfig
 	logger    *zap.Logger
-	id        string // 唯一标识，用于目录命名
-	baseDir   string // /tmp/sandbox-overlay-<id>/
-	upperDir  string // baseDir/upper
-	workDir   string // baseDir/work
-	mergeDir  string // baseDir/merged 或用户指定
+	id        string       // 唯一标识，用于目录命名
+	baseDir   string       // /tmp/sandbox-overlay-<id>/
+	upperDir  string       // baseDir/upper
+	workDir   string       // baseDir/work
+	mergeDir  string       // baseDir/merged 或用户指定
+	peak      OverlayUsage // 采样到的峰值用量
 	setupDone bool
 	mu        sync.Mutex
 }
@@ -127,6 +133,13 @@ func (ov *OverlayFS) Setup() error {
 			return fmt.Errorf("overlayfs: lower dir %q: %w", d, err)
 		}
 	}
+	// 两者都拼接进 tmpfs 挂载选项，带逗号的值会注入额外选项（如覆盖 size）
+	if ov.config.TmpfsSize != "" && !tmpfsSizePattern.MatchString(ov.config.TmpfsSize) {
+		return fmt.Errorf("overlayfs: invalid tmpfs size %q", ov.config.TmpfsSize)
+	}
+	if ov.config.TmpfsInodes != "" && !tmpfsInodesPattern.MatchString(ov.config.TmpfsInodes) {
+		return fmt.Errorf("overlayfs: invalid tmpfs inodes %q", ov.config.TmpfsInodes)
+	}
 
 	// 生成唯一ID和路径
 	ov.id = generateID()
@@ -147,6 +160,9 @@ func (ov *OverlayFS) Setup() error {
 		tmpfsSize = "64m"
 	}
 	mountOpts := fmt.Sprintf("size=%s,mode=0700", tmpfsSize)
+	if ov.config.TmpfsInodes != "" {
+		mountOpts += ",nr_inodes=" + ov.config.TmpfsInodes
+	}
 	if err := syscall.Mount("tmpfs", ov.baseDir, "tmpfs", 0, mountOpts); err != nil {
 		os.Remove(ov.baseDir)
 		return fmt.Errorf("overlayfs: mount tmpfs: %w", err)
@@ -169,6 +185,13 @@ func (ov *OverlayFS) Setup() error {
 			return fmt.Errorf("overlayfs: mkdir %s: %w", dir, err)
 		}
 	}
+	// merged 根目录的属性取自 upper 根目录：必须对非 root 运行用户可遍历，
+	// 宿主机上的访问仍由 0700 的基础目录限制
+	if err := os.Chmod(ov.upperDir, 0755); err != nil {
+		syscall.Unmount(ov.baseDir, syscall.MNT_DETACH)
+		os.RemoveAll(ov.baseDir)
+		return fmt.Errorf("overlayfs: chmod %s: %w", ov.upperDir, err)
+	}
 
 	ov.setupDone = true
 
@@ -177,6 +200,7 @@ func (ov *OverlayFS) Setup() error {
 			zap.String("overlay_id", ov.id),
 			zap.Strings("lower_dirs", ov.config.LowerDirs),
 			zap.String("tmpfs_size", ov.config.TmpfsSize),
+			zap.String("tmpfs_inodes", ov.config.TmpfsInodes),
 		)
 	}
 
@@ -286,3 +310,68 @@ func (ov *OverlayFS) ID() string {
 	defer ov.mu.Unlock()
 	return ov.id
 }
+
+// OverlayUsage 描述 OverlayFS 上层 tmpfs 的空间与 inode 用量。
+type OverlayUsage struct {
+	BytesTotal  uint64 // tmpfs 总容量（字节）
+	BytesUsed   uint64 // 已用字节数
+	BytesFree   uint64 // 剩余字节数
+	InodesTotal uint64 // inode 总数
+	InodesUsed  uint64 // 已用 inode 数
+	InodesFree  uint64 // 剩余 inode 数
+}
+
+// Exhausted 判断空间或 inode 是否已耗尽（此时沙箱内的写入会返回 ENOSPC）。
+func (u OverlayUsage) Exhausted() bool {
+	return (u.BytesTotal > 0 && u.BytesFree == 0) || (u.InodesTotal > 0 && u.InodesFree == 0)
+}
+
+// Usage 通过 statfs 读取上层 tmpfs 的当前用量，并更新峰值记录。
+// 必须在 Setup() 之后、Cleanup() 之前调用。
+func (ov *OverlayFS) Usage() (OverlayUsage, error) {
+	ov.mu.Lock()
+	defer ov.mu.Unlock()
+
+	if !ov.setupDone {
+		return OverlayUsage{}, fmt.Errorf("overlayfs: not set up")
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(ov.baseDir, &st); err != nil {
+		return OverlayUsage{}, fmt.Errorf("overlayfs: statfs %s: %w", ov.baseDir, err)
+	}
+
+	bsize := uint64(st.Bsize)
+	u := OverlayUsage{
+		BytesTotal:  st.Blocks * bsize,
+		BytesUsed:   (st.Blocks - st.Bfree) * bsize,
+		BytesFree:   st.Bavail * bsize,
+		InodesTotal: st.Files,
+		InodesUsed:  st.Files - st.Ffree,
+		InodesFree:  st.Ffree,
+	}
+	ov.updatePeak(u)
+	return u, nil
+}
+
+// PeakUsage 返回 Usage() 采样到的峰值：已用量取最大值，剩余量取最小值。
+// 尚未采样时返回零值。
+func (ov *OverlayFS) PeakUsage() OverlayUsage {
+	ov.mu.Lock()
+	defer ov.mu.Unlock()
+	return ov.peak
+}
+
+// updatePeak 合并一次采样到峰值记录。调用方必须持有 ov.mu。
+func (ov *OverlayFS) updatePeak(u OverlayUsage) {
+	if ov.peak.BytesTotal == 0 && ov.peak.InodesTotal == 0 {
+		ov.peak = u
+		return
+	}
+	ov.peak.BytesTotal = u.BytesTotal
+	ov.peak.InodesTotal = u.InodesTotal
+	ov.peak.BytesUsed = max(ov.peak.BytesUsed, u.BytesUsed)
+	ov.peak.InodesUsed = max(ov.peak.InodesUsed, u.InodesUsed)
+	ov.peak.BytesFree = min(ov.peak.BytesFree, u.BytesFree)
+	ov.peak.InodesFree = min(ov.peak.InodesFree, u.InodesFree)
+}
@@ -137,6 +137,17 @@ func TestOverlaySetupValidation(t *testing.T) {
 	if err := ov.Setup(); err == nil {
 		t.Error("expected error for nonexistent lower dir")
 	}
+
+	// 测试注入额外挂载选项的 tmpfs 大小和 inode 数
+	for _, cfg := range []OverlayConfig{
+		{Enabled: true, LowerDirs: []string{"/"}, TmpfsInodes: "1k,size=100g"},
+		{Enabled: true, LowerDirs: []string{"/"}, TmpfsInodes: "50%"},
+		{Enabled: true, LowerDirs: []string{"/"}, TmpfsSize: "64m,nr_inodes=0"},
+	} {
+		if err := NewOverlayFS(cfg).Setup(); err == nil || !strings.Contains(err.Error(), "invalid tmpfs") {
+			t.Errorf("expected invalid tmpfs option error for %+v, got %v", cfg, err)
+		}
+	}
 }
 
 // --- 集成测试（需要root权限） ---
@@ -567,6 +578,101 @@ func TestOverlayTmpfsSize(t *testing.T) {
 	}
 }
 
+func TestOverlayUsageExhausted(t *testing.T) {
+	tests := []struct {
+		name string
+		u    OverlayUsage
+		want bool
+	}{
+		{"zero value", OverlayUsage{}, false},
+		{"space left", OverlayUsage{BytesTotal: 100, BytesFree: 10, InodesTotal: 10, InodesFree: 5}, false},
+		{"bytes full", OverlayUsage{BytesTotal: 100, BytesFree: 0, InodesTotal: 10, InodesFree: 5}, true},
+		{"inodes full", OverlayUsage{BytesTotal: 100, BytesFree: 10, InodesTotal: 10, InodesFree: 0}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.u.Exhausted(); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestOverlayUsage(t *testing.T) {
+	skipIfNotRoot(t)
+
+	cfg := DefaultOverlayConfig("/")
+	cfg.TmpfsSize = "4m"
+	cfg.TmpfsInodes = "100"
+	ov := NewOverlayFS(cfg)
+	if _, err := ov.Usage(); err == nil {
+		t.Error("expected error before Setup")
+	}
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer ov.Cleanup()
+
+	before, err := ov.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if before.BytesTotal != 4*1024*1024 {
+		t.Errorf("expected 4MB total, got %d", before.BytesTotal)
+	}
+	if before.InodesTotal != 100 {
+		t.Errorf("expected 100 inodes, got %d", before.InodesTotal)
+	}
+
+	os.WriteFile(filepath.Join(ov.UpperDir(), "f"), make([]byte, 1024*1024), 0644)
+	after, err := ov.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if after.BytesUsed < before.BytesUsed+1024*1024 {
+		t.Errorf("expected usage to grow by 1MB: before=%d after=%d", before.BytesUsed, after.BytesUsed)
+	}
+	if after.InodesUsed != before.InodesUsed+1 {
+		t.Errorf("expected one more inode: before=%d after=%d", before.InodesUsed, after.InodesUsed)
+	}
+
+	// 删除文件后峰值应保持
+	os.Remove(filepath.Join(ov.UpperDir(), "f"))
+	ov.Usage()
+	if peak := ov.PeakUsage(); peak.BytesUsed != after.BytesUsed {
+		t.Errorf("peak should keep max usage %d, got %d", after.BytesUsed, peak.BytesUsed)
+	}
+}
+
+func TestOverlayInodeExhaustionInResult(t *testing.T) {
+	skipIfNotRoot(t)
+
+	cfg := DefaultOverlayConfig("/")
+	cfg.TmpfsInodes = "50"
+	ov := NewOverlayFS(cfg)
+	if err := ov.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	ns := NewNamespace(NamespaceConfig{Mount: true})
+	ns.SetOverlayFS(ov)
+	defer ns.Cleanup()
+
+	// 创建超过 inode 上限的空文件
+	cmd := fmt.Sprintf("cd %s && for i in $(seq 1 100); do touch f$i 2>/dev/null; done; true", ov.MergeDir())
+	result, err := ns.Execute("sh", "-c", cmd)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if result.OverlayUsage == nil {
+		t.Fatal("expected overlay usage in result")
+	}
+	if !result.OverlayUsage.Exhausted() {
+		t.Errorf("expected inode exhaustion, got %+v", *result.OverlayUsage)
+	}
+	if result.OverlayUsage.InodesTotal != 50 {
+		t.Errorf("expected 50 inodes total, got %d", result.OverlayUsage.InodesTotal)
+	}
+}
+
 func TestOverlayMultipleLowerDirs(t *testing.T) {
 	skipIfNotRoot(t)
 
@@ -74,68 +74,3 @@ func doPivotRoot(newRoot string) error {
 
 	return nil
 }
-
-// setupMinimalDev 在新 root 中创建最小的 /dev 目录。
-// 包含基本设备节点和必要的符号链接。
-//
-// 设备列表：
-//   - /dev/null   - 丢弃写入的数据
-//   - /dev/zero   - 读取返回零字节
-//   - /dev/urandom - 伪随机数生成器
-//   - /dev/fd     -> /proc/self/fd（文件描述符）
-//   - /dev/stdin  -> /proc/self/fd/0
-//   - /dev/stdout -> /proc/self/fd/1
-//   - /dev/stderr -> /proc/self/fd/2
-func setupMinimalDev(rootDir string) error {
-	devDir := filepath.Join(rootDir, "dev")
-	if err := os.MkdirAll(devDir, 0755); err != nil {
-		return fmt.Errorf("mkdir %s: %w", devDir, err)
-	}
-
-	// bind mount 必要的设备节点
-	devices := []struct {
-		src string
-		dst string
-	}{
-		{"/dev/null", filepath.Join(devDir, "null")},
-		{"/dev/zero", filepath.Join(devDir, "zero")},
-		{"/dev/urandom", filepath.Join(devDir, "urandom")},
-	}
-
-	for _, d := range devices {
-		// 创建目标文件（bind mount 需要目标文件存在）
-		f, err := os.OpenFile(d.dst, os.O_CREATE|os.O_WRONLY, 0666)
-		if err != nil {
-			return fmt.Errorf("create %s: %w", d.dst, err)
-		}
-		f.Close()
-
-		// bind mount 设备
-		if err := syscall.Mount(d.src, d.dst, "", syscall.MS_BIND, ""); err != nil {
-			return fmt.Errorf("bind mount %s -> %s: %w", d.src, d.dst, err)
-		}
-	}
-
-	// 创建符号链接（这些链接在 /proc 挂载后才能正常工作）
-	symlinks := []struct {
-		target string
-		link   string
-	}{
-		{"/proc/self/fd", filepath.Join(devDir, "fd")},
-		{"/proc/self/fd/0", filepath.Join(devDir, "stdin")},
-		{"/proc/self/fd/1", filepath.Join(devDir, "stdout")},
-		{"/proc/self/fd/2", filepath.Join(devDir, "stderr")},
-	}
-
-	for _, s := range symlinks {
-		// 如果链接已存在则跳过
-		if _, err := os.Lstat(s.link); err == nil {
-			continue
-		}
-		if err := os.Symlink(s.target, s.link); err != nil {
-			return fmt.Errorf("symlink %s -> %s: %w", s.link, s.target, err)
-		}
-	}
-
-	return nil
-}
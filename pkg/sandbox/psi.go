@@ -0,0 +1,230 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// PSITrigger 是一个 PSI（Pressure Stall Information）触发器：
+// 在任意 Window 时间窗口内因 Resource 停顿的累计时间超过 Stall 时触发。
+// 内核对每个触发器每个窗口最多通知一次。
+type PSITrigger struct {
+	Resource string        // "cpu"、"memory" 或 "io"
+	Full     bool          // false=some（至少一个任务停顿），true=full（所有非空闲任务同时停顿）
+	Stall    time.Duration // 停顿时间阈值
+	Window   time.Duration // 时间窗口，内核要求 500ms-10s（非特权进程还要求为 2s 的整数倍）
+}
+
+// PSIEvent 是一次触发器通知。
+type PSIEvent struct {
+	Trigger  PSITrigger
+	Time     time.Time
+	Pressure *PSIStats // 通知时该资源的 PSI 统计，读取失败时为 nil
+}
+
+// ParsePSITrigger 解析 "<resource> <some|full> <stall_us> <window_us>" 格式的触发器，
+// 例如 "memory some 150000 1000000"（1 秒内内存停顿超过 150ms）。
+func ParsePSITrigger(s string) (PSITrigger, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 4 {
+		return PSITrigger{}, fmt.Errorf("invalid psi trigger %q (expected \"<resource> <some|full> <stall_us> <window_us>\")", s)
+	}
+	t := PSITrigger{Resource: fields[0]}
+	switch fields[1] {
+	case "some":
+	case "full":
+		t.Full = true
+	default:
+		return PSITrigger{}, fmt.Errorf("invalid psi trigger %q: expected some or full, got %q", s, fields[1])
+	}
+	stall, err1 := strconv.ParseUint(fields[2], 10, 32)
+	window, err2 := strconv.ParseUint(fields[3], 10, 32)
+	if err1 != nil || err2 != nil {
+		return PSITrigger{}, fmt.Errorf("invalid psi trigger %q: stall and window must be microseconds", s)
+	}
+	t.Stall = time.Duration(stall) * time.Microsecond
+	t.Window = time.Duration(window) * time.Microsecond
+	if err := t.validate(); err != nil {
+		return PSITrigger{}, err
+	}
+	return t, nil
+}
+
+// String 返回 ParsePSITrigger 接受的格式。
+func (t PSITrigger) String() string {
+	return t.Resource + " " + t.spec()
+}
+
+// spec 返回写入 <resource>.pressure 的触发器描述，例如 "some 150000 1000000"。
+func (t PSITrigger) spec() string {
+	kind := "some"
+	if t.Full {
+		kind = "full"
+	}
+	return fmt.Sprintf("%s %d %d", kind, t.Stall.Microseconds(), t.Window.Microseconds())
+}
+
+func (t PSITrigger) validate() error {
+	switch t.Resource {
+	case "cpu", "memory", "io":
+	default:
+		return fmt.Errorf("invalid psi trigger resource %q (expected cpu, memory or io)", t.Resource)
+	}
+	if t.Window < 500*time.Millisecond || t.Window > 10*time.Second {
+		return fmt.Errorf("invalid psi trigger %s: window must be in [500ms, 10s], got %v", t, t.Window)
+	}
+	if t.Stall <= 0 || t.Stall > t.Window {
+		return fmt.Errorf("invalid psi trigger %s: stall must be in (0, window], got %v", t, t.Stall)
+	}
+	return nil
+}
+
+// WatchPressure 在此 cgroup 上注册 PSI 触发器，每次触发时调用 fn。
+//
+// fn 在该触发器专用的 goroutine 中依次调用，不应长时间阻塞。
+// 调用返回的 stop 函数或 cgroup 被删除时停止监听；stop 返回后可能还有一次正在进行的 fn 调用。
+// 即使 cgroup 已被删除，也应调用 stop 释放文件描述符。
+func (cg *CgroupsV2) WatchPressure(t PSITrigger, fn func(PSIEvent)) (func(), error) {
+	stop, _, err := cg.watch(t, fn)
+	if err != nil {
+		return nil, err
+	}
+	return stop, nil
+}
+
+// PressureEvents 在此 cgroup 上注册一组 PSI 触发器，事件发送到返回的 channel。
+//
+// 调用返回的 stop 函数或 cgroup 被删除时停止监听，所有触发器退出后关闭 channel。
+// 注册任一触发器失败时已注册的触发器被撤销。
+func (cg *CgroupsV2) PressureEvents(triggers ...PSITrigger) (<-chan PSIEvent, func(), error) {
+	if len(triggers) == 0 {
+		return nil, nil, fmt.Errorf("cgroups: no psi triggers")
+	}
+	ch := make(chan PSIEvent, len(triggers))
+	done := make(chan struct{})
+	var once sync.Once
+	var stops []func()
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			for _, s := range stops {
+				s()
+			}
+		})
+	}
+
+	var wg sync.WaitGroup
+	for _, t := range triggers {
+		s, exited, err := cg.watch(t, func(ev PSIEvent) {
+			select {
+			case ch <- ev:
+			case <-done:
+			}
+		})
+		if err != nil {
+			stop()
+			wg.Wait()
+			return nil, nil, err
+		}
+		stops = append(stops, s)
+		wg.Add(1)
+		go func() {
+			<-exited
+			wg.Done()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return ch, stop, nil
+}
+
+// watch 在此 cgroup 上注册触发器，额外返回监听 goroutine 退出的通知。
+func (cg *CgroupsV2) watch(t PSITrigger, fn func(PSIEvent)) (func(), <-chan struct{}, error) {
+	cg.mu.Lock()
+	dir, id, done, logger := cg.cgroupDir, cg.id, cg.setupDone, cg.logger
+	cg.mu.Unlock()
+
+	if !done {
+		return nil, nil, fmt.Errorf("cgroups: not set up")
+	}
+	stop, exited, err := watchPSI(dir, t, fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if logger != nil {
+		logger.Info("psi trigger registered", zap.String("cgroup_id", id), zap.String("trigger", t.String()))
+	}
+	return stop, exited, nil
+}
+
+// watchPSI 在 cgroup 目录 dir 上注册触发器并启动监听 goroutine。
+// 返回 stop 函数和 goroutine 退出时关闭的 channel。
+//
+// 触发器的生命周期与打开的文件描述符绑定：向 <resource>.pressure 写入触发器描述后，
+// 内核在阈值被突破时以 POLLPRI 通知；cgroup 被删除时返回 POLLERR/POLLHUP。
+func watchPSI(dir string, t PSITrigger, fn func(PSIEvent)) (func(), <-chan struct{}, error) {
+	if err := t.validate(); err != nil {
+		return nil, nil, fmt.Errorf("cgroups: %w", err)
+	}
+	path := filepath.Join(dir, t.Resource+".pressure")
+	fd, err := unix.Open(path, unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cgroups: psi trigger: open %s: %w", path, err)
+	}
+	// 内核将写入的最后一个字节替换为 NUL，因此显式以 NUL 结尾
+	if _, err := unix.Write(fd, []byte(t.spec()+"\x00")); err != nil {
+		unix.Close(fd)
+		if errors.Is(err, unix.EINVAL) && t.Window%(2*time.Second) != 0 {
+			return nil, nil, fmt.Errorf("cgroups: psi trigger %s: %w (without CAP_SYS_RESOURCE the window must be a multiple of 2s)", t, err)
+		}
+		return nil, nil, fmt.Errorf("cgroups: psi trigger %s: %w", t, err)
+	}
+
+	// stop 通过关闭管道写端唤醒 poll
+	var pipe [2]int
+	if err := unix.Pipe2(pipe[:], unix.O_CLOEXEC); err != nil {
+		unix.Close(fd)
+		return nil, nil, fmt.Errorf("cgroups: psi trigger: pipe: %w", err)
+	}
+	var once sync.Once
+	stop := func() { once.Do(func() { unix.Close(pipe[1]) }) }
+
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		defer unix.Close(fd)
+		defer unix.Close(pipe[0])
+
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLPRI}, {Fd: int32(pipe[0]), Events: unix.POLLIN}}
+		for {
+			fds[0].Revents, fds[1].Revents = 0, 0
+			if _, err := unix.Poll(fds, -1); err != nil {
+				if errors.Is(err, unix.EINTR) {
+					continue
+				}
+				return
+			}
+			if fds[1].Revents != 0 || fds[0].Revents&(unix.POLLERR|unix.POLLHUP|unix.POLLNVAL) != 0 {
+				return
+			}
+			if fds[0].Revents&unix.POLLPRI != 0 {
+				ev := PSIEvent{Trigger: t, Time: time.Now()}
+				ev.Pressure, _ = readPSI(path)
+				fn(ev)
+			}
+		}
+	}()
+	return stop, exited, nil
+}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// ===================================================================
+// PSI 触发器单元测试（不需要 root）
+// ===================================================================
+
+func TestParsePSITrigger(t *testing.T) {
+	trig, err := ParsePSITrigger("memory some 150000 1000000")
+	if err != nil {
+		t.Fatalf("ParsePSITrigger failed: %v", err)
+	}
+	want := PSITrigger{Resource: "memory", Stall: 150 * time.Millisecond, Window: time.Second}
+	if trig != want {
+		t.Errorf("ParsePSITrigger = %+v, want %+v", trig, want)
+	}
+	if got := trig.String(); got != "memory some 150000 1000000" {
+		t.Errorf("String() = %q", got)
+	}
+
+	full, err := ParsePSITrigger("io full 500000 2000000")
+	if err != nil || !full.Full || full.spec() != "full 500000 2000000" {
+		t.Errorf("unexpected full trigger %+v (err %v)", full, err)
+	}
+
+	for _, bad := range []string{
+		"",
+		"memory some 150000",
+		"disk some 150000 1000000",
+		"memory most 150000 1000000",
+		"memory some x 1000000",
+		"memory some 150000 100000",   // 窗口小于 500ms
+		"memory some 150000 20000000", // 窗口大于 10s
+		"memory some 0 1000000",
+		"memory some 2000000 1000000", // 阈值大于窗口
+	} {
+		if _, err := ParsePSITrigger(bad); err == nil {
+			t.Errorf("ParsePSITrigger(%q): expected error", bad)
+		}
+	}
+}
+
+func TestWatchPressureNotSetUp(t *testing.T) {
+	cg := NewCgroupsV2(DefaultCgroupsConfig())
+	trig := PSITrigger{Resource: "cpu", Stall: 100 * time.Millisecond, Window: time.Second}
+	if _, err := cg.WatchPressure(trig, func(PSIEvent) {}); err == nil {
+		t.Error("expected error before Setup")
+	}
+	if _, _, err := cg.PressureEvents(); err == nil {
+		t.Error("expected error without triggers")
+	}
+}
+
+// ===================================================================
+// PSI 触发器集成测试（需要 root）
+// ===================================================================
+
+func TestCgroupsPressureEvents(t *testing.T) {
+	base := cgroup2Mount(t)
+
+	cg := NewCgroupsV2(CgroupsConfig{Enabled: true, BaseDir: base})
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer cg.Cleanup()
+
+	// 2 秒内 CPU 等待超过 50ms 即触发（非特权进程的窗口必须是 2s 的整数倍）
+	trig := PSITrigger{Resource: "cpu", Stall: 50 * time.Millisecond, Window: 2 * time.Second}
+	events, stop, err := cg.PressureEvents(trig)
+	if err != nil {
+		t.Skipf("skipping: psi triggers not supported: %v", err)
+	}
+	defer stop()
+
+	// 比 CPU 数更多的忙循环使任务排队等待 CPU
+	for i := 0; i < 4; i++ {
+		cmd := exec.Command("sh", "-c", "while :; do :; done")
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer cmd.Wait()
+		if err := cg.AddProcess(cmd.Process.Pid); err != nil {
+			cmd.Process.Kill()
+			t.Fatalf("AddProcess failed: %v", err)
+		}
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Trigger != trig || ev.Pressure == nil {
+			t.Errorf("unexpected event %+v", ev)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("psi trigger did not fire under cpu contention")
+	}
+
+	// 删除 cgroup 后监听自动结束，channel 关闭
+	if err := cg.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("events channel not closed after the cgroup was removed")
+		}
+	}
+}
@@ -0,0 +1,545 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// RootfsConfig 定义最小 rootfs 的内容。
+type RootfsConfig struct {
+	Binaries []string // 可执行文件：名称在 PATH 中查找，或绝对路径
+	Files    []string // 额外复制的宿主机文件或目录（递归），如 Python 标准库
+	Hardlink bool     // 尽可能使用硬链接而非复制（仅适合只读使用，如作为 lowerdir）
+}
+
+// DefaultRootfsConfig 返回包含给定可执行文件的配置。
+func DefaultRootfsConfig(binaries ...string) RootfsConfig {
+	return RootfsConfig{Binaries: binaries}
+}
+
+// rootfsEtcFiles 是最小 rootfs 的 /etc 基础文件（已从宿主机复制的不会被覆盖）。
+var rootfsEtcFiles = map[string]string{
+	"etc/passwd": "root:x:0:0:root:/root:/bin/sh\n" +
+		"nobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin\n",
+	"etc/group":         "root:x:0:\nnogroup:x:65534:\n",
+	"etc/hosts":         "127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost ip6-loopback\n",
+	"etc/nsswitch.conf": "passwd: files\ngroup: files\nhosts: files dns\n",
+}
+
+// rootfsDirs 是最小 rootfs 的基础目录及权限（/dev、/proc、/sys 为挂载点）。
+var rootfsDirs = []struct {
+	path string
+	mode os.FileMode
+}{
+	{"etc", 0755},
+	{"tmp", os.ModePerm | os.ModeSticky},
+	{"dev", 0755},
+	{"proc", 0555},
+	{"sys", 0555},
+	{"root", 0700},
+}
+
+// mergedUsrLinks 是 merged-usr 系统中指向 /usr 的顶层符号链接。
+var mergedUsrLinks = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64"}
+
+// RootfsBuilder 从宿主机可执行文件生成最小 rootfs。
+//
+// 对每个可执行文件解析 ELF 动态依赖（PT_INTERP 解释器、DT_NEEDED 库，按 RPATH/RUNPATH、
+// /etc/ld.so.conf 和默认库目录查找），脚本则解析 "#!" 解释器；
+// 路径上的符号链接（如 /bin -> usr/bin、/lib64/ld-linux-x86-64.so.2）按原样重建。
+// 生成的目录可以直接用作 PivotRootConfig.RootDir 或 OverlayConfig.LowerDirs。
+//
+// 注意：dlopen 动态加载的库（如 NSS 模块、Python 扩展的部分依赖）无法静态发现，
+// 需要通过 Files 显式加入；Files 中的 ELF 文件同样会解析依赖。
+//
+// 使用方式：
+//
+//	b := sandbox.NewRootfsBuilder(sandbox.DefaultRootfsConfig("python3", "sh", "git"))
+//	files, err := b.Build("/var/lib/ai-sandbox/rootfs/strict")
+//	pcfg.RootDir = "/var/lib/ai-sandbox/rootfs/strict"
+type RootfsBuilder struct {
+	config RootfsConfig
+	logger *zap.Logger
+
+	root    string
+	added   map[string]bool // 已加入的宿主机路径
+	scanned map[string]bool // 已解析依赖的 ELF 文件（真实路径）
+	queue   []string        // 待解析依赖的 ELF 文件
+	libDirs []string        // 系统库搜索目录
+	files   []string        // 已复制的普通文件（rootfs 内路径）
+}
+
+// NewRootfsBuilder 创建 rootfs 生成器。
+func NewRootfsBuilder(config RootfsConfig) *RootfsBuilder {
+	return &RootfsBuilder{config: config}
+}
+
+// SetLogger 设置此RootfsBuilder的日志记录器。
+func (b *RootfsBuilder) SetLogger(l *zap.Logger) {
+	b.logger = l
+}
+
+// Build 在 dir 中生成 rootfs，返回复制的普通文件列表（rootfs 内的绝对路径）。
+// dir 不存在时自动创建；已存在的文件保持不变，因此可以增量添加。
+func (b *RootfsBuilder) Build(dir string) ([]string, error) {
+	if len(b.config.Binaries) == 0 {
+		return nil, errors.New("rootfs: no binaries specified")
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("rootfs: %w", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("rootfs: %w", err)
+	}
+
+	b.root = root
+	b.added = make(map[string]bool)
+	b.scanned = make(map[string]bool)
+	b.queue = nil
+	b.files = nil
+	b.libDirs = systemLibDirs()
+
+	for _, name := range b.config.Binaries {
+		if err := b.addBinary(name); err != nil {
+			return nil, fmt.Errorf("rootfs: %s: %w", name, err)
+		}
+	}
+	for _, p := range b.config.Files {
+		if err := b.addTree(p); err != nil {
+			return nil, fmt.Errorf("rootfs: %s: %w", p, err)
+		}
+	}
+
+	// 逐个解析 ELF 依赖，新加入的库再入队，直到闭包完整
+	for len(b.queue) > 0 {
+		p := b.queue[0]
+		b.queue = b.queue[1:]
+		if err := b.addELFDeps(p); err != nil {
+			return nil, fmt.Errorf("rootfs: %s: %w", p, err)
+		}
+	}
+
+	// 宿主机为 merged-usr 时重建 /bin -> usr/bin 等顶层链接，使 "#!/bin/sh" 等固定路径可用
+	for _, p := range mergedUsrLinks {
+		if fi, err := os.Lstat(p); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			if err := b.addPath(p); err != nil {
+				return nil, fmt.Errorf("rootfs: %w", err)
+			}
+		}
+	}
+
+	// ld.so.cache 使动态链接器能找到非默认目录中的库（缺失的条目会被跳过）
+	if err := b.addPath("/etc/ld.so.cache"); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("rootfs: %w", err)
+	}
+	if err := b.writeBasics(); err != nil {
+		return nil, fmt.Errorf("rootfs: %w", err)
+	}
+
+	if b.logger != nil {
+		b.logger.Info("rootfs built",
+			zap.String("root", root),
+			zap.Strings("binaries", b.config.Binaries),
+			zap.Int("files", len(b.files)),
+		)
+	}
+	return b.files, nil
+}
+
+// addBinary 加入可执行文件（名称在 PATH 中查找）。
+func (b *RootfsBuilder) addBinary(name string) error {
+	p, err := exec.LookPath(name)
+	if err != nil {
+		return err
+	}
+	p, err = filepath.Abs(p)
+	if err != nil {
+		return err
+	}
+	return b.addPath(p)
+}
+
+// addTree 加入宿主机文件或目录（递归）。
+func (b *RootfsBuilder) addTree(p string) error {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return err
+	}
+	if err := b.addPath(p); err != nil {
+		return err
+	}
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(real, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return b.addPath(path)
+	})
+}
+
+// addPath 将宿主机路径 p 加入 rootfs：先加入父目录，再按类型复制。
+// 符号链接在 rootfs 中重建，并继续加入其目标；普通 ELF 文件入队解析依赖。
+func (b *RootfsBuilder) addPath(p string) error {
+	p = filepath.Clean(p)
+	if p == "/" || b.added[p] {
+		return nil
+	}
+	if err := b.addPath(filepath.Dir(p)); err != nil {
+		return err
+	}
+
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return err
+	}
+	b.added[p] = true
+
+	// 父目录已加入，在 rootfs 内解析父目录（跟随 rootfs 内的符号链接，不会逃逸）
+	parent, err := securejoin(b.root, filepath.Dir(p))
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(parent, fi.Name())
+
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			if err := os.Symlink(target, dst); err != nil {
+				return err
+			}
+		}
+		if !filepath.IsAbs(target) {
+			// 相对目标按父目录的真实路径解析，与内核处理 ".." 的方式一致
+			realParent, err := filepath.EvalSymlinks(filepath.Dir(p))
+			if err != nil {
+				return err
+			}
+			target = filepath.Join(realParent, target)
+		}
+		return b.addPath(target)
+
+	case fi.IsDir():
+		if err := os.Mkdir(dst, 0700); err != nil && !os.IsExist(err) {
+			return err
+		}
+		return copyAttrs(dst, fi)
+
+	case fi.Mode().IsRegular():
+		if _, err := os.Lstat(dst); err == nil {
+			return nil
+		}
+		if err := b.copyFile(p, dst, fi); err != nil {
+			return err
+		}
+		b.files = append(b.files, strings.TrimPrefix(dst, b.root))
+		if isELF(p) {
+			b.queue = append(b.queue, p)
+		} else if interp := shebangInterpreter(p); interp != nil {
+			for _, name := range interp {
+				// 非致命：Files 目录中可能有宿主机未安装解释器的脚本
+				if err := b.addBinary(name); err != nil && b.logger != nil {
+					b.logger.Warn("rootfs: script interpreter not found",
+						zap.String("script", p), zap.String("interpreter", name), zap.Error(err))
+				}
+			}
+		}
+		return nil
+	}
+	// 设备、FIFO、socket 等不复制
+	return nil
+}
+
+// copyFile 复制（或硬链接）普通文件并保留权限和属主。
+func (b *RootfsBuilder) copyFile(src, dst string, fi os.FileInfo) error {
+	if b.config.Hardlink {
+		if err := os.Link(src, dst); err == nil {
+			return nil
+		} else if !errors.Is(err, syscall.EXDEV) && !errors.Is(err, syscall.EPERM) {
+			return err
+		}
+		// 跨文件系统或受 protected_hardlinks 限制时退回复制
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY|syscall.O_NOFOLLOW, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return copyAttrs(dst, fi)
+}
+
+// addELFDeps 加入 ELF 文件的解释器和 DT_NEEDED 依赖。
+func (b *RootfsBuilder) addELFDeps(p string) error {
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return err
+	}
+	if b.scanned[real] {
+		return nil
+	}
+	b.scanned[real] = true
+
+	f, err := elf.Open(real)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		data, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return fmt.Errorf("read PT_INTERP: %w", err)
+		}
+		if err := b.addPath(string(bytes.TrimRight(data, "\x00"))); err != nil {
+			return fmt.Errorf("interpreter: %w", err)
+		}
+	}
+
+	needed, err := f.ImportedLibraries()
+	if err != nil {
+		// 静态链接的可执行文件没有动态段
+		return nil
+	}
+	searchDirs := elfSearchDirs(f, filepath.Dir(real))
+	for _, lib := range needed {
+		libPath, err := findLibrary(lib, f, searchDirs, b.libDirs)
+		if err != nil {
+			return err
+		}
+		if err := b.addPath(libPath); err != nil {
+			return fmt.Errorf("%s: %w", lib, err)
+		}
+	}
+	return nil
+}
+
+// writeBasics 创建基础目录和 /etc 文件（不覆盖已存在的）。
+func (b *RootfsBuilder) writeBasics() error {
+	for _, d := range rootfsDirs {
+		p, err := securejoin(b.root, d.path)
+		if err != nil {
+			return err
+		}
+		if err := os.Mkdir(p, 0700); err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return err
+		}
+		if err := os.Chmod(p, d.mode); err != nil {
+			return err
+		}
+	}
+	for name, content := range rootfsEtcFiles {
+		p, err := securejoin(b.root, name)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(p); err == nil {
+			continue
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// elfSearchDirs 返回 ELF 文件自身的 RPATH/RUNPATH 搜索目录（展开 $ORIGIN）。
+// 与 ld.so 一致：存在 RUNPATH 时忽略 RPATH。
+func elfSearchDirs(f *elf.File, origin string) []string {
+	paths, _ := f.DynString(elf.DT_RUNPATH)
+	if len(paths) == 0 {
+		paths, _ = f.DynString(elf.DT_RPATH)
+	}
+	var dirs []string
+	for _, p := range paths {
+		for _, d := range strings.Split(p, ":") {
+			d = strings.ReplaceAll(d, "${ORIGIN}", origin)
+			d = strings.ReplaceAll(d, "$ORIGIN", origin)
+			if d != "" {
+				dirs = append(dirs, d)
+			}
+		}
+	}
+	return dirs
+}
+
+// findLibrary 按搜索目录查找与 f 架构一致的共享库。
+func findLibrary(name string, f *elf.File, dirLists ...[]string) (string, error) {
+	if strings.Contains(name, "/") {
+		return name, nil
+	}
+	for _, dirs := range dirLists {
+		for _, d := range dirs {
+			p := filepath.Join(d, name)
+			if elfCompatible(p, f) {
+				return p, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("library %s not found", name)
+}
+
+// elfCompatible 判断 p 是否为与 f 相同类别和机器架构的 ELF 文件。
+func elfCompatible(p string, f *elf.File) bool {
+	lib, err := elf.Open(p)
+	if err != nil {
+		return false
+	}
+	defer lib.Close()
+	return lib.Class == f.Class && lib.Machine == f.Machine
+}
+
+// systemLibDirs 返回系统库搜索目录：/etc/ld.so.conf 中的目录在前，默认目录在后。
+func systemLibDirs() []string {
+	dirs := readLdSoConf("/etc/ld.so.conf", 0)
+	if triplet := multiarchTriplet(); triplet != "" {
+		dirs = append(dirs, "/lib/"+triplet, "/usr/lib/"+triplet)
+	}
+	return append(dirs, "/lib64", "/usr/lib64", "/lib", "/usr/lib")
+}
+
+// readLdSoConf 解析 ld.so.conf（支持 include 通配），返回库目录。
+func readLdSoConf(path string, depth int) []string {
+	if depth > 8 {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if pattern, ok := strings.CutPrefix(line, "include"); ok {
+			pattern = strings.TrimSpace(pattern)
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(path), pattern)
+			}
+			matches, _ := filepath.Glob(pattern)
+			for _, m := range matches {
+				dirs = append(dirs, readLdSoConf(m, depth+1)...)
+			}
+			continue
+		}
+		dirs = append(dirs, line)
+	}
+	return dirs
+}
+
+// multiarchTriplet 返回 Debian 风格的多架构库目录名。
+func multiarchTriplet() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64-linux-gnu"
+	case "arm64":
+		return "aarch64-linux-gnu"
+	case "386":
+		return "i386-linux-gnu"
+	case "arm":
+		return "arm-linux-gnueabihf"
+	case "riscv64":
+		return "riscv64-linux-gnu"
+	case "ppc64le":
+		return "powerpc64le-linux-gnu"
+	case "s390x":
+		return "s390x-linux-gnu"
+	}
+	return ""
+}
+
+// isELF 判断文件是否以 ELF 魔数开头。
+func isELF(p string) bool {
+	f, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == elf.ELFMAG
+}
+
+// shebangInterpreter 返回脚本 "#!" 行需要的可执行文件：解释器本身，
+// 以及 "#!/usr/bin/env prog" 形式中的 prog。非脚本返回 nil。
+func shebangInterpreter(p string) []string {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	buf := make([]byte, 256)
+	n, _ := io.ReadFull(f, buf)
+	line, _, _ := strings.Cut(string(buf[:n]), "\n")
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return nil
+	}
+	interp := []string{fields[0]}
+	if filepath.Base(fields[0]) == "env" {
+		for _, arg := range fields[1:] {
+			if !strings.HasPrefix(arg, "-") {
+				interp = append(interp, arg)
+				break
+			}
+		}
+	}
+	return interp
+}
+
+// copyAttrs 将宿主机文件的权限位和属主复制到 dst（非 root 时不修改属主）。
+// chown 必须在 chmod 之前：chown 会清除 setuid/setgid 位。
+func copyAttrs(dst string, fi os.FileInfo) error {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && os.Geteuid() == 0 {
+		if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(dst, fi.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+}
@@ -0,0 +1,187 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================================================================
+// Rootfs 单元测试（不需要 root）
+// ===================================================================
+
+func TestShebangInterpreter(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string][]string{
+		"#!/bin/sh\necho hi\n":           {"/bin/sh"},
+		"#!/usr/bin/env python3\n":       {"/usr/bin/env", "python3"},
+		"#!/usr/bin/env -S python3 -u\n": {"/usr/bin/env", "python3"},
+		"#! /usr/bin/python3 -u\nimport": {"/usr/bin/python3"},
+		"plain text":                     nil,
+	}
+	i := 0
+	for content, want := range tests {
+		p := filepath.Join(dir, strings.Repeat("x", i+1))
+		i++
+		os.WriteFile(p, []byte(content), 0755)
+		if got := shebangInterpreter(p); !reflect.DeepEqual(got, want) {
+			t.Errorf("shebangInterpreter(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+func TestReadLdSoConf(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "conf.d"), 0755)
+	os.WriteFile(filepath.Join(dir, "ld.so.conf"), []byte("# comment\ninclude conf.d/*.conf\n/opt/lib\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "conf.d", "a.conf"), []byte("/usr/local/lib # local\n"), 0644)
+
+	got := readLdSoConf(filepath.Join(dir, "ld.so.conf"), 0)
+	want := []string{"/usr/local/lib", "/opt/lib"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRootfsBuildNoBinaries(t *testing.T) {
+	if _, err := NewRootfsBuilder(RootfsConfig{}).Build(t.TempDir()); err == nil {
+		t.Error("expected error without binaries")
+	}
+}
+
+func TestRootfsBuildDeps(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	real, _ := filepath.EvalSymlinks(sh)
+
+	root := t.TempDir()
+	files, err := NewRootfsBuilder(DefaultRootfsConfig("sh")).Build(root)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	// 可执行文件本身通过原路径可访问（符号链接链被重建）
+	if _, err := os.Stat(filepath.Join(root, sh)); err != nil {
+		t.Errorf("%s not reachable in rootfs: %v", sh, err)
+	}
+	if !containsString(files, real) {
+		t.Errorf("expected %s in copied files %v", real, files)
+	}
+
+	// 动态链接的可执行文件：解释器和所有依赖库都应存在
+	f, err := elf.Open(real)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		data := make([]byte, prog.Filesz)
+		prog.ReadAt(data, 0)
+		interp := string(bytes.TrimRight(data, "\x00"))
+		if _, err := os.Stat(filepath.Join(root, interp)); err != nil {
+			t.Errorf("interpreter %s missing: %v", interp, err)
+		}
+	}
+	libs, _ := f.ImportedLibraries()
+	for _, lib := range libs {
+		found := false
+		for _, p := range files {
+			if filepath.Base(p) == lib || strings.HasPrefix(filepath.Base(p), lib) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("library %s not copied: %v", lib, files)
+		}
+	}
+
+	for _, name := range []string{"etc/passwd", "etc/group", "etc/hosts"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Errorf("%s missing: %v", name, err)
+		}
+	}
+	fi, err := os.Stat(filepath.Join(root, "tmp"))
+	if err != nil || fi.Mode()&os.ModeSticky == 0 || fi.Mode()&os.ModePerm != 0777 {
+		t.Errorf("expected /tmp with mode 1777, got %v, %v", fi, err)
+	}
+}
+
+func TestRootfsFilesAndScripts(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+
+	host := t.TempDir()
+	os.MkdirAll(filepath.Join(host, "tools"), 0755)
+	os.WriteFile(filepath.Join(host, "tools", "run.sh"), []byte("#!/bin/sh\necho hi\n"), 0755)
+	os.Symlink("run.sh", filepath.Join(host, "tools", "run"))
+
+	root := t.TempDir()
+	cfg := DefaultRootfsConfig(filepath.Join(host, "tools", "run"))
+	if _, err := NewRootfsBuilder(cfg).Build(root); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if target, err := os.Readlink(filepath.Join(root, host, "tools", "run")); err != nil || target != "run.sh" {
+		t.Errorf("expected symlink run -> run.sh, got %q, %v", target, err)
+	}
+	// 脚本的 "#!" 解释器被自动加入
+	if _, err := os.Stat(filepath.Join(root, "bin", "sh")); err != nil {
+		t.Errorf("script interpreter /bin/sh missing: %v", err)
+	}
+}
+
+// ===================================================================
+// Rootfs 集成测试（需要 root）
+// ===================================================================
+
+func TestRootfsAsPivotRoot(t *testing.T) {
+	skipIfNotRoot(t)
+
+	root := t.TempDir()
+	if _, err := NewRootfsBuilder(DefaultRootfsConfig("sh", "ls")).Build(root); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	pcfg := DefaultPivotRootConfig()
+	pcfg.RootDir = root
+	ns.SetPivotRoot(&pcfg)
+
+	var buf bytes.Buffer
+	r, w, _ := os.Pipe()
+	ns.Stdout = w
+
+	if err := ns.Start("sh", "-c", "ls /usr/bin"); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	result, err := ns.Wait()
+	w.Close()
+	buf.ReadFrom(r)
+	r.Close()
+
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code: %d", result.ExitCode)
+	}
+	// 最小 rootfs 中只有显式请求的工具
+	if strings.Contains(buf.String(), "python") || !strings.Contains(buf.String(), "ls") {
+		t.Errorf("unexpected /usr/bin contents: %q", buf.String())
+	}
+}
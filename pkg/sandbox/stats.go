@@ -0,0 +1,374 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CgroupStats 是一次 cgroup 资源统计采样。
+// 控制器未启用（对应文件不存在）时相应字段为零值或 nil。
+type CgroupStats struct {
+	Time      time.Time     `json:"time"`
+	Populated bool          `json:"populated"` // cgroup 内仍有进程（cgroup.events）
+	Frozen    bool          `json:"frozen"`    // 已被 Freeze 冻结（沙箱处于 Paused 状态）
+	CPU       CPUStats      `json:"cpu"`
+	Memory    MemoryStats   `json:"memory"`
+	Pids      PidsStats     `json:"pids"`
+	IO        []IOStats     `json:"io,omitempty"` // 按设备的 I/O 统计（io.stat）
+	Pressure  PressureStats `json:"pressure"`     // PSI 压力信息
+}
+
+// CPUStats 对应 cpu.stat。
+type CPUStats struct {
+	UsageUsec     uint64 `json:"usage_usec"` // 累计 CPU 时间（微秒）
+	UserUsec      uint64 `json:"user_usec"`
+	SystemUsec    uint64 `json:"system_usec"`
+	NrPeriods     uint64 `json:"nr_periods"`     // 经过的 cpu.max 周期数
+	NrThrottled   uint64 `json:"nr_throttled"`   // 被限流的周期数
+	ThrottledUsec uint64 `json:"throttled_usec"` // 被限流的总时间（微秒）
+}
+
+// MemoryStats 对应 memory.current、memory.peak、memory.stat 和 memory.events。
+type MemoryStats struct {
+	Current uint64            `json:"current"`        // 当前用量（字节）
+	Peak    uint64            `json:"peak"`           // 峰值用量（字节，需要内核 5.19+）
+	Stat    map[string]uint64 `json:"stat,omitempty"` // memory.stat 的全部条目（anon、file、kernel 等）
+	Events  MemoryEvents      `json:"events"`
+}
+
+// MemoryEvents 对应 memory.events。
+type MemoryEvents struct {
+	Low     uint64 `json:"low"`
+	High    uint64 `json:"high"` // 超过 memory.high 被限流的次数
+	Max     uint64 `json:"max"`  // 触及 memory.max 的次数
+	OOM     uint64 `json:"oom"`
+	OOMKill uint64 `json:"oom_kill"` // 被 OOM killer 杀死的进程数
+}
+
+// PidsStats 对应 pids.current。
+type PidsStats struct {
+	Current uint64 `json:"current"`
+}
+
+// IOStats 是 io.stat 中单个设备的统计。
+type IOStats struct {
+	Major  uint32 `json:"major"`
+	Minor  uint32 `json:"minor"`
+	RBytes uint64 `json:"rbytes"`
+	WBytes uint64 `json:"wbytes"`
+	RIOs   uint64 `json:"rios"`
+	WIOs   uint64 `json:"wios"`
+	DBytes uint64 `json:"dbytes"` // discard
+	DIOs   uint64 `json:"dios"`
+}
+
+// PressureStats 是 cpu、memory、io 的 PSI 压力信息（内核未启用 PSI 时为 nil）。
+type PressureStats struct {
+	CPU    *PSIStats `json:"cpu,omitempty"`
+	Memory *PSIStats `json:"memory,omitempty"`
+	IO     *PSIStats `json:"io,omitempty"`
+}
+
+// PSIStats 对应 *.pressure 文件。
+type PSIStats struct {
+	Some PSIData `json:"some"` // 至少一个任务因该资源停顿的时间占比
+	Full PSIData `json:"full"` // 所有非空闲任务同时停顿的时间占比
+}
+
+// PSIData 是 PSI 文件中的一行。
+type PSIData struct {
+	Avg10  float64 `json:"avg10"` // 最近 10 秒的停顿百分比
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total"` // 累计停顿时间（微秒）
+}
+
+// Stats 读取此 cgroup 当前的资源统计。
+func (cg *CgroupsV2) Stats() (*CgroupStats, error) {
+	cg.mu.Lock()
+	dir, done := cg.cgroupDir, cg.setupDone
+	cg.mu.Unlock()
+
+	if !done {
+		return nil, fmt.Errorf("cgroups: not set up")
+	}
+	return ReadCgroupStats(dir)
+}
+
+// StatsStream 每隔 interval 采样一次资源统计并发送到返回的 channel，interval <= 0 时每秒采样一次。
+//
+// 调用返回的 stop 函数或 cgroup 被删除（没有任何统计可读）时停止采样并关闭 channel；
+// 个别统计文件读取失败时仍发送其余统计。
+// 接收方处理不及时时丢弃该次采样，不阻塞采样循环。
+func (cg *CgroupsV2) StatsStream(interval time.Duration) (<-chan *CgroupStats, func()) {
+	ch := make(chan *CgroupStats, 1)
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() { once.Do(func() { close(done) }) }
+
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s, _ := cg.Stats()
+			if s == nil {
+				return
+			}
+			select {
+			case ch <- s:
+			default:
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return ch, stop
+}
+
+// ReadCgroupStats 读取任意 cgroup v2 目录的资源统计。
+// 目录不存在时返回错误，单个统计文件不存在（控制器未启用）时跳过。
+func ReadCgroupStats(dir string) (*CgroupStats, error) {
+	if _, err := os.Stat(filepath.Join(dir, "cgroup.procs")); err != nil {
+		return nil, fmt.Errorf("cgroups: stats: %w", err)
+	}
+
+	s := &CgroupStats{Time: time.Now()}
+	var errs []error
+	collect := func(err error) {
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	events, err := readCgroupEvents(dir)
+	collect(err)
+	s.Populated = events["populated"] == "1"
+	s.Frozen = events["frozen"] == "1"
+
+	collect(readKeyValueFile(filepath.Join(dir, "cpu.stat"), func(key string, v uint64) {
+		switch key {
+		case "usage_usec":
+			s.CPU.UsageUsec = v
+		case "user_usec":
+			s.CPU.UserUsec = v
+		case "system_usec":
+			s.CPU.SystemUsec = v
+		case "nr_periods":
+			s.CPU.NrPeriods = v
+		case "nr_throttled":
+			s.CPU.NrThrottled = v
+		case "throttled_usec":
+			s.CPU.ThrottledUsec = v
+		}
+	}))
+
+	s.Memory.Current, err = readUintFile(filepath.Join(dir, "memory.current"))
+	collect(err)
+	s.Memory.Peak, err = readUintFile(filepath.Join(dir, "memory.peak"))
+	collect(err)
+	collect(readKeyValueFile(filepath.Join(dir, "memory.stat"), func(key string, v uint64) {
+		if s.Memory.Stat == nil {
+			s.Memory.Stat = make(map[string]uint64)
+		}
+		s.Memory.Stat[key] = v
+	}))
+	collect(readKeyValueFile(filepath.Join(dir, "memory.events"), func(key string, v uint64) {
+		switch key {
+		case "low":
+			s.Memory.Events.Low = v
+		case "high":
+			s.Memory.Events.High = v
+		case "max":
+			s.Memory.Events.Max = v
+		case "oom":
+			s.Memory.Events.OOM = v
+		case "oom_kill":
+			s.Memory.Events.OOMKill = v
+		}
+	}))
+
+	s.Pids.Current, err = readUintFile(filepath.Join(dir, "pids.current"))
+	collect(err)
+
+	s.IO, err = readIOStat(filepath.Join(dir, "io.stat"))
+	collect(err)
+
+	s.Pressure.CPU, err = readPSI(filepath.Join(dir, "cpu.pressure"))
+	collect(err)
+	s.Pressure.Memory, err = readPSI(filepath.Join(dir, "memory.pressure"))
+	collect(err)
+	s.Pressure.IO, err = readPSI(filepath.Join(dir, "io.pressure"))
+	collect(err)
+
+	if len(errs) > 0 {
+		return s, fmt.Errorf("cgroups: stats: %v", errs)
+	}
+	return s, nil
+}
+
+// readUintFile 读取只包含一个数字的 cgroup 文件，"max" 视为 0。
+func readUintFile(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	v := strings.TrimSpace(string(data))
+	if v == "max" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return n, nil
+}
+
+// readKeyValueFile 逐行解析 "key value" 格式的 cgroup 文件（cpu.stat、memory.stat 等）。
+func readKeyValueFile(path string, fn func(key string, v uint64)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		fn(key, n)
+	}
+	return scanner.Err()
+}
+
+// readIOStat 解析 io.stat：每行 "major:minor rbytes=.. wbytes=.. rios=.. wios=.. dbytes=.. dios=.."。
+func readIOStat(path string) ([]IOStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var stats []IOStats
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		majStr, minStr, ok := strings.Cut(fields[0], ":")
+		if !ok {
+			continue
+		}
+		major, err1 := strconv.ParseUint(majStr, 10, 32)
+		minor, err2 := strconv.ParseUint(minStr, 10, 32)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		st := IOStats{Major: uint32(major), Minor: uint32(minor)}
+		for _, kv := range fields[1:] {
+			key, value, _ := strings.Cut(kv, "=")
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				st.RBytes = n
+			case "wbytes":
+				st.WBytes = n
+			case "rios":
+				st.RIOs = n
+			case "wios":
+				st.WIOs = n
+			case "dbytes":
+				st.DBytes = n
+			case "dios":
+				st.DIOs = n
+			}
+		}
+		stats = append(stats, st)
+	}
+	return stats, scanner.Err()
+}
+
+// readPSI 解析 PSI 文件：
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func readPSI(path string) (*PSIStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	psi := &PSIStats{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var d *PSIData
+		switch fields[0] {
+		case "some":
+			d = &psi.Some
+		case "full":
+			d = &psi.Full
+		default:
+			continue
+		}
+		for _, kv := range fields[1:] {
+			key, value, _ := strings.Cut(kv, "=")
+			switch key {
+			case "avg10":
+				d.Avg10, _ = strconv.ParseFloat(value, 64)
+			case "avg60":
+				d.Avg60, _ = strconv.ParseFloat(value, 64)
+			case "avg300":
+				d.Avg300, _ = strconv.ParseFloat(value, 64)
+			case "total":
+				d.Total, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return psi, nil
+}
+
+// CPUPercent 返回 prev 到 s 两次采样之间的 CPU 使用率（100 表示占满一个核）。
+func (s *CgroupStats) CPUPercent(prev *CgroupStats) float64 {
+	if prev == nil || s.CPU.UsageUsec < prev.CPU.UsageUsec {
+		return 0
+	}
+	elapsed := s.Time.Sub(prev.Time).Microseconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.CPU.UsageUsec-prev.CPU.UsageUsec) / float64(elapsed) * 100
+}
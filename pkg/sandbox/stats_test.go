@@ -0,0 +1,226 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// ===================================================================
+// 资源统计单元测试（不需要 root）
+// ===================================================================
+
+// writeCgroupFixture 在临时目录中模拟沙箱 cgroup base/sandbox-test，写入给定的控制文件，
+// 返回沙箱 cgroup 目录。父目录 base 中只有 cgroup.subtree_control。
+func writeCgroupFixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+	base := t.TempDir()
+	dir := filepath.Join(base, "sandbox-test")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "cgroup.subtree_control"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	files["cgroup.procs"] = ""
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadCgroupStats(t *testing.T) {
+	dir := writeCgroupFixture(t, map[string]string{
+		"cpu.stat": "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n" +
+			"nr_periods 20\nnr_throttled 3\nthrottled_usec 42000\nnr_bursts 0\n",
+		"memory.current": "10485760\n",
+		"memory.peak":    "20971520\n",
+		"memory.stat":    "anon 4096\nfile 8192\nkernel 1024\n",
+		"memory.events":  "low 0\nhigh 5\nmax 2\noom 1\noom_kill 1\noom_group_kill 0\n",
+		"pids.current":   "7\n",
+		"io.stat": "253:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n" +
+			"8:0 rbytes=100 wbytes=200 rios=3 wios=4 dbytes=5 dios=6\n",
+		"cpu.pressure": "some avg10=1.50 avg60=0.75 avg300=0.10 total=123456\n" +
+			"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+		"memory.pressure": "some avg10=0.00 avg60=0.00 avg300=0.00 total=10\n" +
+			"full avg10=0.00 avg60=0.00 avg300=0.00 total=5\n",
+	})
+
+	s, err := ReadCgroupStats(dir)
+	if err != nil {
+		t.Fatalf("ReadCgroupStats failed: %v", err)
+	}
+
+	wantCPU := CPUStats{UsageUsec: 1500000, UserUsec: 1000000, SystemUsec: 500000, NrPeriods: 20, NrThrottled: 3, ThrottledUsec: 42000}
+	if s.CPU != wantCPU {
+		t.Errorf("CPU = %+v, want %+v", s.CPU, wantCPU)
+	}
+	if s.Memory.Current != 10485760 || s.Memory.Peak != 20971520 {
+		t.Errorf("unexpected memory usage: %+v", s.Memory)
+	}
+	if s.Memory.Stat["anon"] != 4096 || s.Memory.Stat["file"] != 8192 || len(s.Memory.Stat) != 3 {
+		t.Errorf("unexpected memory.stat: %v", s.Memory.Stat)
+	}
+	wantEvents := MemoryEvents{High: 5, Max: 2, OOM: 1, OOMKill: 1}
+	if s.Memory.Events != wantEvents {
+		t.Errorf("Events = %+v, want %+v", s.Memory.Events, wantEvents)
+	}
+	if s.Pids.Current != 7 {
+		t.Errorf("Pids.Current = %d, want 7", s.Pids.Current)
+	}
+	if len(s.IO) != 2 {
+		t.Fatalf("expected 2 io devices, got %+v", s.IO)
+	}
+	wantIO := IOStats{Major: 8, Minor: 0, RBytes: 100, WBytes: 200, RIOs: 3, WIOs: 4, DBytes: 5, DIOs: 6}
+	if s.IO[1] != wantIO {
+		t.Errorf("IO[1] = %+v, want %+v", s.IO[1], wantIO)
+	}
+	if p := s.Pressure.CPU; p == nil || p.Some.Avg10 != 1.5 || p.Some.Avg60 != 0.75 || p.Some.Total != 123456 {
+		t.Errorf("unexpected cpu pressure: %+v", p)
+	}
+	if p := s.Pressure.Memory; p == nil || p.Full.Total != 5 {
+		t.Errorf("unexpected memory pressure: %+v", p)
+	}
+	// io.pressure 不存在
+	if s.Pressure.IO != nil {
+		t.Errorf("expected nil io pressure, got %+v", s.Pressure.IO)
+	}
+}
+
+func TestReadCgroupStatsMissingControllers(t *testing.T) {
+	// 只有 cpu.stat（未启用 memory/pids/io 控制器的 cgroup）
+	dir := writeCgroupFixture(t, map[string]string{
+		"cpu.stat": "usage_usec 10\nuser_usec 5\nsystem_usec 5\n",
+	})
+	s, err := ReadCgroupStats(dir)
+	if err != nil {
+		t.Fatalf("ReadCgroupStats failed: %v", err)
+	}
+	if s.CPU.UsageUsec != 10 || s.Memory.Current != 0 || s.Memory.Stat != nil || s.IO != nil {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	if _, err := ReadCgroupStats(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for a missing cgroup")
+	}
+}
+
+func TestReadCgroupStatsInvalid(t *testing.T) {
+	dir := writeCgroupFixture(t, map[string]string{
+		"memory.current": "garbage\n",
+		"pids.current":   "3\n",
+	})
+	s, err := ReadCgroupStats(dir)
+	if err == nil {
+		t.Fatal("expected error for an unparsable memory.current")
+	}
+	// 其余统计仍然返回
+	if s == nil || s.Pids.Current != 3 {
+		t.Errorf("expected partial stats, got %+v", s)
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	now := time.Now()
+	prev := &CgroupStats{Time: now, CPU: CPUStats{UsageUsec: 1000000}}
+	cur := &CgroupStats{Time: now.Add(2 * time.Second), CPU: CPUStats{UsageUsec: 2000000}}
+	if got := cur.CPUPercent(prev); got != 50 {
+		t.Errorf("CPUPercent = %v, want 50", got)
+	}
+	if got := cur.CPUPercent(nil); got != 0 {
+		t.Errorf("CPUPercent(nil) = %v, want 0", got)
+	}
+	if got := prev.CPUPercent(cur); got != 0 {
+		t.Errorf("CPUPercent with counter going backwards = %v, want 0", got)
+	}
+}
+
+func TestStatsStreamFixture(t *testing.T) {
+	dir := writeCgroupFixture(t, map[string]string{
+		"memory.current": "garbage\n",
+		"pids.current":   "3\n",
+	})
+	cg := &CgroupsV2{cgroupDir: dir, setupDone: true}
+
+	// interval <= 0 使用默认间隔，不会 panic
+	stream, stop := cg.StatsStream(0)
+	if s := <-stream; s == nil || s.Pids.Current != 3 {
+		t.Errorf("first sample = %+v", s)
+	}
+	stop()
+	for range stream {
+	}
+
+	// 部分统计读取失败时继续采样，cgroup 被删除后关闭 channel
+	stream, stop = cg.StatsStream(10 * time.Millisecond)
+	defer stop()
+	for i := 0; i < 3; i++ {
+		if s, ok := <-stream; !ok || s.Pids.Current != 3 {
+			t.Fatalf("sample %d = %+v, open %v", i, s, ok)
+		}
+	}
+	os.Remove(filepath.Join(dir, "cgroup.procs"))
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-stream:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("stream not closed after the cgroup was removed")
+		}
+	}
+}
+
+// ===================================================================
+// 资源统计集成测试（需要 root + cgroups v2）
+// ===================================================================
+
+func TestCgroupsV2Stats(t *testing.T) {
+	base := cgroup2Mount(t)
+
+	cg := NewCgroupsV2(CgroupsConfig{Enabled: true, BaseDir: base})
+	if _, err := cg.Stats(); err == nil {
+		t.Error("expected error before Setup")
+	}
+	if err := cg.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.SetCgroupsV2(cg)
+	// 忙循环约 0.5 秒后退出
+	if err := ns.Start("sh", "-c", `end=$(($(date +%s%N) + 500000000)); while [ $(date +%s%N) -lt $end ]; do :; done; sleep 10`); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+
+	stream, stop := cg.StatsStream(100 * time.Millisecond)
+	var last *CgroupStats
+	timeout := time.After(5 * time.Second)
+	for last == nil || last.CPU.UsageUsec < 100000 {
+		select {
+		case s, ok := <-stream:
+			if !ok {
+				t.Fatal("stream closed unexpectedly")
+			}
+			last = s
+		case <-timeout:
+			t.Fatalf("CPU usage not reported, last sample: %+v", last)
+		}
+	}
+	stop()
+	stop() // 重复调用无副作用
+	for range stream {
+	}
+
+	ns.Signal(syscall.SIGKILL)
+	ns.Wait()
+}
@@ -0,0 +1,201 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// execUser 是解析后的运行用户身份。
+type execUser struct {
+	Name   string // 用户名（数字 UID 不在 passwd 中时为空）
+	UID    int
+	GID    int
+	Groups []int  // 附加组
+	Home   string // 家目录（用于补充 HOME 环境变量）
+}
+
+// passwdEntry 是 /etc/passwd 的一行。
+type passwdEntry struct {
+	name  string
+	uid   int
+	gid   int
+	home  string
+	shell string
+}
+
+// groupEntry 是 /etc/group 的一行。
+type groupEntry struct {
+	name    string
+	gid     int
+	members []string
+}
+
+// resolveUser 按 "user[:group]" 解析运行用户，user/group 可以是名称或数字 ID。
+//
+// 名称在 passwdPath、groupPath 中查找（沙箱内的 /etc/passwd、/etc/group）；
+// 数字 UID 不在 passwd 中时 GID 默认为 0、家目录为 "/"（与 Docker 行为一致）。
+// 附加组取 /etc/group 中列出该用户名的所有组。
+func resolveUser(spec, passwdPath, groupPath string) (*execUser, error) {
+	userPart, groupPart, hasGroup := strings.Cut(spec, ":")
+	if userPart == "" {
+		return nil, fmt.Errorf("invalid user %q", spec)
+	}
+
+	users, err := readPasswd(passwdPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	groups, err := readGroup(groupPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	u := &execUser{Home: "/"}
+	var name string
+	if uid, err := parseID(userPart); err == nil {
+		u.UID = uid
+		for _, e := range users {
+			if e.uid == uid {
+				name, u.GID, u.Home = e.name, e.gid, e.home
+				break
+			}
+		}
+	} else {
+		found := false
+		for _, e := range users {
+			if e.name == userPart {
+				name, u.UID, u.GID, u.Home = e.name, e.uid, e.gid, e.home
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("user %q not found in %s", userPart, passwdPath)
+		}
+	}
+
+	if hasGroup {
+		if gid, err := parseID(groupPart); err == nil {
+			u.GID = gid
+		} else {
+			found := false
+			for _, g := range groups {
+				if g.name == groupPart {
+					u.GID = g.gid
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("group %q not found in %s", groupPart, groupPath)
+			}
+		}
+	}
+
+	u.Name = name
+	if name != "" {
+		for _, g := range groups {
+			for _, m := range g.members {
+				if m == name && g.gid != u.GID {
+					u.Groups = append(u.Groups, g.gid)
+					break
+				}
+			}
+		}
+	}
+	if u.Home == "" {
+		u.Home = "/"
+	}
+	return u, nil
+}
+
+// switchUser 将当前进程切换到指定用户（先设置组再设置 UID）。
+// Go 的 syscall.Setuid/Setgid 会作用于进程的所有线程。
+func switchUser(u *execUser) error {
+	if err := syscall.Setgroups(u.Groups); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setgid(u.GID); err != nil {
+		return fmt.Errorf("setgid %d: %w", u.GID, err)
+	}
+	if err := syscall.Setuid(u.UID); err != nil {
+		return fmt.Errorf("setuid %d: %w", u.UID, err)
+	}
+	return nil
+}
+
+// parseID 解析非负的 32 位用户/组 ID。
+func parseID(s string) (int, error) {
+	n, err := strconv.ParseUint(s, 10, 31)
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// readPasswd 解析 passwd 格式文件（name:x:uid:gid:gecos:home:shell）。
+func readPasswd(path string) ([]passwdEntry, error) {
+	var entries []passwdEntry
+	err := readColonFile(path, func(fields []string) {
+		if len(fields) < 6 {
+			return
+		}
+		uid, err1 := parseID(fields[2])
+		gid, err2 := parseID(fields[3])
+		if err1 != nil || err2 != nil {
+			return
+		}
+		e := passwdEntry{name: fields[0], uid: uid, gid: gid, home: fields[5]}
+		if len(fields) > 6 {
+			e.shell = fields[6]
+		}
+		entries = append(entries, e)
+	})
+	return entries, err
+}
+
+// readGroup 解析 group 格式文件（name:x:gid:member1,member2）。
+func readGroup(path string) ([]groupEntry, error) {
+	var entries []groupEntry
+	err := readColonFile(path, func(fields []string) {
+		if len(fields) < 3 {
+			return
+		}
+		gid, err := parseID(fields[2])
+		if err != nil {
+			return
+		}
+		g := groupEntry{name: fields[0], gid: gid}
+		if len(fields) > 3 && fields[3] != "" {
+			g.members = strings.Split(fields[3], ",")
+		}
+		entries = append(entries, g)
+	})
+	return entries, err
+}
+
+// readColonFile 逐行读取以冒号分隔的文件，跳过空行和注释。
+func readColonFile(path string, fn func(fields []string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fn(strings.Split(line, ":"))
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,105 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================================================================
+// 运行用户单元测试（不需要 root）
+// ===================================================================
+
+func writeUserFiles(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	passwd := filepath.Join(dir, "passwd")
+	group := filepath.Join(dir, "group")
+	os.WriteFile(passwd, []byte("root:x:0:0:root:/root:/bin/sh\n"+
+		"# comment\n"+
+		"agent:x:1000:1000::/home/agent:/bin/sh\n"), 0644)
+	os.WriteFile(group, []byte("root:x:0:\n"+
+		"agent:x:1000:\n"+
+		"docker:x:999:agent,other\n"+
+		"video:x:44:agent\n"), 0644)
+	return passwd, group
+}
+
+func TestResolveUser(t *testing.T) {
+	passwd, group := writeUserFiles(t)
+
+	tests := []struct {
+		spec string
+		want execUser
+	}{
+		{"agent", execUser{Name: "agent", UID: 1000, GID: 1000, Groups: []int{999, 44}, Home: "/home/agent"}},
+		{"1000", execUser{Name: "agent", UID: 1000, GID: 1000, Groups: []int{999, 44}, Home: "/home/agent"}},
+		{"agent:docker", execUser{Name: "agent", UID: 1000, GID: 999, Groups: []int{44}, Home: "/home/agent"}},
+		{"2000", execUser{UID: 2000, GID: 0, Home: "/"}},
+		{"2000:3000", execUser{UID: 2000, GID: 3000, Home: "/"}},
+	}
+	for _, tt := range tests {
+		u, err := resolveUser(tt.spec, passwd, group)
+		if err != nil {
+			t.Errorf("resolveUser(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(*u, tt.want) {
+			t.Errorf("resolveUser(%q) = %+v, want %+v", tt.spec, *u, tt.want)
+		}
+	}
+}
+
+func TestResolveUserErrors(t *testing.T) {
+	passwd, group := writeUserFiles(t)
+	for _, spec := range []string{"", ":0", "nobody", "agent:nogroup", "-1"} {
+		if _, err := resolveUser(spec, passwd, group); err == nil {
+			t.Errorf("resolveUser(%q): expected error", spec)
+		}
+	}
+
+	// 文件不存在时仍支持数字 ID
+	if u, err := resolveUser("1000:1000", "/nonexistent/passwd", "/nonexistent/group"); err != nil || u.UID != 1000 {
+		t.Errorf("numeric user without passwd: %+v, %v", u, err)
+	}
+}
+
+// ===================================================================
+// 运行用户集成测试（需要 root）
+// ===================================================================
+
+func TestNamespaceUser(t *testing.T) {
+	skipIfNotRoot(t)
+
+	ns := NewNamespace(DefaultNamespaceConfig())
+	defer ns.Cleanup()
+	ns.User = "65534:65534"
+
+	var buf bytes.Buffer
+	r, w, _ := os.Pipe()
+	ns.Stdout = w
+
+	if err := ns.Start("sh", "-c", "id -u; id -g; echo $HOME"); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	result, err := ns.Wait()
+	w.Close()
+	buf.ReadFrom(r)
+	r.Close()
+
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code: %d", result.ExitCode)
+	}
+	lines := strings.Fields(buf.String())
+	if len(lines) < 2 || lines[0] != "65534" || lines[1] != "65534" {
+		t.Errorf("expected uid/gid 65534, got %q", buf.String())
+	}
+}